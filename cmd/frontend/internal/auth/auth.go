@@ -0,0 +1,220 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth authenticates requests to the frontend with basic auth, OIDC bearer tokens
+// or Identity-Aware Proxy (IAP) signed headers, and restricts their queries to the label
+// values of their tenants.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/secutil"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Authentication methods of identities.
+const (
+	MethodBasic = "basic"
+	MethodOIDC  = "oidc"
+	MethodIAP   = "iap"
+)
+
+const (
+	// iapHeader holds the JWT signed by IAP.
+	iapHeader = "X-Goog-IAP-JWT-Assertion"
+	// iapIssuer and iapJWKSURL are the issuer and the public keys of IAP JWTs.
+	iapIssuer  = "https://cloud.google.com/iap"
+	iapJWKSURL = "https://www.gstatic.com/iap/verify/public_key-jwk"
+)
+
+// Identity is the authenticated identity of a request.
+type Identity struct {
+	// Method is the authentication method, one of MethodBasic, MethodOIDC and MethodIAP.
+	Method string
+	// User is the basic auth username or the username claim of the token.
+	User string
+	// Claims are the verified claims of the token. Unset for basic auth.
+	Claims map[string]any
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context holding the identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the request, or nil if authentication is
+// disabled.
+func IdentityFromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Options configures the authentication methods. Authentication is disabled if no method
+// is configured.
+type Options struct {
+	// Username and Password of a static basic auth user.
+	Username, Password string
+	// HtpasswdFile is the path of a file with basic auth users and their bcrypt password
+	// hashes in htpasswd format.
+	HtpasswdFile string
+	// OIDC configures the validation of bearer tokens. Disabled if the issuer URL is unset.
+	OIDC JWTOptions
+	// IAPAudience is the expected audience of IAP signed headers. IAP authentication is
+	// disabled if unset.
+	IAPAudience string
+	// RefreshInterval is the interval in which the htpasswd file is reloaded and the keys of
+	// token issuers are refreshed.
+	RefreshInterval time.Duration
+}
+
+// Authenticator authenticates requests and adds their identity to the request context.
+type Authenticator struct {
+	logger   log.Logger
+	opts     Options
+	htpasswd *htpasswd
+	oidc     *jwtVerifier
+	iap      *jwtVerifier
+}
+
+// New creates an authenticator. The htpasswd file is loaded initially.
+func New(logger log.Logger, client *http.Client, opts Options) (*Authenticator, error) {
+	a := &Authenticator{logger: logger, opts: opts}
+	if opts.HtpasswdFile != "" {
+		a.htpasswd = newHtpasswd(opts.HtpasswdFile)
+		if err := a.htpasswd.reload(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OIDC.IssuerURL != "" {
+		if opts.OIDC.UsernameClaim == "" {
+			opts.OIDC.UsernameClaim = "sub"
+		}
+		a.oidc = newJWTVerifier(logger, client, opts.OIDC)
+	}
+	if opts.IAPAudience != "" {
+		a.iap = newJWTVerifier(logger, client, JWTOptions{
+			IssuerURL:     iapIssuer,
+			JWKSURL:       iapJWKSURL,
+			Audiences:     []string{opts.IAPAudience},
+			UsernameClaim: "email",
+		})
+	}
+	return a, nil
+}
+
+func (a *Authenticator) basicEnabled() bool {
+	return (a.opts.Username != "" && a.opts.Password != "") || a.htpasswd != nil
+}
+
+// Enabled returns whether any authentication method is configured.
+func (a *Authenticator) Enabled() bool {
+	return a.basicEnabled() || a.oidc != nil || a.iap != nil
+}
+
+// Run reloads the htpasswd file and refreshes the keys of token issuers until the context
+// is canceled. Failed refreshes keep the previous users and keys.
+func (a *Authenticator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		if a.htpasswd != nil {
+			if err := a.htpasswd.reload(); err != nil {
+				_ = level.Warn(a.logger).Log("msg", "Reloading htpasswd file failed", "err", err)
+			}
+		}
+		for _, v := range []*jwtVerifier{a.oidc, a.iap} {
+			if v == nil {
+				continue
+			}
+			if err := v.keys.refresh(ctx); err != nil {
+				_ = level.Warn(a.logger).Log("msg", "Refreshing token keys failed", "issuer", v.opts.IssuerURL, "err", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Middleware authenticates requests before passing them to next with their identity.
+// Requests without credentials are rejected with 401, requests with invalid basic auth
+// credentials with 403 and requests with invalid tokens with 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, status, err := a.authenticate(req)
+		if err != nil {
+			_ = level.Debug(a.logger).Log("msg", "Authentication failed", "err", err)
+			if status == http.StatusUnauthorized {
+				if a.basicEnabled() {
+					w.Header().Set("WWW-Authenticate", "Basic")
+				} else if a.oidc != nil {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+			}
+			w.WriteHeader(status)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithIdentity(req.Context(), id)))
+	})
+}
+
+func (a *Authenticator) authenticate(req *http.Request) (*Identity, int, error) {
+	if assertion := req.Header.Get(iapHeader); a.iap != nil && assertion != "" {
+		id, err := a.iap.verify(req.Context(), assertion)
+		if err != nil {
+			return nil, http.StatusUnauthorized, err
+		}
+		id.Method = MethodIAP
+		return id, 0, nil
+	}
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); a.oidc != nil && ok {
+		id, err := a.oidc.verify(req.Context(), token)
+		if err != nil {
+			return nil, http.StatusUnauthorized, err
+		}
+		id.Method = MethodOIDC
+		return id, 0, nil
+	}
+	if user, pass, ok := req.BasicAuth(); a.basicEnabled() && ok {
+		if !a.checkBasic(user, pass) {
+			return nil, http.StatusForbidden, errors.New("invalid basic auth credentials")
+		}
+		return &Identity{Method: MethodBasic, User: user}, 0, nil
+	}
+	return nil, http.StatusUnauthorized, errors.New("no credentials")
+}
+
+func (a *Authenticator) checkBasic(user, pass string) bool {
+	if a.opts.Username != "" && a.opts.Password != "" {
+		matchUser := secutil.ConstTimeEqual(user, a.opts.Username)
+		matchPass := secutil.ConstTimeEqual(pass, a.opts.Password)
+		if matchUser && matchPass {
+			return true
+		}
+	}
+	return a.htpasswd != nil && a.htpasswd.authenticate(user, pass)
+}
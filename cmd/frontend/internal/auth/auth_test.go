@@ -0,0 +1,277 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// issuer serves the OpenID configuration and the keys of an RSA and an EC key, alongside
+// keys that are skipped.
+type issuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	iss := &issuer{rsaKey: rsaKey, ecKey: ecKey}
+	enc := base64.RawURLEncoding.EncodeToString
+	ecBytes, err := ecKey.PublicKey.Bytes()
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kid": "rsa", "kty": "RSA", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kid": "ec", "kty": "EC", "crv": "P-256", "x": enc(ecBytes[1:33]), "y": enc(ecBytes[33:])},
+			{"kid": "enc", "kty": "RSA", "use": "enc"},
+			{"kid": "oct", "kty": "oct"},
+			{"kid": "p192", "kty": "EC", "crv": "P-192", "x": "AA", "y": "AA"},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *issuer) token(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	var (
+		token *jwt.Token
+		key   any
+	)
+	switch kid {
+	case "ec":
+		token, key = jwt.NewWithClaims(jwt.SigningMethodES256, claims), iss.ecKey
+	default:
+		token, key = jwt.NewWithClaims(jwt.SigningMethodRS256, claims), iss.rsaKey
+	}
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func writeHtpasswd(t *testing.T, path string, users map[string]string) {
+	t.Helper()
+
+	var content []byte
+	for user, pass := range users {
+		hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+		require.NoError(t, err)
+		content = append(content, []byte(user+":"+string(hash)+"\n")...)
+	}
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+}
+
+func TestAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	iss := newIssuer(t)
+	htpasswdFile := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, htpasswdFile, map[string]string{"alice": "secret"})
+
+	a, err := New(log.NewNopLogger(), iss.Client(), Options{
+		Username:     "admin",
+		Password:     "pass",
+		HtpasswdFile: htpasswdFile,
+		OIDC: JWTOptions{
+			IssuerURL: iss.URL,
+			Audiences: []string{"frontend"},
+		},
+	})
+	require.NoError(t, err)
+
+	var got *Identity
+	handler := a.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got = IdentityFromContext(req.Context())
+	}))
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for _, tt := range []struct {
+		name       string
+		setup      func(req *http.Request)
+		wantStatus int
+		want       *Identity
+	}{
+		{
+			name:       "no credentials",
+			setup:      func(*http.Request) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "static basic auth",
+			setup:      func(req *http.Request) { req.SetBasicAuth("admin", "pass") },
+			wantStatus: http.StatusOK,
+			want:       &Identity{Method: MethodBasic, User: "admin"},
+		},
+		{
+			name:       "htpasswd",
+			setup:      func(req *http.Request) { req.SetBasicAuth("alice", "secret") },
+			wantStatus: http.StatusOK,
+			want:       &Identity{Method: MethodBasic, User: "alice"},
+		},
+		{
+			name:       "wrong password",
+			setup:      func(req *http.Request) { req.SetBasicAuth("alice", "pass") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "RSA token",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "rsa", jwt.MapClaims{"iss": iss.URL, "aud": "frontend", "sub": "bob", "exp": exp}))
+			},
+			wantStatus: http.StatusOK,
+			want:       &Identity{Method: MethodOIDC, User: "bob", Claims: map[string]any{"iss": iss.URL, "aud": "frontend", "sub": "bob", "exp": float64(exp)}},
+		},
+		{
+			name: "EC token",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "ec", jwt.MapClaims{"iss": iss.URL, "aud": []string{"other", "frontend"}, "sub": "carol", "exp": exp}))
+			},
+			wantStatus: http.StatusOK,
+			want:       &Identity{Method: MethodOIDC, User: "carol", Claims: map[string]any{"iss": iss.URL, "aud": []any{"other", "frontend"}, "sub": "carol", "exp": float64(exp)}},
+		},
+		{
+			name: "wrong audience",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "rsa", jwt.MapClaims{"iss": iss.URL, "aud": "other", "sub": "bob", "exp": exp}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong issuer",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "rsa", jwt.MapClaims{"iss": "https://other", "aud": "frontend", "sub": "bob", "exp": exp}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "expired token",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "rsa", jwt.MapClaims{"iss": iss.URL, "aud": "frontend", "sub": "bob", "exp": time.Now().Add(-time.Hour).Unix()}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown key",
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+iss.token(t, "unknown", jwt.MapClaims{"iss": iss.URL, "aud": "frontend", "sub": "bob", "exp": exp}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unconfigured IAP",
+			setup: func(req *http.Request) {
+				req.Header.Set(iapHeader, iss.token(t, "rsa", jwt.MapClaims{"iss": iss.URL, "aud": "frontend", "sub": "bob", "exp": exp}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil)
+			tt.setup(req)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			require.Equal(t, tt.want, got)
+			if tt.wantStatus == http.StatusUnauthorized {
+				require.Equal(t, "Basic", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthenticator_IAP(t *testing.T) {
+	t.Parallel()
+
+	iss := newIssuer(t)
+	a, err := New(log.NewNopLogger(), iss.Client(), Options{IAPAudience: "/projects/1/global/backendServices/2"})
+	require.NoError(t, err)
+	// Verify against the test issuer instead of IAP.
+	a.iap = newJWTVerifier(log.NewNopLogger(), iss.Client(), JWTOptions{
+		IssuerURL:     iss.URL,
+		JWKSURL:       iss.URL + "/keys",
+		Audiences:     []string{"/projects/1/global/backendServices/2"},
+		UsernameClaim: "email",
+	})
+
+	var got *Identity
+	handler := a.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got = IdentityFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(iapHeader, iss.token(t, "ec", jwt.MapClaims{
+		"iss": iss.URL, "aud": "/projects/1/global/backendServices/2", "email": "dave@example.com", "exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, MethodIAP, got.Method)
+	require.Equal(t, "dave@example.com", got.User)
+
+	// Bearer tokens are not accepted without OIDC.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer foo")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticator_Disabled(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(log.NewNopLogger(), http.DefaultClient, Options{Username: "admin"})
+	require.NoError(t, err)
+
+	called := false
+	handler := a.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		called = true
+		require.Nil(t, IdentityFromContext(req.Context()))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.True(t, called)
+}
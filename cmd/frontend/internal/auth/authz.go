@@ -0,0 +1,382 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// AuthorizationConfig maps identities to the values of a label they may query.
+type AuthorizationConfig struct {
+	// Label whose values are restricted, e.g. namespace.
+	Label string `yaml:"label"`
+	// GroupsClaim is the token claim holding the groups of an identity.
+	GroupsClaim string `yaml:"groups_claim,omitempty"`
+	// Tenants grant access to label values. Identities matching several tenants may query
+	// the values of all of them. Identities matching no tenant are denied.
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+// Tenant grants users and groups access to label values.
+type Tenant struct {
+	// Users are the usernames of identities of the tenant.
+	Users []string `yaml:"users,omitempty"`
+	// Groups are the values of the groups claim of identities of the tenant.
+	Groups []string `yaml:"groups,omitempty"`
+	// Values are regular expressions of the label values the tenant may query. They are
+	// fully anchored like PromQL regular expressions.
+	Values []string `yaml:"values,omitempty"`
+	// Unrestricted tenants may query all data.
+	Unrestricted bool `yaml:"unrestricted,omitempty"`
+}
+
+// LoadAuthorizationConfig parses and validates an authorization config.
+func LoadAuthorizationConfig(content []byte) (*AuthorizationConfig, error) {
+	var config AuthorizationConfig
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if !model.LabelName(config.Label).IsValid() {
+		return nil, fmt.Errorf("invalid label %q", config.Label)
+	}
+	for i, t := range config.Tenants {
+		if len(t.Users) == 0 && len(t.Groups) == 0 {
+			return nil, fmt.Errorf("tenant %d: users or groups must be set", i)
+		}
+		if len(t.Groups) > 0 && config.GroupsClaim == "" {
+			return nil, fmt.Errorf("tenant %d: groups require groups_claim to be set", i)
+		}
+		if len(t.Values) == 0 && !t.Unrestricted {
+			return nil, fmt.Errorf("tenant %d: values must be set unless unrestricted", i)
+		}
+		for _, v := range t.Values {
+			// Values must be complete expressions on their own, e.g. `a)|(.*` would otherwise
+			// close its group and match all values.
+			if _, err := regexp.Compile(v); err != nil {
+				return nil, fmt.Errorf("tenant %d: invalid value %q: %w", i, v, err)
+			}
+		}
+		if _, err := labels.NewMatcher(labels.MatchRegexp, config.Label, valuesRegex(t.Values)); err != nil {
+			return nil, fmt.Errorf("tenant %d: invalid values: %w", i, err)
+		}
+	}
+	return &config, nil
+}
+
+// valuesRegex returns the regular expression matching any of the values. Each value is
+// grouped, so that alternations within a value don't apply to the anchors of the matcher.
+func valuesRegex(values []string) string {
+	groups := make([]string, 0, len(values))
+	for _, v := range values {
+		groups = append(groups, "(?:"+v+")")
+	}
+	return strings.Join(groups, "|")
+}
+
+// matcher returns the matcher to add to all selectors of queries of the identity. It returns
+// a nil matcher for unrestricted identities and false for denied identities.
+func (c *AuthorizationConfig) matcher(id *Identity) (*labels.Matcher, bool) {
+	if id == nil {
+		return nil, false
+	}
+	groups := id.groups(c.GroupsClaim)
+
+	var values []string
+	for _, t := range c.Tenants {
+		if !slices.Contains(t.Users, id.User) && !slices.ContainsFunc(t.Groups, func(g string) bool {
+			return slices.Contains(groups, g)
+		}) {
+			continue
+		}
+		if t.Unrestricted {
+			return nil, true
+		}
+		values = append(values, t.Values...)
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	slices.Sort(values)
+	values = slices.Compact(values)
+
+	m, err := labels.NewMatcher(labels.MatchRegexp, c.Label, valuesRegex(values))
+	if err != nil {
+		// Values were validated when loading the config.
+		panic(err)
+	}
+	return m, true
+}
+
+// groups returns the string values of the claim, which may be a single string or a list.
+func (id *Identity) groups(claim string) []string {
+	if claim == "" {
+		return nil
+	}
+	switch v := id.Claims[claim].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var groups []string
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// Authorizer restricts the queries of authenticated identities to the label values of their
+// tenants by adding a label matcher to all selectors.
+type Authorizer struct {
+	logger          log.Logger
+	path            string
+	refreshInterval time.Duration
+
+	mtx     sync.RWMutex
+	content []byte
+	config  *AuthorizationConfig
+}
+
+// NewAuthorizer creates an authorizer with the config of the file. Authorization is disabled
+// if the path is empty.
+func NewAuthorizer(logger log.Logger, path string, refreshInterval time.Duration) (*Authorizer, error) {
+	a := &Authorizer{logger: logger, path: path, refreshInterval: refreshInterval}
+	if path == "" {
+		return a, nil
+	}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Enabled returns whether queries are authorized.
+func (a *Authorizer) Enabled() bool {
+	return a.path != ""
+}
+
+// reload reads the config file and replaces the config if the content changed.
+func (a *Authorizer) reload() error {
+	content, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("read authorization config file: %w", err)
+	}
+	a.mtx.RLock()
+	unchanged := a.config != nil && bytes.Equal(content, a.content)
+	a.mtx.RUnlock()
+	if unchanged {
+		return nil
+	}
+	config, err := LoadAuthorizationConfig(content)
+	if err != nil {
+		return fmt.Errorf("authorization config file %s: %w", a.path, err)
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.content = content
+	a.config = config
+	return nil
+}
+
+// Run reloads the config file until the context is canceled. Failed reloads keep the
+// previous config.
+func (a *Authorizer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := a.reload(); err != nil {
+			_ = level.Warn(a.logger).Log("msg", "Reloading authorization config failed", "err", err)
+		}
+	}
+}
+
+// Middleware authorizes requests of the identity added by the authenticator before passing
+// them to next. Queries and series matchers of restricted identities are rewritten to only
+// select their label values. Requests of restricted identities to APIs that cannot be
+// restricted, and requests of identities without tenant, are rejected with 403.
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		a.mtx.RLock()
+		config := a.config
+		a.mtx.RUnlock()
+
+		id := IdentityFromContext(req.Context())
+		m, ok := config.matcher(id)
+		if !ok {
+			_ = level.Debug(a.logger).Log("msg", "Identity has no tenant", "user", id.username())
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if m == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		var rewrite func(url.Values) error
+		switch p := req.URL.Path; {
+		case p == "/api/v1/query" || p == "/api/v1/query_range" || p == "/api/v1/query_exemplars":
+			rewrite = func(form url.Values) error {
+				query, err := enforceQuery(form.Get("query"), m)
+				if err != nil {
+					return err
+				}
+				form.Set("query", query)
+				return nil
+			}
+		case p == "/api/v1/series" || p == "/api/v1/labels" ||
+			(strings.HasPrefix(p, "/api/v1/label/") && strings.HasSuffix(p, "/values")):
+			rewrite = func(form url.Values) error {
+				selectors, err := enforceSelectors(form["match[]"], m)
+				if err != nil {
+					return err
+				}
+				form["match[]"] = selectors
+				return nil
+			}
+		case p == "/api/v1/metadata" || p == "/api/v1/format_query" || p == "/api/v1/parse_query" ||
+			!strings.HasPrefix(p, "/api/"):
+			// No series data is returned.
+			next.ServeHTTP(w, req)
+			return
+		default:
+			_ = level.Debug(a.logger).Log("msg", "API is not available to restricted identities", "user", id.User, "path", p)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err := rewriteForm(req, rewrite); err != nil {
+			promapi.WriteError(a.logger, w, promapi.ErrorBadData, err.Error(), http.StatusBadRequest, req.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (id *Identity) username() string {
+	if id == nil {
+		return ""
+	}
+	return id.User
+}
+
+// rewriteForm rewrites the URL and form body params of the request. All params are moved
+// into a form body for requests with a body and into the URL otherwise. Bodies that are
+// not URL-encoded forms, e.g. multipart forms, are replaced and their params dropped, as
+// they could otherwise hold params that were not rewritten.
+func rewriteForm(req *http.Request, rewrite func(url.Values) error) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	form := req.Form
+	if err := rewrite(form); err != nil {
+		return err
+	}
+	encoded := form.Encode()
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		req.Body = io.NopCloser(strings.NewReader(encoded))
+		req.ContentLength = int64(len(encoded))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.URL.RawQuery = ""
+	default:
+		req.URL.RawQuery = encoded
+	}
+	// Make handlers parse the rewritten params.
+	req.Form, req.PostForm = nil, nil
+	return nil
+}
+
+// enforceQuery adds the matcher to all selectors of the PromQL query.
+func enforceQuery(query string, m *labels.Matcher) (string, error) {
+	if query == "" {
+		return "", errors.New("query must be set")
+	}
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return "", fmt.Errorf("parse PromQL expression: %w", err)
+	}
+	parser.Inspect(expr, func(n parser.Node, _ []parser.Node) error {
+		if vs, ok := n.(*parser.VectorSelector); ok {
+			setSelector(vs, m)
+		}
+		return nil
+	})
+	return expr.String(), nil
+}
+
+// enforceSelectors adds the matcher to the series selectors. A selector of all series
+// with the matcher's label values is returned if there are none.
+func enforceSelectors(selectors []string, m *labels.Matcher) ([]string, error) {
+	if len(selectors) == 0 {
+		return []string{(&parser.VectorSelector{LabelMatchers: []*labels.Matcher{m}}).String()}, nil
+	}
+	result := make([]string, 0, len(selectors))
+	for _, s := range selectors {
+		expr, err := parser.ParseExpr(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse series selector: %w", err)
+		}
+		vs, ok := expr.(*parser.VectorSelector)
+		if !ok {
+			return nil, fmt.Errorf("invalid series selector %q", s)
+		}
+		setSelector(vs, m)
+		result = append(result, vs.String())
+	}
+	return result, nil
+}
+
+// setSelector adds the matcher to the selector. Existing matchers of the label are kept as
+// all matchers must match.
+func setSelector(vs *parser.VectorSelector, m *labels.Matcher) {
+	for _, existing := range vs.LabelMatchers {
+		if existing.Name == m.Name && existing.Type == m.Type && existing.Value == m.Value {
+			return
+		}
+	}
+	vs.LabelMatchers = append(vs.LabelMatchers, m)
+}
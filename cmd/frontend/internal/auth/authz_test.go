@@ -0,0 +1,314 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuthorizationConfig = `
+label: namespace
+groups_claim: groups
+tenants:
+- users: [alice]
+  groups: [team-a]
+  values: [team-a, shared]
+- groups: [team-b]
+  values: [team-b-.*]
+- users: [admin]
+  unrestricted: true
+`
+
+func TestLoadAuthorizationConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "valid", config: testAuthorizationConfig},
+		{name: "missing label", config: "tenants: [{users: [a], values: [b]}]", wantErr: true},
+		{name: "unknown field", config: "label: namespace\nteams: []", wantErr: true},
+		{name: "no users or groups", config: "label: namespace\ntenants: [{values: [b]}]", wantErr: true},
+		{name: "no groups claim", config: "label: namespace\ntenants: [{groups: [a], values: [b]}]", wantErr: true},
+		{name: "no values", config: "label: namespace\ntenants: [{users: [a]}]", wantErr: true},
+		{name: "invalid value", config: "label: namespace\ntenants: [{users: [a], values: ['(']}]", wantErr: true},
+		{name: "value closing its group", config: "label: namespace\ntenants: [{users: [a], values: ['a)|(.*']}]", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadAuthorizationConfig([]byte(tt.config))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthorizationConfig_Matcher(t *testing.T) {
+	t.Parallel()
+
+	config, err := LoadAuthorizationConfig([]byte(testAuthorizationConfig))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		id     *Identity
+		want   string
+		wantOK bool
+	}{
+		{name: "no identity"},
+		{name: "unknown user", id: &Identity{User: "bob"}},
+		{name: "user", id: &Identity{User: "alice"}, want: `namespace=~"(?:shared)|(?:team-a)"`, wantOK: true},
+		{
+			name:   "groups",
+			id:     &Identity{User: "bob", Claims: map[string]any{"groups": []any{"team-a", "team-b"}}},
+			want:   `namespace=~"(?:shared)|(?:team-a)|(?:team-b-.*)"`,
+			wantOK: true,
+		},
+		{
+			name:   "single group",
+			id:     &Identity{User: "bob", Claims: map[string]any{"groups": "team-b"}},
+			want:   `namespace=~"(?:team-b-.*)"`,
+			wantOK: true,
+		},
+		{name: "unrestricted", id: &Identity{User: "admin"}, wantOK: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := config.matcher(tt.id)
+			require.Equal(t, tt.wantOK, ok)
+			if tt.want == "" {
+				require.Nil(t, m)
+				return
+			}
+			require.Equal(t, tt.want, m.String())
+		})
+	}
+}
+
+func TestAuthorizer_Middleware(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "authz.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testAuthorizationConfig), 0o600))
+	a, err := NewAuthorizer(log.NewNopLogger(), path, time.Minute)
+	require.NoError(t, err)
+
+	var got url.Values
+	handler := a.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		// Parse params like the Prometheus API, which also reads multipart forms.
+		if err := req.ParseMultipartForm(1 << 20); !errors.Is(err, http.ErrNotMultipart) {
+			require.NoError(t, err)
+		}
+		got = req.Form
+	}))
+
+	for _, tt := range []struct {
+		name       string
+		user       string
+		method     string
+		path       string
+		params     url.Values
+		multipart  url.Values
+		wantStatus int
+		want       url.Values
+	}{
+		{
+			name:       "query",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/query",
+			params:     url.Values{"query": {`sum by (pod) (rate(foo{job="a"}[5m])) / on () group_left bar offset 1h`}, "time": {"1"}},
+			wantStatus: http.StatusOK,
+			want: url.Values{
+				"query": {`sum by (pod) (rate(foo{job="a",namespace=~"(?:shared)|(?:team-a)"}[5m])) / on () group_left () bar{namespace=~"(?:shared)|(?:team-a)"} offset 1h`},
+				"time":  {"1"},
+			},
+		},
+		{
+			name:       "range query form",
+			user:       "alice",
+			method:     http.MethodPost,
+			path:       "/api/v1/query_range",
+			params:     url.Values{"query": {`foo{namespace="team-b-1"}`}, "step": {"15"}},
+			wantStatus: http.StatusOK,
+			want:       url.Values{"query": {`foo{namespace="team-b-1",namespace=~"(?:shared)|(?:team-a)"}`}, "step": {"15"}},
+		},
+		{
+			name:       "multipart query",
+			user:       "alice",
+			method:     http.MethodPost,
+			path:       "/api/v1/query",
+			multipart:  url.Values{"query": {`foo`}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multipart query with URL query",
+			user:       "alice",
+			method:     http.MethodPost,
+			path:       "/api/v1/query?query=foo",
+			multipart:  url.Values{"query": {`bar`}, "time": {"1"}},
+			wantStatus: http.StatusOK,
+			want:       url.Values{"query": {`foo{namespace=~"(?:shared)|(?:team-a)"}`}},
+		},
+		{
+			name:       "invalid query",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/query",
+			params:     url.Values{"query": {`foo{`}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "series",
+			user:       "alice",
+			method:     http.MethodPost,
+			path:       "/api/v1/series",
+			params:     url.Values{"match[]": {`foo`, `{__name__=~"bar.*"}`}},
+			wantStatus: http.StatusOK,
+			want:       url.Values{"match[]": {`foo{namespace=~"(?:shared)|(?:team-a)"}`, `{__name__=~"bar.*",namespace=~"(?:shared)|(?:team-a)"}`}},
+		},
+		{
+			name:       "label values without match",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/label/job/values",
+			wantStatus: http.StatusOK,
+			want:       url.Values{"match[]": {`{namespace=~"(?:shared)|(?:team-a)"}`}},
+		},
+		{
+			name:       "invalid match",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/labels",
+			params:     url.Values{"match[]": {`sum(foo)`}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "metadata",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/metadata",
+			params:     url.Values{"metric": {"foo"}},
+			wantStatus: http.StatusOK,
+			want:       url.Values{"metric": {"foo"}},
+		},
+		{
+			name:       "rules",
+			user:       "alice",
+			method:     http.MethodGet,
+			path:       "/api/v1/rules",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "unrestricted",
+			user:       "admin",
+			method:     http.MethodGet,
+			path:       "/api/v1/query",
+			params:     url.Values{"query": {`foo`}},
+			wantStatus: http.StatusOK,
+			want:       url.Values{"query": {`foo`}},
+		},
+		{
+			name:       "no tenant",
+			user:       "bob",
+			method:     http.MethodGet,
+			path:       "/api/v1/query",
+			params:     url.Values{"query": {`foo`}},
+			wantStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			var req *http.Request
+			if tt.multipart != nil {
+				var body bytes.Buffer
+				mw := multipart.NewWriter(&body)
+				for k, vs := range tt.multipart {
+					for _, v := range vs {
+						require.NoError(t, mw.WriteField(k, v))
+					}
+				}
+				require.NoError(t, mw.Close())
+				req = httptest.NewRequest(tt.method, tt.path, &body)
+				req.Header.Set("Content-Type", mw.FormDataContentType())
+			} else if tt.method == http.MethodPost {
+				req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(tt.method, tt.path+"?"+tt.params.Encode(), nil)
+			}
+			req = req.WithContext(WithIdentity(req.Context(), &Identity{Method: MethodBasic, User: tt.user}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "authz.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testAuthorizationConfig), 0o600))
+	a, err := NewAuthorizer(log.NewNopLogger(), path, time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("label: namespace\ntenants: [{users: [bob], values: [b]}]"), 0o600))
+	require.NoError(t, a.reload())
+	m, ok := a.config.matcher(&Identity{User: "bob"})
+	require.True(t, ok)
+	require.Equal(t, `namespace=~"(?:b)"`, m.String())
+
+	// Invalid configs keep the previous config.
+	require.NoError(t, os.WriteFile(path, []byte("label: ''"), 0o600))
+	require.Error(t, a.reload())
+	_, ok = a.config.matcher(&Identity{User: "bob"})
+	require.True(t, ok)
+}
+
+func TestAuthorizer_Disabled(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewAuthorizer(log.NewNopLogger(), "", time.Minute)
+	require.NoError(t, err)
+	require.False(t, a.Enabled())
+
+	called := false
+	handler := a.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/rules", nil))
+	require.True(t, called)
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswd holds the basic auth users of an htpasswd file. Only bcrypt hashes are supported.
+type htpasswd struct {
+	path string
+
+	mtx     sync.RWMutex
+	content []byte
+	users   map[string][]byte
+	// verified holds the password digests of users that were verified against their hash,
+	// as bcrypt is too slow to run on every request.
+	verified map[string][sha256.Size]byte
+}
+
+func newHtpasswd(path string) *htpasswd {
+	return &htpasswd{path: path}
+}
+
+// reload reads the file and replaces the users if the content changed.
+func (h *htpasswd) reload() error {
+	content, err := os.ReadFile(h.path)
+	if err != nil {
+		return fmt.Errorf("read htpasswd file: %w", err)
+	}
+	h.mtx.RLock()
+	unchanged := h.users != nil && bytes.Equal(content, h.content)
+	h.mtx.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	users := map[string][]byte{}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return fmt.Errorf("htpasswd file %s line %d: expected <user>:<hash>", h.path, i+1)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("htpasswd file %s line %d: only bcrypt hashes are supported: %w", h.path, i+1, err)
+		}
+		users[user] = []byte(hash)
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.content = content
+	h.users = users
+	h.verified = map[string][sha256.Size]byte{}
+	return nil
+}
+
+func (h *htpasswd) authenticate(user, pass string) bool {
+	digest := sha256.Sum256([]byte(pass))
+
+	h.mtx.RLock()
+	hash, ok := h.users[user]
+	verified, cached := h.verified[user]
+	h.mtx.RUnlock()
+	if !ok {
+		return false
+	}
+	if cached && subtle.ConstantTimeCompare(digest[:], verified[:]) == 1 {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(pass)) != nil {
+		return false
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	// Skip caching if the file was reloaded in the meantime.
+	if current, ok := h.users[user]; ok && bytes.Equal(current, hash) {
+		h.verified[user] = digest
+	}
+	return true
+}
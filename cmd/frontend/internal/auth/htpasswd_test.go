@@ -0,0 +1,54 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHtpasswd_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, map[string]string{"alice": "secret", "bob": "hunter2"})
+
+	h := newHtpasswd(path)
+	require.NoError(t, h.reload())
+	require.True(t, h.authenticate("alice", "secret"))
+	// Cached verification.
+	require.True(t, h.authenticate("alice", "secret"))
+	require.False(t, h.authenticate("alice", "hunter2"))
+	require.True(t, h.authenticate("bob", "hunter2"))
+	require.False(t, h.authenticate("carol", "secret"))
+
+	writeHtpasswd(t, path, map[string]string{"alice": "changed"})
+	require.NoError(t, h.reload())
+	require.False(t, h.authenticate("alice", "secret"))
+	require.True(t, h.authenticate("alice", "changed"))
+	require.False(t, h.authenticate("bob", "hunter2"))
+
+	// Invalid files keep the previous users.
+	require.NoError(t, os.WriteFile(path, []byte("# comment\nalice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0o600))
+	require.Error(t, h.reload())
+	require.True(t, h.authenticate("alice", "changed"))
+
+	require.NoError(t, os.Remove(path))
+	require.Error(t, h.reload())
+	require.True(t, h.authenticate("alice", "changed"))
+}
@@ -0,0 +1,268 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minKeyRefreshInterval limits how often keys are refreshed because of tokens signed with
+// unknown keys.
+const minKeyRefreshInterval = 30 * time.Second
+
+// JWTOptions configures the validation of JWTs of an issuer.
+type JWTOptions struct {
+	// IssuerURL is the expected issuer of tokens.
+	IssuerURL string
+	// JWKSURL is the URL of the JSON Web Key Set of the issuer. Discovered through the OpenID
+	// configuration of the issuer if unset.
+	JWKSURL string
+	// Audiences of which tokens must have at least one.
+	Audiences []string
+	// UsernameClaim is the claim holding the username of the identity.
+	UsernameClaim string
+}
+
+type jwtVerifier struct {
+	opts   JWTOptions
+	parser *jwt.Parser
+	keys   *jwks
+}
+
+func newJWTVerifier(logger log.Logger, client *http.Client, opts JWTOptions) *jwtVerifier {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(opts.IssuerURL),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if len(opts.Audiences) > 0 {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audiences...))
+	}
+	return &jwtVerifier{
+		opts:   opts,
+		parser: jwt.NewParser(parserOpts...),
+		keys:   &jwks{logger: logger, client: client, issuerURL: opts.IssuerURL, url: opts.JWKSURL, keys: map[string]any{}},
+	}
+}
+
+// verify validates the signature and claims of the token.
+func (v *jwtVerifier) verify(ctx context.Context, raw string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	user, _ := claims[v.opts.UsernameClaim].(string)
+	if user == "" {
+		return nil, fmt.Errorf("token has no %q claim", v.opts.UsernameClaim)
+	}
+	return &Identity{User: user, Claims: claims}, nil
+}
+
+// jwks holds the public keys of an issuer by key ID.
+type jwks struct {
+	logger    log.Logger
+	client    *http.Client
+	issuerURL string
+
+	mtx         sync.RWMutex
+	url         string
+	keys        map[string]any
+	lastRefresh time.Time
+}
+
+// key returns the key with the ID. Keys are refreshed if the key is unknown. Tokens without
+// key ID are accepted if the issuer has a single key.
+func (k *jwks) key(ctx context.Context, kid string) (any, error) {
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+	k.mtx.RLock()
+	recent := time.Since(k.lastRefresh) < minKeyRefreshInterval
+	k.mtx.RUnlock()
+	if !recent {
+		if err := k.refresh(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok := k.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (k *jwks) lookup(kid string) (any, bool) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the keys of the issuer.
+func (k *jwks) refresh(ctx context.Context) error {
+	k.mtx.Lock()
+	k.lastRefresh = time.Now()
+	url := k.url
+	k.mtx.Unlock()
+
+	if url == "" {
+		var config struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := k.get(ctx, strings.TrimSuffix(k.issuerURL, "/")+"/.well-known/openid-configuration", &config); err != nil {
+			return fmt.Errorf("get OpenID configuration: %w", err)
+		}
+		if config.JWKSURI == "" {
+			return errors.New("OpenID configuration has no jwks_uri")
+		}
+		url = config.JWKSURI
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := k.get(ctx, url, &set); err != nil {
+		return fmt.Errorf("get JSON Web Key Set: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// A single invalid key, e.g. of a curve the issuer added support for, must not
+		// prevent the use of the other keys.
+		key, err := jwk.publicKey()
+		if err != nil {
+			_ = level.Warn(k.logger).Log("msg", "Skipping invalid token key", "issuer", k.issuerURL, "kid", jwk.Kid, "err", err)
+			continue
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.url = url
+	k.keys = keys
+	return nil
+}
+
+func (k *jwks) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jsonWebKey is a public RSA or EC key as defined in RFC 7517.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the key, or nil if the key type is not supported.
+func (k *jsonWebKey) publicKey() (any, error) {
+	decode := func(s string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode modulus: %w", err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid point")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, nil
+}
@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Cache stores the responses of range query splits. Failures of the cache are treated as
+// cache misses.
+type Cache interface {
+	Fetch(ctx context.Context, key string) ([]byte, bool)
+	Store(ctx context.Context, key string, value []byte)
+}
+
+// LRUCache is an in-memory cache evicting the least recently used entries when exceeding its
+// maximum size.
+type LRUCache struct {
+	maxBytes int
+
+	mtx     sync.Mutex
+	size    int
+	entries *list.List
+	byKey   map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+func (e *lruEntry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+// NewLRUCache creates an in-memory cache holding at most maxBytes of keys and values.
+func NewLRUCache(maxBytes int) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		entries:  list.New(),
+		byKey:    map[string]*list.Element{},
+	}
+}
+
+// Fetch returns the value of the key.
+func (c *LRUCache) Fetch(_ context.Context, key string) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// Store sets the value of the key and evicts the least recently used entries to stay within
+// the maximum size. Values larger than the maximum size are not stored.
+func (c *LRUCache) Store(_ context.Context, key string, value []byte) {
+	entry := &lruEntry{key: key, value: value}
+	if entry.size() > c.maxBytes {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if el, ok := c.byKey[key]; ok {
+		c.remove(el)
+	}
+	c.byKey[key] = c.entries.PushFront(entry)
+	c.size += entry.size()
+	for c.size > c.maxBytes {
+		c.remove(c.entries.Back())
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	entry := c.entries.Remove(el).(*lruEntry)
+	delete(c.byKey, entry.key)
+	c.size -= entry.size()
+}
+
+// maxIdleConns is the number of idle connections kept per memcached server.
+const maxIdleConns = 8
+
+// MemcachedCache stores values in memcached servers using the text protocol. Keys are
+// distributed across the servers by their hash.
+type MemcachedCache struct {
+	logger  log.Logger
+	ttl     time.Duration
+	timeout time.Duration
+	servers []*memcachedServer
+}
+
+type memcachedServer struct {
+	addr string
+	idle chan net.Conn
+}
+
+// NewMemcachedCache creates a cache storing values in the memcached servers at the given
+// addresses for ttl. Each operation times out after timeout.
+func NewMemcachedCache(logger log.Logger, addrs []string, ttl, timeout time.Duration) (*MemcachedCache, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no memcached addresses")
+	}
+	c := &MemcachedCache{
+		logger:  logger,
+		ttl:     ttl,
+		timeout: timeout,
+	}
+	for _, addr := range addrs {
+		c.servers = append(c.servers, &memcachedServer{addr: addr, idle: make(chan net.Conn, maxIdleConns)})
+	}
+	return c, nil
+}
+
+// Fetch returns the value of the key.
+func (c *MemcachedCache) Fetch(ctx context.Context, key string) ([]byte, bool) {
+	var value []byte
+	err := c.do(ctx, key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "get %s\r\n", key); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		n, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rw, buf); err != nil {
+			return err
+		}
+		if line, err := readLine(rw.Reader); err != nil || line != "END" {
+			return fmt.Errorf("unexpected end of response %q: %w", line, err)
+		}
+		value = buf[:n]
+		return nil
+	})
+	if err != nil {
+		_ = level.Debug(c.logger).Log("msg", "Fetching from memcached failed", "err", err)
+		return nil, false
+	}
+	return value, value != nil
+}
+
+// Store sets the value of the key.
+func (c *MemcachedCache) Store(ctx context.Context, key string, value []byte) {
+	err := c.do(ctx, key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "set %s 0 %d %d\r\n", key, int(c.ttl.Seconds()), len(value)); err != nil {
+			return err
+		}
+		if _, err := rw.Write(value); err != nil {
+			return err
+		}
+		if _, err := rw.WriteString("\r\n"); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line != "STORED" {
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = level.Debug(c.logger).Log("msg", "Storing in memcached failed", "err", err)
+	}
+}
+
+// do runs the operation on a connection to the server of the key. Connections are only
+// reused after successful operations.
+func (c *MemcachedCache) do(ctx context.Context, key string, op func(*bufio.ReadWriter) error) error {
+	s := c.servers[xxhash.Sum64String(key)%uint64(len(c.servers))]
+
+	var conn net.Conn
+	select {
+	case conn = <-s.idle:
+	default:
+		var err error
+		d := net.Dialer{Timeout: c.timeout}
+		if conn, err = d.DialContext(ctx, "tcp", s.addr); err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := op(bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))); err != nil {
+		conn.Close()
+		return err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
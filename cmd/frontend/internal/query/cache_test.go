@@ -0,0 +1,149 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+
+	// Every entry has a size of 4 bytes.
+	c := NewLRUCache(12)
+	c.Store(t.Context(), "k1", []byte("v1"))
+	c.Store(t.Context(), "k2", []byte("v2"))
+	c.Store(t.Context(), "k3", []byte("v3"))
+
+	_, ok := c.Fetch(t.Context(), "k1")
+	require.True(t, ok)
+
+	c.Store(t.Context(), "k4", []byte("v4"))
+	_, ok = c.Fetch(t.Context(), "k2")
+	require.False(t, ok, "least recently used entry must be evicted")
+
+	for _, key := range []string{"k1", "k3", "k4"} {
+		v, ok := c.Fetch(t.Context(), key)
+		require.True(t, ok, key)
+		require.Equal(t, "v"+key[1:], string(v))
+	}
+
+	c.Store(t.Context(), "big", []byte("too large value"))
+	_, ok = c.Fetch(t.Context(), "big")
+	require.False(t, ok)
+	require.Equal(t, 12, c.size)
+}
+
+// fakeMemcached serves the get and set commands of the memcached text protocol.
+func fakeMemcached(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	var (
+		mtx   sync.Mutex
+		items = map[string][]byte{}
+	)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := readLine(r)
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "get":
+				mtx.Lock()
+				v, ok := items[fields[1]]
+				mtx.Unlock()
+				if ok {
+					fmt.Fprintf(conn, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(v), v)
+				}
+				fmt.Fprint(conn, "END\r\n")
+			case "set":
+				n, _ := strconv.Atoi(fields[4])
+				buf := make([]byte, n+2)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return
+				}
+				mtx.Lock()
+				items[fields[1]] = buf[:n]
+				mtx.Unlock()
+				fmt.Fprint(conn, "STORED\r\n")
+			default:
+				fmt.Fprint(conn, "ERROR\r\n")
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestMemcachedCache(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewMemcachedCache(log.NewNopLogger(), []string{fakeMemcached(t), fakeMemcached(t)}, time.Hour, time.Second)
+	require.NoError(t, err)
+
+	_, ok := c.Fetch(t.Context(), "missing")
+	require.False(t, ok)
+
+	for i := range 10 {
+		c.Store(t.Context(), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value\r\n%d", i)))
+	}
+	for i := range 10 {
+		v, ok := c.Fetch(t.Context(), fmt.Sprintf("key%d", i))
+		require.True(t, ok)
+		require.Equal(t, fmt.Sprintf("value\r\n%d", i), string(v))
+	}
+}
+
+func TestMemcachedCache_Unavailable(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	c, err := NewMemcachedCache(log.NewNopLogger(), []string{addr}, time.Hour, time.Second)
+	require.NoError(t, err)
+	c.Store(t.Context(), "key", []byte("value"))
+	_, ok := c.Fetch(t.Context(), "key")
+	require.False(t, ok)
+}
@@ -0,0 +1,360 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package query provides a middleware splitting range queries into smaller queries whose
+// results are cached.
+package query
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// SplitOptions configures how range queries are split and cached.
+type SplitOptions struct {
+	// Interval along whose boundaries range queries are split, e.g. 24h for days.
+	Interval time.Duration
+	// MaxParallelism is the maximum number of splits of a query that are run in parallel.
+	MaxParallelism int
+	// MaxFreshness is the time before now after which the results of splits are not cached,
+	// as they may still change, e.g. due to late samples.
+	MaxFreshness time.Duration
+}
+
+type splitMetrics struct {
+	splits    prometheus.Counter
+	cacheHits prometheus.Counter
+}
+
+func newSplitMetrics(reg prometheus.Registerer) *splitMetrics {
+	m := &splitMetrics{
+		splits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frontend_query_range_splits_total",
+			Help: "Number of queries that range queries were split into.",
+		}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frontend_query_range_cache_hits_total",
+			Help: "Number of range query splits whose result was served from the cache.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.splits, m.cacheHits)
+	}
+	return m
+}
+
+// Splitter is a middleware that splits range queries along interval boundaries, runs the
+// splits in parallel and merges their results. Start and end of queries are aligned to
+// their step, so that the splits of repeated queries are identical and results of splits
+// that are older than the maximum freshness can be cached.
+type Splitter struct {
+	logger  log.Logger
+	next    http.Handler
+	cache   Cache
+	opts    SplitOptions
+	metrics *splitMetrics
+	now     func() time.Time
+}
+
+// NewSplitter creates a new splitter for range queries forwarded to next. No results are
+// cached if cache is nil.
+func NewSplitter(logger log.Logger, next http.Handler, cache Cache, opts SplitOptions, reg prometheus.Registerer) *Splitter {
+	return &Splitter{
+		logger:  logger,
+		next:    next,
+		cache:   cache,
+		opts:    opts,
+		metrics: newSplitMetrics(reg),
+		now:     time.Now,
+	}
+}
+
+// split is the time range of a split in milliseconds.
+type split struct {
+	start, end int64
+}
+
+func (s *Splitter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		s.next.ServeHTTP(w, req)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		promapi.WriteError(s.logger, w, promapi.ErrorBadData, err.Error(), http.StatusBadRequest, req.URL.Path)
+		return
+	}
+	startTime, err := promapi.ParseTime(req.Form.Get("start"))
+	if err != nil {
+		promapi.WriteError(s.logger, w, promapi.ErrorBadData, fmt.Sprintf("invalid parameter \"start\": %s", err), http.StatusBadRequest, req.URL.Path)
+		return
+	}
+	endTime, err := promapi.ParseTime(req.Form.Get("end"))
+	if err != nil {
+		promapi.WriteError(s.logger, w, promapi.ErrorBadData, fmt.Sprintf("invalid parameter \"end\": %s", err), http.StatusBadRequest, req.URL.Path)
+		return
+	}
+	stepDuration, err := promapi.ParseDuration(req.Form.Get("step"))
+	if err != nil || stepDuration.Milliseconds() <= 0 {
+		// Leave the validation of the step to the query backend.
+		s.forward(w, req, req.Form)
+		return
+	}
+	start, end, step := startTime.UnixMilli(), endTime.UnixMilli(), stepDuration.Milliseconds()
+	if end < start {
+		promapi.WriteError(s.logger, w, promapi.ErrorBadData, "end timestamp must not be before start time", http.StatusBadRequest, req.URL.Path)
+		return
+	}
+	start, end = start-start%step, end-end%step
+	query, err := resolveStartEnd(req.Form.Get("query"), start, end)
+	if err != nil {
+		// Leave the validation of the query to the query backend.
+		s.forward(w, req, req.Form)
+		return
+	}
+	req.Form.Set("query", query)
+
+	splits := splitRange(start, end, step, s.opts.Interval.Milliseconds())
+	s.metrics.splits.Add(float64(len(splits)))
+
+	type splitResult struct {
+		resp *promapi.Response[promapi.QueryRangeResponseData]
+		// err is the response of the query backend if the split failed.
+		err *responseBuffer
+	}
+	var (
+		results = make([]splitResult, len(splits))
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, max(s.opts.MaxParallelism, 1))
+	)
+	for i, sp := range splits {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i].resp, results[i].err = s.query(req, sp, step)
+		})
+	}
+	wg.Wait()
+
+	var (
+		merged   = map[string]*model.SampleStream{}
+		order    []string
+		warnings []string
+		infos    []string
+	)
+	for _, r := range results {
+		if r.err != nil {
+			copyResponse(w, r.err)
+			return
+		}
+		for _, ss := range r.resp.Data.Result {
+			key := ss.Metric.String()
+			m, ok := merged[key]
+			if !ok {
+				merged[key] = ss
+				order = append(order, key)
+				continue
+			}
+			m.Values = append(m.Values, ss.Values...)
+			m.Histograms = append(m.Histograms, ss.Histograms...)
+		}
+		warnings = append(warnings, r.resp.Warnings...)
+		infos = append(infos, r.resp.Infos...)
+	}
+	slices.Sort(order)
+	data := promapi.QueryRangeResponseData{ResultType: model.ValMatrix, Result: make(model.Matrix, 0, len(order))}
+	for _, key := range order {
+		data.Result = append(data.Result, merged[key])
+	}
+	slices.Sort(warnings)
+	slices.Sort(infos)
+	promapi.WriteQueryRangeResponse(s.logger, w, req.URL.Path, data, slices.Compact(warnings), slices.Compact(infos))
+}
+
+// resolveStartEnd replaces the start() and end() of @ modifiers in the query with the start
+// and end of the range query in milliseconds, as splits would resolve them to their own
+// range. Queries without them are returned unchanged.
+func resolveStartEnd(query string, start, end int64) (string, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return "", err
+	}
+	resolve := func(startOrEnd *parser.ItemType, ts **int64) bool {
+		switch *startOrEnd {
+		case parser.START:
+			*ts = &start
+		case parser.END:
+			*ts = &end
+		default:
+			return false
+		}
+		*startOrEnd = 0
+		return true
+	}
+	resolved := false
+	parser.Inspect(expr, func(n parser.Node, _ []parser.Node) error {
+		switch n := n.(type) {
+		case *parser.VectorSelector:
+			resolved = resolve(&n.StartOrEnd, &n.Timestamp) || resolved
+		case *parser.SubqueryExpr:
+			resolved = resolve(&n.StartOrEnd, &n.Timestamp) || resolved
+		}
+		return nil
+	})
+	if !resolved {
+		return query, nil
+	}
+	return expr.String(), nil
+}
+
+// query returns the result of the split from the cache or the query backend. The response
+// of the query backend is returned if the query failed.
+func (s *Splitter) query(req *http.Request, sp split, step int64) (*promapi.Response[promapi.QueryRangeResponseData], *responseBuffer) {
+	params := url.Values{}
+	for k, v := range req.Form {
+		params[k] = v
+	}
+	params.Set("start", formatMillis(sp.start))
+	params.Set("end", formatMillis(sp.end))
+	params.Set("step", formatMillis(step))
+
+	cacheable := s.cache != nil && sp.end < s.now().Add(-s.opts.MaxFreshness).UnixMilli()
+	key := cacheKey(params)
+	if cacheable {
+		if body, ok := s.cache.Fetch(req.Context(), key); ok {
+			var resp promapi.Response[promapi.QueryRangeResponseData]
+			err := json.Unmarshal(body, &resp)
+			if err == nil {
+				s.metrics.cacheHits.Inc()
+				return &resp, nil
+			}
+			_ = level.Warn(s.logger).Log("msg", "Decoding cached range query result failed", "err", err)
+		}
+	}
+
+	buf := &responseBuffer{header: http.Header{}}
+	s.forward(buf, req, params)
+	if buf.status != http.StatusOK {
+		return nil, buf
+	}
+	var resp promapi.Response[promapi.QueryRangeResponseData]
+	if err := json.Unmarshal(buf.body.Bytes(), &resp); err != nil {
+		return nil, errorResponse(promapi.ErrorInternal, fmt.Sprintf("decoding range query result: %s", err), http.StatusBadGateway)
+	}
+	if resp.Data.ResultType != model.ValMatrix {
+		return nil, errorResponse(promapi.ErrorInternal, fmt.Sprintf("unexpected range query result type %q", resp.Data.ResultType), http.StatusBadGateway)
+	}
+	// Warnings may hint at incomplete results.
+	if cacheable && len(resp.Warnings) == 0 {
+		s.cache.Store(req.Context(), key, buf.body.Bytes())
+	}
+	return &resp, nil
+}
+
+// forward sends a GET request with the given parameters to the next handler.
+func (s *Splitter) forward(w http.ResponseWriter, req *http.Request, params url.Values) {
+	r := req.Clone(req.Context())
+	r.Method = http.MethodGet
+	r.URL.RawQuery = params.Encode()
+	r.Body = http.NoBody
+	r.ContentLength = 0
+	r.Form, r.PostForm = nil, nil
+	r.Header.Del("Content-Type")
+	r.Header.Del("Content-Length")
+	// Let the transport of the next handler negotiate and decode compressed responses.
+	r.Header.Del("Accept-Encoding")
+	s.next.ServeHTTP(w, r)
+}
+
+// splitRange splits the step-aligned range into splits that do not cross interval
+// boundaries. Every split starts and ends on a step.
+func splitRange(start, end, step, interval int64) []split {
+	if interval <= 0 {
+		return []split{{start: start, end: end}}
+	}
+	var splits []split
+	for t := start; t <= end; {
+		boundary := (t/interval + 1) * interval
+		if t < 0 && t%interval != 0 {
+			boundary -= interval
+		}
+		e := min(t+(boundary-1-t)/step*step, end)
+		splits = append(splits, split{start: t, end: e})
+		t = e + step
+	}
+	return splits
+}
+
+func cacheKey(params url.Values) string {
+	h := sha256.Sum256([]byte(params.Encode()))
+	return "query_range:" + hex.EncodeToString(h[:])
+}
+
+func formatMillis(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
+
+// responseBuffer records the response of the next handler.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+func errorResponse(errType promapi.ErrorType, msg string, status int) *responseBuffer {
+	buf := &responseBuffer{header: http.Header{}}
+	promapi.WriteError(log.NewNopLogger(), buf, errType, msg, status, "")
+	return buf
+}
+
+func copyResponse(w http.ResponseWriter, buf *responseBuffer) {
+	for k, v := range buf.header {
+		w.Header()[k] = v
+	}
+	if buf.status == 0 {
+		buf.status = http.StatusOK
+	}
+	w.WriteHeader(buf.status)
+	_, _ = w.Write(buf.body.Bytes())
+}
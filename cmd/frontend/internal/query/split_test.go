@@ -0,0 +1,250 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRange(t *testing.T) {
+	t.Parallel()
+
+	const day = int64(24 * time.Hour / time.Millisecond)
+	for _, tt := range []struct {
+		name                   string
+		start, end, step, intv int64
+		want                   []split
+	}{
+		{
+			name: "within interval",
+			// 10:00 to 12:00 with 1h step.
+			start: 10 * 3600e3, end: 12 * 3600e3, step: 3600e3, intv: day,
+			want: []split{{10 * 3600e3, 12 * 3600e3}},
+		},
+		{
+			name:  "across intervals",
+			start: day - 7200e3, end: 2*day + 3600e3, step: 3600e3, intv: day,
+			want: []split{{day - 7200e3, day - 3600e3}, {day, 2*day - 3600e3}, {2 * day, 2*day + 3600e3}},
+		},
+		{
+			name: "step not dividing interval",
+			// Steps of 7h from 14:00.
+			start: 14 * 3600e3, end: 42 * 3600e3, step: 7 * 3600e3, intv: day,
+			want: []split{{14 * 3600e3, 21 * 3600e3}, {28 * 3600e3, 42 * 3600e3}},
+		},
+		{
+			name:  "step larger than interval",
+			start: 0, end: 4 * day, step: 2 * day, intv: day,
+			want: []split{{0, 0}, {2 * day, 2 * day}, {4 * day, 4 * day}},
+		},
+		{
+			name:  "negative times",
+			start: -2 * 3600e3, end: 3600e3, step: 3600e3, intv: day,
+			want: []split{{-2 * 3600e3, -3600e3}, {0, 3600e3}},
+		},
+		{
+			name:  "no interval",
+			start: 0, end: 4 * day, step: 3600e3,
+			want: []split{{0, 4 * day}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, splitRange(tt.start, tt.end, tt.step, tt.intv))
+		})
+	}
+}
+
+// backend answers range queries with one sample per step of the series {a="1"} and, for
+// queries starting on the second day, of the series {a="2"}.
+type backend struct {
+	mtx     sync.Mutex
+	queries []url.Values
+}
+
+func (b *backend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.mtx.Lock()
+	b.queries = append(b.queries, req.URL.Query())
+	b.mtx.Unlock()
+
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := req.URL.Query()
+	if q.Get("query") == "invalid" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		return
+	}
+	startTime, _ := promapi.ParseTime(q.Get("start"))
+	endTime, _ := promapi.ParseTime(q.Get("end"))
+	stepDuration, _ := promapi.ParseDuration(q.Get("step"))
+	start, end, step := startTime.UnixMilli(), endTime.UnixMilli(), stepDuration.Milliseconds()
+	var values []string
+	for t := start; t <= end; t += step {
+		values = append(values, fmt.Sprintf(`[%s,"%d"]`, formatMillis(t), t/1000))
+	}
+	result := fmt.Sprintf(`{"metric":{"a":"1"},"values":[%s]}`, strings.Join(values, ","))
+	if start >= 24*3600e3 {
+		result += fmt.Sprintf(`,{"metric":{"a":"2"},"values":[%s]}`, strings.Join(values, ","))
+	}
+	fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[%s]},"warnings":["w"]}`, result)
+}
+
+func (b *backend) reset() []url.Values {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	q := b.queries
+	b.queries = nil
+	return q
+}
+
+func TestSplitter(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{}
+	s := NewSplitter(log.NewNopLogger(), b, NewLRUCache(1<<20), SplitOptions{
+		Interval:       24 * time.Hour,
+		MaxParallelism: 2,
+		MaxFreshness:   time.Hour,
+	}, nil)
+	s.now = func() time.Time { return time.Unix(2*24*3600, 0) }
+
+	// Day 0 22:00 to day 1 02:00. The unaligned start is aligned to the step.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/query_range", strings.NewReader("query=up&start=79300&end=1970-01-02T02:00:00Z&step=1h"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"success","data":{"resultType":"matrix","result":[`+
+		`{"metric":{"a":"1"},"values":[[79200,"79200"],[82800,"82800"],[86400,"86400"],[90000,"90000"],[93600,"93600"]]},`+
+		`{"metric":{"a":"2"},"values":[[86400,"86400"],[90000,"90000"],[93600,"93600"]]}`+
+		`]},"warnings":["w"]}`, w.Body.String())
+	queries := b.reset()
+	require.Len(t, queries, 2)
+	for _, q := range queries {
+		require.Equal(t, "up", q.Get("query"))
+		require.Equal(t, "3600", q.Get("step"))
+	}
+
+	// Results with warnings are not cached.
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=up&start=79200&end=93600&step=3600", nil))
+	require.Len(t, b.reset(), 2)
+}
+
+func TestSplitter_StartEnd(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{}
+	s := NewSplitter(log.NewNopLogger(), b, NewLRUCache(1<<20), SplitOptions{
+		Interval:       24 * time.Hour,
+		MaxParallelism: 2,
+		MaxFreshness:   time.Hour,
+	}, nil)
+	s.now = func() time.Time { return time.Unix(2*24*3600, 0) }
+
+	// start() and end() are resolved to the aligned range of the query, not of the splits.
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+url.Values{
+		"query": {"up @ end() - max_over_time(up[1h:] @ start())"},
+		"start": {"79300"},
+		"end":   {"93600"},
+		"step":  {"3600"},
+	}.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	queries := b.reset()
+	require.Len(t, queries, 2)
+	for _, q := range queries {
+		require.Equal(t, "up @ 93600.000 - max_over_time(up[1h:] @ 79200.000)", q.Get("query"))
+	}
+}
+
+func TestSplitter_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[%s,"1"]]}]}}`, req.URL.Query().Get("start"))
+	})
+	s := NewSplitter(log.NewNopLogger(), next, NewLRUCache(1<<20), SplitOptions{
+		Interval:       24 * time.Hour,
+		MaxParallelism: 1,
+		MaxFreshness:   time.Hour,
+	}, nil)
+	s.now = func() time.Time { return time.Unix(86400+1800, 0) }
+
+	// The split of the first day is cached, the split of the second day is too fresh.
+	for range 2 {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=up&start=0&end=90000&step=86400", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[0,"1"],[86400,"1"]]}]}}`, w.Body.String())
+	}
+	require.Equal(t, 3, calls)
+}
+
+func TestSplitter_Errors(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{}
+	s := NewSplitter(log.NewNopLogger(), b, nil, SplitOptions{Interval: 24 * time.Hour}, nil)
+
+	for _, tt := range []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "backend error",
+			query:      "query=invalid&start=0&end=100000&step=60",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+		},
+		{
+			name:       "invalid start",
+			query:      "query=up&start=foo&end=100000&step=60",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":"error","errorType":"bad_data","error":"invalid parameter \"start\": cannot parse \"foo\" to a valid timestamp"}`,
+		},
+		{
+			name:       "end before start",
+			query:      "query=up&start=100&end=0&step=60",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":"error","errorType":"bad_data","error":"end timestamp must not be before start time"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+tt.query, nil))
+			require.Equal(t, tt.wantStatus, w.Code)
+			require.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
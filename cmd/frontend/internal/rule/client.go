@@ -60,10 +60,43 @@ func (r *client) RuleGroups(ctx context.Context, baseURL url.URL, queryString st
 	if err := json.Unmarshal(resp, &parsedResp); err != nil {
 		return nil, fmt.Errorf("unmarshalling response from endpoint failed with error: %w", err)
 	}
+	for _, g := range parsedResp.Data.Groups {
+		for i, rule := range g.Rules {
+			if g.Rules[i], err = decodeRule(rule); err != nil {
+				return nil, fmt.Errorf("unmarshalling rule of group %q failed with error: %w", g.Name, err)
+			}
+		}
+	}
 
 	return parsedResp.Data.Groups, nil
 }
 
+// decodeRule converts a rule, which is unmarshalled as a generic JSON object, into an
+// alerting or recording rule. Rules of unknown type are returned as-is.
+func decodeRule(rule promapiv1.Rule) (promapiv1.Rule, error) {
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, err
+	}
+	switch typed.Type {
+	case "alerting":
+		var r promapiv1.AlertingRule
+		err = json.Unmarshal(raw, &r)
+		return r, err
+	case "recording":
+		var r promapiv1.RecordingRule
+		err = json.Unmarshal(raw, &r)
+		return r, err
+	}
+	return rule, nil
+}
+
 // Alerts fetches alerts from the endpoint.
 func (r *client) Alerts(ctx context.Context, baseURL url.URL, queryString string) ([]*promapiv1.Alert, error) {
 	resp, err := r.call(ctx, baseURL, alertsPath, queryString)
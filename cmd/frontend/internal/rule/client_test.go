@@ -22,6 +22,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/prometheus/model/labels"
 	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
 	"github.com/stretchr/testify/require"
 )
@@ -190,6 +191,25 @@ func TestClient_Rules(t *testing.T) {
 			queryString: "queryParam=bar",
 			want:        []*promapiv1.RuleGroup{{Name: "test"}},
 		},
+		{
+			name: "decodes alerting and recording rules",
+			client: &mockClient{
+				DoFunc: func(_ *http.Request) (*http.Response, error) {
+					return &http.Response{
+						Body: io.NopCloser(strings.NewReader(`{"status": "success", "data": {"groups": [{"name": "test", "rules": [` +
+							`{"name": "rec", "query": "up", "labels": {"a": "b"}, "type": "recording"},` +
+							`{"name": "alert", "query": "up == 0", "state": "firing", "alerts": [{"labels": {"a": "b"}, "state": "firing"}], "type": "alerting"},` +
+							`{"name": "unknown"}]}]}}`)),
+						StatusCode: http.StatusOK,
+					}, nil
+				},
+			},
+			want: []*promapiv1.RuleGroup{{Name: "test", Rules: []promapiv1.Rule{
+				promapiv1.RecordingRule{Name: "rec", Query: "up", Labels: labels.FromStrings("a", "b"), Type: "recording"},
+				promapiv1.AlertingRule{Name: "alert", Query: "up == 0", State: "firing", Alerts: []*promapiv1.Alert{{Labels: labels.FromStrings("a", "b"), State: "firing"}}, Type: "alerting"},
+				map[string]any{"name": "unknown"},
+			}}},
+		},
 		{
 			name: "json-unmarshal error results in error",
 			client: &mockClient{
@@ -0,0 +1,262 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Scheme prefixes of DNS targets, e.g. dns+http://rule-evaluator:19092 or
+// dnssrv+http://_rule-evaluator._tcp.rule-evaluator.
+const (
+	// dnsPrefix expands the target to the A/AAAA records of its host.
+	dnsPrefix = "dns"
+	// dnsSRVPrefix expands the target to the SRV records of its host.
+	dnsSRVPrefix = "dnssrv"
+)
+
+// Endpoints provides the endpoints the proxy fans out to.
+type Endpoints interface {
+	Endpoints() []url.URL
+}
+
+// StaticEndpoints are endpoints that never change.
+type StaticEndpoints []url.URL
+
+// Endpoints returns the static endpoints.
+func (e StaticEndpoints) Endpoints() []url.URL {
+	return e
+}
+
+// Discovery periodically refreshes the endpoints, e.g. of the individual pods of a scaled
+// rule-evaluator. If a refresh fails, the previously discovered endpoints are kept.
+type Discovery struct {
+	logger   log.Logger
+	interval time.Duration
+	discover func(context.Context) ([]url.URL, error)
+
+	mtx       sync.RWMutex
+	endpoints []url.URL
+}
+
+// Endpoints returns the endpoints of the last successful refresh.
+func (d *Discovery) Endpoints() []url.URL {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	return d.endpoints
+}
+
+// Run refreshes the endpoints until the context is canceled.
+func (d *Discovery) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.refresh(ctx); err != nil {
+			_ = level.Warn(d.logger).Log("msg", "Refreshing rule endpoints failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Discovery) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, d.interval)
+	defer cancel()
+
+	endpoints, err := d.discover(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(endpoints, func(a, b url.URL) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	endpoints = slices.Compact(endpoints)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if !slices.Equal(d.endpoints, endpoints) {
+		_ = level.Info(d.logger).Log("msg", "Rule endpoints changed", "endpoints", fmt.Sprint(endpoints))
+	}
+	d.endpoints = endpoints
+	return nil
+}
+
+// IsDNSTarget returns whether the scheme of the target has a DNS prefix, i.e. whether the
+// target is expanded by DNS discovery.
+func IsDNSTarget(target url.URL) bool {
+	prefix, _, ok := strings.Cut(target.Scheme, "+")
+	return ok && (prefix == dnsPrefix || prefix == dnsSRVPrefix)
+}
+
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSDiscovery creates a discovery that expands the DNS targets to one endpoint per
+// resolved record. Targets without a DNS prefix are used as-is.
+func NewDNSDiscovery(logger log.Logger, targets []url.URL, interval time.Duration) (*Discovery, error) {
+	return newDNSDiscovery(logger, net.DefaultResolver, targets, interval)
+}
+
+func newDNSDiscovery(logger log.Logger, r resolver, targets []url.URL, interval time.Duration) (*Discovery, error) {
+	for _, t := range targets {
+		prefix, _, ok := strings.Cut(t.Scheme, "+")
+		if ok && !IsDNSTarget(t) {
+			return nil, fmt.Errorf("unsupported DNS prefix %q of target %s", prefix, t.Redacted())
+		}
+		if prefix == dnsSRVPrefix && t.Port() != "" {
+			return nil, fmt.Errorf("target %s must not have a port, ports are taken from SRV records", t.Redacted())
+		}
+	}
+	return &Discovery{
+		logger:   logger,
+		interval: interval,
+		discover: func(ctx context.Context) ([]url.URL, error) {
+			return resolveTargets(ctx, r, targets)
+		},
+	}, nil
+}
+
+func resolveTargets(ctx context.Context, r resolver, targets []url.URL) ([]url.URL, error) {
+	var (
+		endpoints []url.URL
+		errs      []error
+	)
+	for _, t := range targets {
+		if !IsDNSTarget(t) {
+			endpoints = append(endpoints, t)
+			continue
+		}
+		prefix, scheme, _ := strings.Cut(t.Scheme, "+")
+		endpoint := func(host string) url.URL {
+			u := t
+			u.Scheme = scheme
+			u.Host = host
+			return u
+		}
+		switch prefix {
+		case dnsPrefix:
+			addrs, err := r.LookupIPAddr(ctx, t.Hostname())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("lookup %s: %w", t.Hostname(), err))
+				continue
+			}
+			for _, addr := range addrs {
+				host := addr.String()
+				if port := t.Port(); port != "" {
+					host = net.JoinHostPort(host, port)
+				} else if addr.IP.To4() == nil {
+					host = "[" + host + "]"
+				}
+				endpoints = append(endpoints, endpoint(host))
+			}
+		case dnsSRVPrefix:
+			_, srvs, err := r.LookupSRV(ctx, "", "", t.Hostname())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("lookup SRV %s: %w", t.Hostname(), err))
+				continue
+			}
+			for _, srv := range srvs {
+				host := strings.TrimSuffix(srv.Target, ".")
+				endpoints = append(endpoints, endpoint(net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// EndpointSliceOptions selects the EndpointSlices of the rule-evaluator pods.
+type EndpointSliceOptions struct {
+	// Namespace of the EndpointSlices.
+	Namespace string
+	// Selector is the label selector of the EndpointSlices.
+	Selector string
+	// PortName is the name of the port serving the rules and alerts API. The first
+	// port is used if empty.
+	PortName string
+	// Scheme of the endpoints.
+	Scheme string
+}
+
+// NewEndpointSliceDiscovery creates a discovery that returns one endpoint for every ready
+// endpoint of the selected EndpointSlices.
+func NewEndpointSliceDiscovery(logger log.Logger, client kubernetes.Interface, opts EndpointSliceOptions, interval time.Duration) (*Discovery, error) {
+	if _, err := k8slabels.Parse(opts.Selector); err != nil {
+		return nil, fmt.Errorf("invalid EndpointSlice selector: %w", err)
+	}
+	return &Discovery{
+		logger:   logger,
+		interval: interval,
+		discover: func(ctx context.Context) ([]url.URL, error) {
+			return listEndpointSlices(ctx, client, opts)
+		},
+	}, nil
+}
+
+func listEndpointSlices(ctx context.Context, client kubernetes.Interface, opts EndpointSliceOptions) ([]url.URL, error) {
+	list, err := client.DiscoveryV1().EndpointSlices(opts.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: opts.Selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list EndpointSlices: %w", err)
+	}
+	var endpoints []url.URL
+	for _, slice := range list.Items {
+		var port *int32
+		for _, p := range slice.Ports {
+			if opts.PortName == "" || (p.Name != nil && *p.Name == opts.PortName) {
+				port = p.Port
+				break
+			}
+		}
+		if port == nil {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			// Unset conditions are to be interpreted as ready. All addresses of an
+			// endpoint are fungible.
+			if (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) || len(ep.Addresses) == 0 {
+				continue
+			}
+			endpoints = append(endpoints, url.URL{
+				Scheme: opts.Scheme,
+				Host:   net.JoinHostPort(ep.Addresses[0], strconv.Itoa(int(*port))),
+			})
+		}
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+type mockResolver struct {
+	addrs map[string][]net.IPAddr
+	srvs  map[string][]*net.SRV
+	err   error
+}
+
+func (r *mockResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	return r.addrs[host], r.err
+}
+
+func (r *mockResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	return "", r.srvs[name], r.err
+}
+
+func mustParseURLs(t *testing.T, urls ...string) []url.URL {
+	t.Helper()
+	var res []url.URL
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		res = append(res, *u)
+	}
+	return res
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	t.Parallel()
+
+	r := &mockResolver{
+		addrs: map[string][]net.IPAddr{
+			"rule-evaluator": {{IP: net.ParseIP("10.0.0.2")}, {IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fd00::1")}},
+		},
+		srvs: map[string][]*net.SRV{
+			"_web._tcp.rule-evaluator": {{Target: "rule-evaluator-0.rule-evaluator.", Port: 19092}},
+		},
+	}
+	d, err := newDNSDiscovery(log.NewNopLogger(), r, mustParseURLs(t,
+		"dns+http://rule-evaluator:19092/prefix",
+		"dnssrv+https://_web._tcp.rule-evaluator",
+		"http://static:19092",
+	), time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, d.Endpoints())
+
+	require.NoError(t, d.refresh(t.Context()))
+	require.Equal(t, mustParseURLs(t,
+		"http://10.0.0.1:19092/prefix",
+		"http://10.0.0.2:19092/prefix",
+		"http://[fd00::1]:19092/prefix",
+		"http://static:19092",
+		"https://rule-evaluator-0.rule-evaluator:19092",
+	), d.Endpoints())
+
+	// Failed lookups keep the previous endpoints.
+	r.err = errors.New("lookup failed")
+	require.Error(t, d.refresh(t.Context()))
+	require.Len(t, d.Endpoints(), 5)
+}
+
+func TestDNSDiscovery_InvalidTargets(t *testing.T) {
+	t.Parallel()
+
+	for _, target := range []string{
+		"foo+http://rule-evaluator:19092",
+		"dnssrv+http://_web._tcp.rule-evaluator:19092",
+	} {
+		_, err := newDNSDiscovery(log.NewNopLogger(), &mockResolver{}, mustParseURLs(t, target), time.Minute)
+		require.Error(t, err, target)
+	}
+}
+
+func TestEndpointSliceDiscovery(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{discoveryv1.LabelServiceName: "rule-evaluator"}
+	client := fake.NewClientset(
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-system", Name: "rule-evaluator-abc", Labels: labels},
+			Ports: []discoveryv1.EndpointPort{
+				{Name: ptr.To("metrics"), Port: ptr.To[int32](19093)},
+				{Name: ptr.To("rule-evaluator"), Port: ptr.To[int32](19092)},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+				{Addresses: []string{"10.0.0.1"}},
+				{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+			},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-system", Name: "rule-evaluator-def", Labels: labels},
+			Ports:      []discoveryv1.EndpointPort{{Name: ptr.To("metrics"), Port: ptr.To[int32](19093)}},
+			Endpoints:  []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.4"}}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-system", Name: "other", Labels: map[string]string{discoveryv1.LabelServiceName: "other"}},
+			Ports:      []discoveryv1.EndpointPort{{Name: ptr.To("rule-evaluator"), Port: ptr.To[int32](19092)}},
+			Endpoints:  []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.5"}}},
+		},
+	)
+
+	d, err := NewEndpointSliceDiscovery(log.NewNopLogger(), client, EndpointSliceOptions{
+		Namespace: "gmp-system",
+		Selector:  discoveryv1.LabelServiceName + "=rule-evaluator",
+		PortName:  "rule-evaluator",
+		Scheme:    "http",
+	}, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, d.refresh(t.Context()))
+	require.Equal(t, mustParseURLs(t, "http://10.0.0.1:19092", "http://10.0.0.2:19092"), d.Endpoints())
+}
+
+func TestEndpointSliceDiscovery_InvalidSelector(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewEndpointSliceDiscovery(log.NewNopLogger(), fake.NewClientset(), EndpointSliceOptions{Selector: "a in (b"}, time.Minute)
+	require.Error(t, err)
+}
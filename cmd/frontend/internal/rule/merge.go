@@ -0,0 +1,178 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
+)
+
+// mergeRuleGroups merges the groups with the same file and name, e.g. of replicas evaluating
+// the same rules, and sorts the groups by file and name. Rules of merged groups are
+// deduplicated by their type, name, query and labels. The most recently evaluated duplicate is kept.
+func mergeRuleGroups(groups []*promapiv1.RuleGroup, sourceAnnotation string) []*promapiv1.RuleGroup {
+	type groupKey struct{ file, name string }
+	var (
+		merged = make([]*promapiv1.RuleGroup, 0, len(groups))
+		byKey  = map[groupKey]*promapiv1.RuleGroup{}
+	)
+	for _, g := range groups {
+		key := groupKey{file: g.File, name: g.Name}
+		prev, ok := byKey[key]
+		if !ok {
+			byKey[key] = g
+			merged = append(merged, g)
+			continue
+		}
+		if g.LastEvaluation.After(prev.LastEvaluation) {
+			prev.EvaluationTime = g.EvaluationTime
+			prev.LastEvaluation = g.LastEvaluation
+		}
+		prev.Rules = mergeRules(prev.Rules, g.Rules, sourceAnnotation)
+	}
+	slices.SortStableFunc(merged, func(a, b *promapiv1.RuleGroup) int {
+		return cmp.Or(strings.Compare(a.File, b.File), strings.Compare(a.Name, b.Name))
+	})
+	return merged
+}
+
+// mergeRules appends the rules that are not in dst and replaces the duplicates that were
+// evaluated more recently. The order of dst is kept.
+func mergeRules(dst, src []promapiv1.Rule, sourceAnnotation string) []promapiv1.Rule {
+	index := make(map[string]int, len(dst))
+	for i, r := range dst {
+		if key := ruleKey(r); key != "" {
+			index[key] = i
+		}
+	}
+	for _, r := range src {
+		key := ruleKey(r)
+		i, ok := index[key]
+		if key == "" || !ok {
+			index[key] = len(dst)
+			dst = append(dst, r)
+			continue
+		}
+		dst[i] = mergeRule(dst[i], r, sourceAnnotation)
+	}
+	return dst
+}
+
+func mergeRule(a, b promapiv1.Rule, sourceAnnotation string) promapiv1.Rule {
+	switch a := a.(type) {
+	case promapiv1.AlertingRule:
+		b := b.(promapiv1.AlertingRule)
+		annotations := mergeSource(a.Annotations, b.Annotations, sourceAnnotation)
+		if b.LastEvaluation.After(a.LastEvaluation) {
+			a = b
+		}
+		a.Annotations = annotations
+		return a
+	case promapiv1.RecordingRule:
+		if b := b.(promapiv1.RecordingRule); b.LastEvaluation.After(a.LastEvaluation) {
+			return b
+		}
+	}
+	return a
+}
+
+// ruleKey identifies a rule within a group. Rules of unknown type have no key and are
+// never merged.
+func ruleKey(r promapiv1.Rule) string {
+	switch r := r.(type) {
+	case promapiv1.AlertingRule:
+		return strings.Join([]string{"alerting", r.Name, r.Query, r.Labels.String()}, "\xff")
+	case promapiv1.RecordingRule:
+		return strings.Join([]string{"recording", r.Name, r.Query, r.Labels.String()}, "\xff")
+	}
+	return ""
+}
+
+// mergeAlerts deduplicates the alerts with the same labels and sorts them by labels.
+// Of duplicates, firing alerts are preferred over pending ones and earlier activation
+// over later activation.
+func mergeAlerts(alerts []*promapiv1.Alert, sourceAnnotation string) []*promapiv1.Alert {
+	var (
+		merged = make([]*promapiv1.Alert, 0, len(alerts))
+		index  = map[string]int{}
+	)
+	for _, a := range alerts {
+		key := a.Labels.String()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, a)
+			continue
+		}
+		prev := merged[i]
+		annotations := mergeSource(prev.Annotations, a.Annotations, sourceAnnotation)
+		if compareAlerts(a, prev) < 0 {
+			prev = a
+		}
+		prev.Annotations = annotations
+		merged[i] = prev
+	}
+	slices.SortFunc(merged, func(a, b *promapiv1.Alert) int {
+		return labels.Compare(a.Labels, b.Labels)
+	})
+	return merged
+}
+
+var alertStateOrder = map[string]int{"firing": 0, "pending": 1, "inactive": 2}
+
+// compareAlerts orders duplicate alerts by preference.
+func compareAlerts(a, b *promapiv1.Alert) int {
+	stateOrder := func(s string) int {
+		if o, ok := alertStateOrder[s]; ok {
+			return o
+		}
+		return len(alertStateOrder)
+	}
+	if c := cmp.Compare(stateOrder(a.State), stateOrder(b.State)); c != 0 {
+		return c
+	}
+	switch {
+	case a.ActiveAt == nil && b.ActiveAt == nil:
+		return 0
+	case a.ActiveAt == nil:
+		return 1
+	case b.ActiveAt == nil:
+		return -1
+	}
+	return a.ActiveAt.Compare(*b.ActiveAt)
+}
+
+// mergeSource returns the annotations of a with the sources of a and b combined in the
+// source annotation.
+func mergeSource(a, b labels.Labels, sourceAnnotation string) labels.Labels {
+	if sourceAnnotation == "" {
+		return a
+	}
+	var sources []string
+	for _, s := range []string{a.Get(sourceAnnotation), b.Get(sourceAnnotation)} {
+		if s != "" {
+			sources = append(sources, strings.Split(s, ",")...)
+		}
+	}
+	if len(sources) == 0 {
+		return a
+	}
+	slices.Sort(sources)
+	return labels.NewBuilder(a).Set(sourceAnnotation, strings.Join(slices.Compact(sources), ",")).Labels()
+}
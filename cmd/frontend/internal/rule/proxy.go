@@ -25,6 +25,7 @@ import (
 	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
 	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
 )
 
@@ -36,40 +37,81 @@ type retriever interface {
 	Alerts(ctx context.Context, baseURL url.URL, queryString string) ([]*promapiv1.Alert, error)
 }
 
+// ProxyOptions configures how the results of the endpoints are merged.
+type ProxyOptions struct {
+	// ReplicaLabel is removed from the labels of rules and alerts, so that the results of
+	// replicas evaluating the same rules are deduplicated.
+	ReplicaLabel string
+	// SourceAnnotation is the annotation of alerts and alerting rules that is set to the
+	// endpoints they were retrieved from. No annotation is added if empty.
+	SourceAnnotation string
+}
+
 // Proxy fan-outs requests to multiple endpoints serving rules and alerts.
-// Results are un-sorted and concatenated as-is. In case of errors from any endpoint,
-// warning log and partial results are returned.
+// Rule groups with the same file and name are merged and alerts with the same labels are
+// deduplicated. Results are sorted. In case of errors from any endpoint, warning log and
+// partial results are returned.
 type Proxy struct {
 	logger    log.Logger
-	endpoints []url.URL
+	endpoints Endpoints
 	client    retriever
+	opts      ProxyOptions
 }
 
 // NewProxy creates a new proxy.
-func NewProxy(logger log.Logger, c httpClient, ruleEndpoints []url.URL) *Proxy {
+func NewProxy(logger log.Logger, c httpClient, ruleEndpoints Endpoints, opts ProxyOptions) *Proxy {
 	return &Proxy{
 		logger:    logger,
 		endpoints: ruleEndpoints,
 		client:    newClient(c),
+		opts:      opts,
 	}
 }
 
+// RuleGroups returns the rule groups of all endpoints. The match[] and other filters are
+// forwarded to the endpoints. Pagination is applied to the combined groups, as each endpoint
+// only knows its own groups.
 func (p *Proxy) RuleGroups(w http.ResponseWriter, req *http.Request) {
-	rules, err := fanoutForward[*promapiv1.RuleGroup](req.Context(), p.logger, p.endpoints, req.URL.RawQuery, p.client.RuleGroups)
+	query := req.URL.Query()
+	groupLimit, groupNextToken, err := promapi.ParseRuleGroupsPagination(query)
+	if err != nil {
+		promapi.WriteError(p.logger, w, promapi.ErrorBadData, err.Error(), http.StatusBadRequest, req.URL.Path)
+		return
+	}
+	rawQuery := req.URL.RawQuery
+	if groupLimit > 0 {
+		query.Del(promapi.GroupLimitQueryParamName)
+		query.Del(promapi.GroupNextTokenQueryParamName)
+		rawQuery = query.Encode()
+	}
+
+	rules, err := fanoutForward[*promapiv1.RuleGroup](req.Context(), p.logger, p.endpoints.Endpoints(), rawQuery, p.retrieveRuleGroups)
 	if err != nil {
 		p.handleError(w, req, err)
 		return
 	}
+	// Results of the endpoints arrive in any order, merging sorts them for stable pages.
+	rules = mergeRuleGroups(rules, p.opts.SourceAnnotation)
+
+	var nextToken string
+	if groupLimit > 0 {
+		rules, nextToken, err = promapi.PaginateRuleGroups(rules, groupLimit, groupNextToken)
+		if err != nil {
+			promapi.WriteError(p.logger, w, promapi.ErrorBadData, err.Error(), http.StatusBadRequest, req.URL.Path)
+			return
+		}
+	}
 
-	promapi.WriteSuccessResponse(p.logger, w, http.StatusOK, req.URL.Path, promapi.RulesResponseData{Groups: rules})
+	promapi.WriteSuccessResponse(p.logger, w, http.StatusOK, req.URL.Path, promapi.RulesResponseData{Groups: rules, GroupNextToken: nextToken})
 }
 
 func (p *Proxy) Alerts(w http.ResponseWriter, req *http.Request) {
-	alerts, err := fanoutForward[*promapiv1.Alert](req.Context(), p.logger, p.endpoints, req.URL.RawQuery, p.client.Alerts)
+	alerts, err := fanoutForward[*promapiv1.Alert](req.Context(), p.logger, p.endpoints.Endpoints(), req.URL.RawQuery, p.retrieveAlerts)
 	if err != nil {
 		p.handleError(w, req, err)
 		return
 	}
+	alerts = mergeAlerts(alerts, p.opts.SourceAnnotation)
 
 	promapi.WriteSuccessResponse(p.logger, w, http.StatusOK, req.URL.Path, promapi.AlertsResponseData{Alerts: alerts})
 }
@@ -83,7 +125,7 @@ func fanoutForward[T *promapiv1.Alert | *promapiv1.RuleGroup](
 	retrieveFn func(context.Context, url.URL, string) ([]T, error),
 ) ([]T, error) {
 	if len(ruleEndpoints) == 0 {
-		_ = level.Warn(logger).Log("msg", "tried to fetch rules/alerts, no endpoints (--rules.target-urls) configured")
+		_ = level.Warn(logger).Log("msg", "tried to fetch rules/alerts, no endpoints (--rules.target-urls) configured or discovered")
 		return []T{}, nil
 	}
 
@@ -138,10 +180,66 @@ func fanoutForward[T *promapiv1.Alert | *promapiv1.RuleGroup](
 		}
 		_ = level.Warn(logger).Log("msg", "some endpoints failed; potentially partial result", "errors", errs)
 	}
-	// TODO(bwplotka): Sort?
 	return results, nil
 }
 
+// retrieveRuleGroups retrieves the rule groups of the endpoint and prepares their rules
+// for merging.
+func (p *Proxy) retrieveRuleGroups(ctx context.Context, baseURL url.URL, rawQuery string) ([]*promapiv1.RuleGroup, error) {
+	groups, err := p.client.RuleGroups(ctx, baseURL, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		for i, r := range g.Rules {
+			switch r := r.(type) {
+			case promapiv1.AlertingRule:
+				r.Labels = p.withoutReplica(r.Labels)
+				r.Annotations = p.withSource(r.Annotations, baseURL)
+				for _, a := range r.Alerts {
+					p.prepareAlert(a, baseURL)
+				}
+				g.Rules[i] = r
+			case promapiv1.RecordingRule:
+				r.Labels = p.withoutReplica(r.Labels)
+				g.Rules[i] = r
+			}
+		}
+	}
+	return groups, nil
+}
+
+// retrieveAlerts retrieves the alerts of the endpoint and prepares them for merging.
+func (p *Proxy) retrieveAlerts(ctx context.Context, baseURL url.URL, rawQuery string) ([]*promapiv1.Alert, error) {
+	alerts, err := p.client.Alerts(ctx, baseURL, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range alerts {
+		p.prepareAlert(a, baseURL)
+	}
+	return alerts, nil
+}
+
+func (p *Proxy) prepareAlert(a *promapiv1.Alert, baseURL url.URL) {
+	a.Labels = p.withoutReplica(a.Labels)
+	a.Annotations = p.withSource(a.Annotations, baseURL)
+}
+
+func (p *Proxy) withoutReplica(lset labels.Labels) labels.Labels {
+	if p.opts.ReplicaLabel == "" || !lset.Has(p.opts.ReplicaLabel) {
+		return lset
+	}
+	return labels.NewBuilder(lset).Del(p.opts.ReplicaLabel).Labels()
+}
+
+func (p *Proxy) withSource(annotations labels.Labels, baseURL url.URL) labels.Labels {
+	if p.opts.SourceAnnotation == "" {
+		return annotations
+	}
+	return labels.NewBuilder(annotations).Set(p.opts.SourceAnnotation, baseURL.Redacted()).Labels()
+}
+
 // handleError writes an error response to the client based on the error.
 func (p *Proxy) handleError(w http.ResponseWriter, req *http.Request, err error) {
 	if errors.Is(err, context.Canceled) {
@@ -80,7 +80,7 @@ func TestProxy_handleError(t *testing.T) {
 			t.Parallel()
 
 			recorder := httptest.NewRecorder()
-			p := NewProxy(log.NewNopLogger(), nil, nil)
+			p := NewProxy(log.NewNopLogger(), nil, nil, ProxyOptions{})
 			p.handleError(recorder, dummyRequest, tt.err)
 
 			require.Equal(t, tt.wantStatus, recorder.Code)
@@ -236,6 +236,7 @@ func TestProxy_Alerts(t *testing.T) {
 	activeAt2, _ := time.Parse(time.RFC3339Nano, "2022-02-22T22:22:22.999977773Z")
 	for _, tt := range []struct {
 		name                  string
+		opts                  ProxyOptions
 		ruleEvaluatorBaseURLs []url.URL
 		ruleRetriever         retriever
 		wantStatus            int
@@ -290,12 +291,37 @@ func TestProxy_Alerts(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantBody:   `{"status":"success","data":{"alerts":[{"labels":{"labelKey1":"labelVal1"},"annotations":{"annoKey1":"AnnoVal1"},"state":"firing","activeAt":"2011-11-11T11:11:11.111122223Z","value":"1e+00"},{"labels":{"labelKey2":"labelVal2"},"annotations":{"annoKey2":"AnnoVal2"},"state":"firing","activeAt":"2022-02-22T22:22:22.999977773Z","value":"2e+00"}]}}`,
 		},
+		{
+			name: "deduplicates and sorts alerts of replicas",
+			opts: ProxyOptions{ReplicaLabel: "replica", SourceAnnotation: "source"},
+			ruleEvaluatorBaseURLs: []url.URL{
+				{Scheme: "http", Host: "localhost:8080"},
+				{Scheme: "http", Host: "localhost:8081"},
+			},
+			ruleRetriever: &mockRetriever{
+				AlertsFunc: func(_ context.Context, baseURL url.URL, _ string) ([]*promapiv1.Alert, error) {
+					if baseURL.Host == "localhost:8080" {
+						return []*promapiv1.Alert{
+							{Labels: labels.FromStrings("alertname", "b", "replica", "0"), State: "pending", ActiveAt: &activeAt1, Value: "1e+00"},
+							{Labels: labels.FromStrings("alertname", "a", "replica", "0"), State: "firing", ActiveAt: &activeAt2, Value: "1e+00"},
+						}, nil
+					}
+					return []*promapiv1.Alert{
+						{Labels: labels.FromStrings("alertname", "a", "replica", "1"), State: "firing", ActiveAt: &activeAt1, Value: "2e+00"},
+						{Labels: labels.FromStrings("alertname", "b", "replica", "1"), State: "firing", ActiveAt: &activeAt2, Value: "2e+00"},
+					}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"success","data":{"alerts":[{"labels":{"alertname":"a"},"annotations":{"source":"http://localhost:8080,http://localhost:8081"},"state":"firing","activeAt":"2011-11-11T11:11:11.111122223Z","value":"2e+00"},{"labels":{"alertname":"b"},"annotations":{"source":"http://localhost:8080,http://localhost:8081"},"state":"firing","activeAt":"2022-02-22T22:22:22.999977773Z","value":"2e+00"}]}}`,
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &Proxy{
 				logger:    log.NewNopLogger(),
-				endpoints: tt.ruleEvaluatorBaseURLs,
+				endpoints: StaticEndpoints(tt.ruleEvaluatorBaseURLs),
 				client:    tt.ruleRetriever,
+				opts:      tt.opts,
 			}
 
 			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
@@ -311,8 +337,12 @@ func TestProxy_Alerts(t *testing.T) {
 func TestProxy_RuleGroups(t *testing.T) {
 	t.Parallel()
 
+	activeAt, _ := time.Parse(time.RFC3339Nano, "2011-11-11T11:11:11.111122223Z")
+
 	tests := []struct {
 		name                  string
+		query                 string
+		opts                  ProxyOptions
 		ruleEvaluatorBaseURLs []url.URL
 		ruleRetriever         retriever
 		wantStatus            int
@@ -358,16 +388,114 @@ func TestProxy_RuleGroups(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantBody:   `{"status":"success","data":{"groups":[{"name":"group1","file":"file1","rules":[],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"}]}}`,
 		},
+		{
+			name:  "forwards match filter",
+			query: `match[]={team="a"}`,
+			ruleEvaluatorBaseURLs: []url.URL{
+				{Scheme: "http", Host: "localhost:8080"},
+			},
+			ruleRetriever: &mockRetriever{
+				RuleGroupsFunc: func(_ context.Context, _ url.URL, query string) ([]*promapiv1.RuleGroup, error) {
+					require.Equal(t, `match[]={team="a"}`, query)
+					return []*promapiv1.RuleGroup{{Name: "group1", File: "file1", Rules: []promapiv1.Rule{}}}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"success","data":{"groups":[{"name":"group1","file":"file1","rules":[],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"}]}}`,
+		},
+		{
+			name:  "paginates over combined groups",
+			query: "group_limit=2&group_next_token=" + promapi.RuleGroupNextToken("file1", "group2") + "&rule_group[]=foo",
+			ruleEvaluatorBaseURLs: []url.URL{
+				{Scheme: "http", Host: "localhost:8080"},
+				{Scheme: "http", Host: "localhost:8081"},
+			},
+			ruleRetriever: &mockRetriever{
+				RuleGroupsFunc: func(_ context.Context, baseURL url.URL, query string) ([]*promapiv1.RuleGroup, error) {
+					// Pagination parameters are not forwarded.
+					require.Equal(t, "rule_group%5B%5D=foo", query)
+					if baseURL.Host == "localhost:8080" {
+						return []*promapiv1.RuleGroup{
+							{Name: "group3", File: "file1", Rules: []promapiv1.Rule{}},
+							{Name: "group1", File: "file1", Rules: []promapiv1.Rule{}},
+						}, nil
+					}
+					return []*promapiv1.RuleGroup{
+						{Name: "group2", File: "file1", Rules: []promapiv1.Rule{}},
+						{Name: "group1", File: "file2", Rules: []promapiv1.Rule{}},
+					}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"success","data":{"groups":[{"name":"group2","file":"file1","rules":[],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"},{"name":"group3","file":"file1","rules":[],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"}],"groupNextToken":"` + promapi.RuleGroupNextToken("file2", "group1") + `"}}`,
+		},
+		{
+			name: "merges groups of replicas",
+			opts: ProxyOptions{ReplicaLabel: "replica", SourceAnnotation: "source"},
+			ruleEvaluatorBaseURLs: []url.URL{
+				{Scheme: "http", Host: "localhost:8080"},
+				{Scheme: "http", Host: "localhost:8081"},
+			},
+			ruleRetriever: &mockRetriever{
+				RuleGroupsFunc: func(_ context.Context, baseURL url.URL, _ string) ([]*promapiv1.RuleGroup, error) {
+					replica, lastEvaluation := "0", time.Time{}
+					if baseURL.Host == "localhost:8081" {
+						replica, lastEvaluation = "1", activeAt
+					}
+					return []*promapiv1.RuleGroup{
+						{Name: "group2", File: "file1", Rules: []promapiv1.Rule{}},
+						{Name: "group1", File: "file1", LastEvaluation: lastEvaluation, Rules: []promapiv1.Rule{
+							promapiv1.RecordingRule{Name: "rec", Query: "up", Labels: labels.FromStrings("replica", replica), LastEvaluation: lastEvaluation, Type: "recording"},
+							promapiv1.AlertingRule{Name: "alert", Query: "up == 0", Labels: labels.FromStrings("replica", replica), LastEvaluation: lastEvaluation, State: "inactive", Alerts: []*promapiv1.Alert{}, Type: "alerting"},
+						}},
+					}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"status":"success","data":{"groups":[` +
+				`{"name":"group1","file":"file1","rules":[` +
+				`{"name":"rec","query":"up","health":"","evaluationTime":0,"lastEvaluation":"2011-11-11T11:11:11.111122223Z","type":"recording"},` +
+				`{"state":"inactive","name":"alert","query":"up == 0","duration":0,"keepFiringFor":0,"labels":{},"annotations":{"source":"http://localhost:8080,http://localhost:8081"},"alerts":[],"health":"","evaluationTime":0,"lastEvaluation":"2011-11-11T11:11:11.111122223Z","type":"alerting"}` +
+				`],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"2011-11-11T11:11:11.111122223Z"},` +
+				`{"name":"group2","file":"file1","rules":[],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"}]}}`,
+		},
+		{
+			name:  "invalid pagination token",
+			query: "group_limit=2&group_next_token=foo",
+			ruleEvaluatorBaseURLs: []url.URL{
+				{Scheme: "http", Host: "localhost:8080"},
+			},
+			ruleRetriever: &mockRetriever{
+				RuleGroupsFunc: func(context.Context, url.URL, string) ([]*promapiv1.RuleGroup, error) {
+					return []*promapiv1.RuleGroup{{Name: "group1", File: "file1", Rules: []promapiv1.Rule{}}}, nil
+				},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":"error","errorType":"bad_data","error":"invalid group_next_token"}`,
+		},
+		{
+			name:  "invalid pagination limit",
+			query: "group_limit=-1",
+			ruleRetriever: &mockRetriever{
+				RuleGroupsFunc: func(context.Context, url.URL, string) ([]*promapiv1.RuleGroup, error) {
+					t.Fatal("Should not call the rule retriever for invalid requests")
+					return nil, nil
+				},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":"error","errorType":"bad_data","error":"group_limit needs to be greater than 0"}`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &Proxy{
 				logger:    log.NewNopLogger(),
-				endpoints: tt.ruleEvaluatorBaseURLs,
+				endpoints: StaticEndpoints(tt.ruleEvaluatorBaseURLs),
 				client:    tt.ruleRetriever,
+				opts:      tt.opts,
 			}
 
-			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+			req := httptest.NewRequest(http.MethodGet, "http://localhost?"+tt.query, nil)
 			w := httptest.NewRecorder()
 			r.RuleGroups(w, req)
 
@@ -35,9 +35,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/GoogleCloudPlatform/prometheus-engine/cmd/frontend/internal/auth"
+	"github.com/GoogleCloudPlatform/prometheus-engine/cmd/frontend/internal/query"
 	"github.com/GoogleCloudPlatform/prometheus-engine/cmd/frontend/internal/rule"
 	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
-	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/secutil"
 	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/ui"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -49,6 +50,9 @@ import (
 	"github.com/prometheus/common/version"
 	"google.golang.org/api/option"
 	apihttp "google.golang.org/api/transport/http"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 const projectIDVar = "PROJECT_ID"
@@ -71,8 +75,77 @@ var (
 	targetURLStr = flag.String("query.target-url", fmt.Sprintf("https://monitoring.googleapis.com/v1/projects/%s/location/global/prometheus", projectIDVar),
 		fmt.Sprintf("The URL to forward authenticated requests to. (%s is replaced with the --query.project-id flag.)", projectIDVar))
 
+	querySplitInterval = flag.Duration("query.split-interval", 24*time.Hour,
+		"Interval along whose boundaries range queries are split into queries that run in parallel and whose results are cached. Splitting and caching is disabled if 0.")
+
+	queryMaxParallelism = flag.Int("query.max-parallelism", 8,
+		"Maximum number of splits of a range query that run in parallel.")
+
+	queryCacheMaxSize = flag.Int("query.cache.max-size-bytes", 100<<20,
+		"Maximum size of the in-memory cache of range query splits. The in-memory cache is disabled if 0.")
+
+	queryCacheMaxFreshness = flag.Duration("query.cache.max-freshness", 10*time.Minute,
+		"Splits of range queries ending within this duration before now are not cached, as their results may still change.")
+
+	queryCacheMemcachedAddresses = flag.String("query.cache.memcached-addresses", "",
+		"Comma separated list of memcached addresses (host:port) to cache range query splits in instead of the in-memory cache.")
+
+	queryCacheMemcachedTimeout = flag.Duration("query.cache.memcached-timeout", 500*time.Millisecond,
+		"Timeout of memcached operations.")
+
+	queryCacheTTL = flag.Duration("query.cache.ttl", 7*24*time.Hour,
+		"Expiration of range query splits cached in memcached.")
+
 	//nolint:revive // Allow insecure http connection
-	ruleEndpointURLStrings = flag.String("rules.target-urls", "http://rule-evaluator.gmp-system.svc.cluster.local:19092", "Comma separated lists of URLs that support HTTP Prometheus Alert and Rules APIs (/api/v1/alerts, /api/v1/rules), e.g. GMP rule-evaluator. NOTE: Results are merged as-is, no sorting and deduplication is done.")
+	ruleEndpointURLStrings = flag.String("rules.target-urls", "http://rule-evaluator.gmp-system.svc.cluster.local:19092", "Comma separated lists of URLs that support HTTP Prometheus Alert and Rules APIs (/api/v1/alerts, /api/v1/rules), e.g. GMP rule-evaluator. URLs with a dns+ or dnssrv+ scheme prefix (e.g. dns+http://rule-evaluator:19092) are expanded to one URL per resolved A/AAAA or SRV record. Ignored if --rules.kube.selector is set. Rule groups with the same file and name and alerts with the same labels are merged and results are sorted.")
+
+	ruleRefreshInterval = flag.Duration("rules.refresh-interval", 30*time.Second,
+		"Interval in which DNS targets of --rules.target-urls are resolved and the EndpointSlices of --rules.kube.selector are listed.")
+
+	ruleKubeSelector = flag.String("rules.kube.selector", "",
+		"If set, label selector of the EndpointSlices of the rule-evaluator pods to use instead of --rules.target-urls, e.g. kubernetes.io/service-name=rule-evaluator.")
+
+	ruleKubeNamespace = flag.String("rules.kube.namespace", "gmp-system",
+		"Namespace of the EndpointSlices selected by --rules.kube.selector.")
+
+	ruleKubePort = flag.String("rules.kube.port", "",
+		"Name of the EndpointSlice port serving the HTTP Prometheus Alert and Rules APIs. Defaults to the first port.")
+
+	ruleKubeScheme = flag.String("rules.kube.scheme", "http",
+		"Scheme of the endpoints discovered through --rules.kube.selector.")
+
+	ruleKubeConfig = flag.String("rules.kube.config", "",
+		"Path to kube config file used for --rules.kube.selector. Defaults to the in-cluster configuration.")
+
+	ruleReplicaLabel = flag.String("rules.replica-label", "",
+		"Label to remove from rules and alerts of the --rules.target-urls endpoints before deduplicating them, e.g. an external label distinguishing replicas.")
+
+	ruleSourceAnnotation = flag.String("rules.source-annotation", "",
+		"If set, annotation of alerts and alerting rules to set to the --rules.target-urls endpoints they were retrieved from.")
+
+	authHtpasswdFile = flag.String("web.auth.htpasswd-file", "",
+		"Path to a file with basic auth users and their bcrypt password hashes in htpasswd format. Changes are picked up every --web.auth.refresh-interval.")
+
+	authOIDCIssuerURL = flag.String("web.auth.oidc.issuer-url", "",
+		"If set, accept OIDC bearer tokens of this issuer.")
+
+	authOIDCJWKSURL = flag.String("web.auth.oidc.jwks-url", "",
+		"URL of the JSON Web Key Set of the OIDC issuer. Discovered through the OpenID configuration of the issuer if empty.")
+
+	authOIDCAudiences = flag.String("web.auth.oidc.audiences", "",
+		"Comma separated list of audiences of which OIDC bearer tokens must have at least one. Audiences are not checked if empty.")
+
+	authOIDCUsernameClaim = flag.String("web.auth.oidc.username-claim", "sub",
+		"Claim of OIDC bearer tokens holding the username.")
+
+	authIAPAudience = flag.String("web.auth.iap.audience", "",
+		"If set, accept Identity-Aware Proxy signed headers with this audience, e.g. /projects/PROJECT_NUMBER/global/backendServices/SERVICE_ID.")
+
+	authRefreshInterval = flag.Duration("web.auth.refresh-interval", time.Minute,
+		"Interval in which the htpasswd file and the authorization config file are reloaded and the keys of the OIDC issuer and IAP are refreshed.")
+
+	authzConfigFile = flag.String("web.auth.authorization-config-file", "",
+		"Path to a file mapping users and groups to the label values they may query. If set, queries and series selectors of restricted users are rewritten to only select these values. Requires authentication.")
 
 	logLevel = flag.String("log.level", "info",
 		"The level of logging. Can be one of 'debug', 'info', 'warn', 'error'")
@@ -138,6 +211,57 @@ func main() {
 		ruleEndpointURLs = append(ruleEndpointURLs, *ruleEndpointURL)
 	}
 
+	var (
+		ruleEndpoints rule.Endpoints = rule.StaticEndpoints(ruleEndpointURLs)
+		ruleDiscovery *rule.Discovery
+	)
+	switch {
+	case *ruleKubeSelector != "":
+		ruleDiscovery, err = newEndpointSliceDiscovery(log.With(logger, "component", "rule-discovery"))
+	case slices.ContainsFunc(ruleEndpointURLs, rule.IsDNSTarget):
+		ruleDiscovery, err = rule.NewDNSDiscovery(log.With(logger, "component", "rule-discovery"), ruleEndpointURLs, *ruleRefreshInterval)
+	}
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "setting up rule endpoint discovery failed", "err", err)
+		os.Exit(1)
+	}
+	if ruleDiscovery != nil {
+		ruleEndpoints = ruleDiscovery
+	}
+
+	var authOIDCAudienceList []string
+	if *authOIDCAudiences != "" {
+		for aud := range strings.SplitSeq(*authOIDCAudiences, ",") {
+			authOIDCAudienceList = append(authOIDCAudienceList, strings.TrimSpace(aud))
+		}
+	}
+	authenticator, err := auth.New(log.With(logger, "component", "auth"), &http.Client{Timeout: 30 * time.Second}, auth.Options{
+		Username:     os.Getenv(authUsernameEnv),
+		Password:     os.Getenv(authPasswordEnv),
+		HtpasswdFile: *authHtpasswdFile,
+		OIDC: auth.JWTOptions{
+			IssuerURL:     *authOIDCIssuerURL,
+			JWKSURL:       *authOIDCJWKSURL,
+			Audiences:     authOIDCAudienceList,
+			UsernameClaim: *authOIDCUsernameClaim,
+		},
+		IAPAudience:     *authIAPAudience,
+		RefreshInterval: *authRefreshInterval,
+	})
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "setting up authentication failed", "err", err)
+		os.Exit(1)
+	}
+	authorizer, err := auth.NewAuthorizer(log.With(logger, "component", "authz"), *authzConfigFile, *authRefreshInterval)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "setting up authorization failed", "err", err)
+		os.Exit(1)
+	}
+	if authorizer.Enabled() && !authenticator.Enabled() {
+		_ = level.Error(logger).Log("msg", "--web.auth.authorization-config-file requires authentication to be configured")
+		os.Exit(1)
+	}
+
 	var g run.Group
 	{
 		term := make(chan os.Signal, 1)
@@ -159,6 +283,30 @@ func main() {
 			},
 		)
 	}
+	if *authHtpasswdFile != "" || *authOIDCIssuerURL != "" || *authIAPAudience != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return authenticator.Run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if authorizer.Enabled() {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return authorizer.Run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if ruleDiscovery != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return ruleDiscovery.Run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
 	{
 		opts := []option.ClientOption{
 			option.WithScopes("https://www.googleapis.com/auth/monitoring.read"),
@@ -176,17 +324,30 @@ func main() {
 		ruleProxy := rule.NewProxy(
 			log.With(logger, "component", "rule-proxy"),
 			&http.Client{Timeout: 30 * time.Second},
-			ruleEndpointURLs,
+			ruleEndpoints,
+			rule.ProxyOptions{
+				ReplicaLabel:     *ruleReplicaLabel,
+				SourceAnnotation: *ruleSourceAnnotation,
+			},
 		)
 
 		server := &http.Server{Addr: *listenAddress}
 		buildInfoHandler := http.HandlerFunc(promapi.BuildinfoHandlerFunc(log.With(logger, "component", "buildinfo-handler"), "frontend", version.Version))
 		http.Handle("/api/v1/status/buildinfo", buildInfoHandler)
 		http.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{Registry: metrics}))
-		http.Handle("/api/v1/rules", authenticate(http.HandlerFunc(ruleProxy.RuleGroups)))
-		http.Handle("/api/v1/rules/", authenticate(http.NotFoundHandler()))
-		http.Handle("/api/v1/alerts", authenticate(http.HandlerFunc(ruleProxy.Alerts)))
-		http.Handle("/api/", authenticate(forward(logger, targetURL, transport)))
+		http.Handle("/api/v1/rules", authenticator.Middleware(authorizer.Middleware(http.HandlerFunc(ruleProxy.RuleGroups))))
+		http.Handle("/api/v1/rules/", authenticator.Middleware(http.NotFoundHandler()))
+		http.Handle("/api/v1/alerts", authenticator.Middleware(authorizer.Middleware(http.HandlerFunc(ruleProxy.Alerts))))
+		forwardHandler := forward(logger, targetURL, transport)
+		if *querySplitInterval > 0 {
+			splitter, err := newQueryRangeSplitter(log.With(logger, "component", "query-range-splitter"), forwardHandler, metrics)
+			if err != nil {
+				_ = level.Error(logger).Log("msg", "setting up range query splitting failed", "err", err)
+				os.Exit(1)
+			}
+			http.Handle("/api/v1/query_range", authenticator.Middleware(authorizer.Middleware(splitter)))
+		}
+		http.Handle("/api/", authenticator.Middleware(authorizer.Middleware(forwardHandler)))
 
 		http.HandleFunc("/-/healthy", func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -197,7 +358,7 @@ func main() {
 			fmt.Fprint(w, "Prometheus frontend is Ready.\n")
 		})
 
-		http.Handle("/", authenticate(ui.Handler(externalURL)))
+		http.Handle("/", authenticator.Middleware(ui.Handler(externalURL)))
 
 		g.Add(func() error {
 			//nolint:errcheck
@@ -221,27 +382,57 @@ func main() {
 	}
 }
 
-func authenticate(next http.Handler) http.Handler {
-	username := os.Getenv(authUsernameEnv)
-	password := os.Getenv(authPasswordEnv)
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if len(username) > 0 && len(password) > 0 {
-			reqUser, reqPass, ok := req.BasicAuth()
-			if !ok {
-				w.Header().Set("WWW-Authenticate", "Basic")
-				w.WriteHeader(http.StatusUnauthorized)
-				return
-			}
-			matchUser := secutil.ConstTimeEqual(reqUser, username)
-			matchPass := secutil.ConstTimeEqual(reqPass, password)
-			if !matchUser || !matchPass {
-				w.WriteHeader(http.StatusForbidden)
-				return
-			}
+func newQueryRangeSplitter(logger log.Logger, next http.Handler, reg prometheus.Registerer) (*query.Splitter, error) {
+	var cache query.Cache
+	switch {
+	case *queryCacheMemcachedAddresses != "":
+		var addrs []string
+		for addr := range strings.SplitSeq(*queryCacheMemcachedAddresses, ",") {
+			addrs = append(addrs, strings.TrimSpace(addr))
+		}
+		var err error
+		if cache, err = query.NewMemcachedCache(logger, addrs, *queryCacheTTL, *queryCacheMemcachedTimeout); err != nil {
+			return nil, err
+		}
+	case *queryCacheMaxSize > 0:
+		cache = query.NewLRUCache(*queryCacheMaxSize)
+	}
+	return query.NewSplitter(logger, next, cache, query.SplitOptions{
+		Interval:       *querySplitInterval,
+		MaxParallelism: *queryMaxParallelism,
+		MaxFreshness:   *queryCacheMaxFreshness,
+	}, reg), nil
+}
+
+func newEndpointSliceDiscovery(logger log.Logger) (*rule.Discovery, error) {
+	cfg, err := loadKubeConfig(*ruleKubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("load kube config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create Kubernetes client: %w", err)
+	}
+	return rule.NewEndpointSliceDiscovery(logger, client, rule.EndpointSliceOptions{
+		Namespace: *ruleKubeNamespace,
+		Selector:  *ruleKubeSelector,
+		PortName:  *ruleKubePort,
+		Scheme:    *ruleKubeScheme,
+	}, *ruleRefreshInterval)
+}
+
+func loadKubeConfig(kubeconfigPath string) (*rest.Config, error) {
+	if kubeconfigPath == "" {
+		cfg, err := rest.InClusterConfig()
+		if err == nil {
+			return cfg, nil
 		}
+		// Fallback to default config.
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfigPath
 
-		next.ServeHTTP(w, req)
-	})
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, nil).ClientConfig()
 }
 
 func forward(logger log.Logger, target *url.URL, transport http.RoundTripper) http.Handler {
@@ -0,0 +1,441 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	"github.com/prometheus/prometheus/google/export"
+	exportsetup "github.com/prometheus/prometheus/google/export/setup"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"golang.org/x/time/rate"
+)
+
+// backfillOptions configures the backfill command, which evaluates recording rules over a
+// past time range and writes the results to GCM.
+type backfillOptions struct {
+	RuleFiles        []string
+	Start            string
+	End              string
+	ChunkSize        time.Duration
+	SamplesPerSecond float64
+	CheckpointFile   string
+	FlushTimeout     time.Duration
+
+	start, end time.Time
+}
+
+func (opts *backfillOptions) setupFlags(cmd *kingpin.CmdClause) {
+	cmd.Arg("rule-file", "Rule files to backfill. Defaults to the rule files of the configuration file.").
+		StringsVar(&opts.RuleFiles)
+
+	cmd.Flag("backfill.start", "Start of the time range to backfill, as RFC 3339 or Unix timestamp. GCM rejects samples older than 25 hours and samples older than the latest sample of a series, so recording rules should be backfilled before they are deployed.").
+		Required().
+		StringVar(&opts.Start)
+
+	cmd.Flag("backfill.end", "End of the time range to backfill, as RFC 3339 or Unix timestamp. Defaults to the current time.").
+		StringVar(&opts.End)
+
+	cmd.Flag("backfill.chunk-size", "Time range of a single range query. Results are written and checkpointed chunk by chunk.").
+		Default(opts.ChunkSize.String()).
+		DurationVar(&opts.ChunkSize)
+
+	cmd.Flag("backfill.samples-per-second", "Maximum rate at which samples are written to GCM.").
+		Default(strconv.FormatFloat(opts.SamplesPerSecond, 'f', -1, 64)).
+		Float64Var(&opts.SamplesPerSecond)
+
+	cmd.Flag("backfill.checkpoint-file", "File to record the progress of the backfill in. If the file exists, the backfill resumes after the last completed chunk of every rule.").
+		PlaceHolder("<FILE>").
+		StringVar(&opts.CheckpointFile)
+
+	cmd.Flag("backfill.flush-timeout", "Maximum time to wait for the samples of a chunk to be sent to GCM.").
+		Default(opts.FlushTimeout.String()).
+		DurationVar(&opts.FlushTimeout)
+}
+
+func (opts *backfillOptions) validate(now time.Time) error {
+	var err error
+	if opts.start, err = promapi.ParseTime(opts.Start); err != nil {
+		return fmt.Errorf("invalid --backfill.start: %w", err)
+	}
+	opts.end = now
+	if opts.End != "" {
+		if opts.end, err = promapi.ParseTime(opts.End); err != nil {
+			return fmt.Errorf("invalid --backfill.end: %w", err)
+		}
+	}
+	if !opts.start.Before(opts.end) {
+		return fmt.Errorf("--backfill.start must be before --backfill.end, got %s and %s", opts.start, opts.end)
+	}
+	if opts.ChunkSize <= 0 {
+		return fmt.Errorf("--backfill.chunk-size must be positive, got %s", opts.ChunkSize)
+	}
+	if opts.SamplesPerSecond <= 0 {
+		return fmt.Errorf("--backfill.samples-per-second must be positive, got %v", opts.SamplesPerSecond)
+	}
+	if opts.FlushTimeout <= 0 {
+		return fmt.Errorf("--backfill.flush-timeout must be positive, got %s", opts.FlushTimeout)
+	}
+	return nil
+}
+
+// runBackfill runs the backfill command with its own exporter.
+func runBackfill(
+	ctx context.Context,
+	logger log.Logger,
+	reg *prometheus.Registry,
+	exportOpts *exportsetup.Opts,
+	evaluatorOpts *evaluatorOptions,
+	opts *backfillOptions,
+) error {
+	contents, err := os.ReadFile(evaluatorOpts.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("read config %q: %w", evaluatorOpts.ConfigFile, err)
+	}
+	cfg, err := loadConfig(contents)
+	if err != nil {
+		return fmt.Errorf("load config %q: %w", evaluatorOpts.ConfigFile, err)
+	}
+	evaluatorOpts, err = evaluatorOpts.withConfig(&cfg.Config)
+	if err != nil {
+		return err
+	}
+
+	ruleFiles := opts.RuleFiles
+	if len(ruleFiles) == 0 {
+		for _, pat := range cfg.RuleFiles {
+			fs, err := filepath.Glob(pat)
+			if fs == nil || err != nil {
+				return fmt.Errorf("retrieving rule file: %s", pat)
+			}
+			ruleFiles = append(ruleFiles, fs...)
+		}
+	}
+
+	ctxExporter, cancelExporter := context.WithCancel(ctx)
+	defer cancelExporter()
+	exporter, err := exportOpts.NewExporter(ctxExporter, logger, reg)
+	if err != nil {
+		return fmt.Errorf("create exporter: %w", err)
+	}
+	destination := export.NewStorage(exporter)
+	if err := destination.ApplyConfig(&cfg.Config); err != nil {
+		return fmt.Errorf("apply exporter config: %w", err)
+	}
+	go func() {
+		if err := destination.Run(); err != nil {
+			_ = level.Error(logger).Log("msg", "Background processing of storage failed", "err", err)
+		}
+	}()
+
+	api, err := newAPI(ctx, evaluatorOpts, version.Version)
+	if err != nil {
+		return fmt.Errorf("query client: %w", err)
+	}
+	b := &backfiller{
+		logger:     logger,
+		api:        api,
+		appendable: histogramAppendable{destination},
+		limiter:    rate.NewLimiter(rate.Limit(opts.SamplesPerSecond), max(1, int(opts.SamplesPerSecond))),
+		retry:      evaluatorOpts.QueryRetry,
+		flush: func(ctx context.Context, sent float64) error {
+			return waitExported(ctx, reg, sent, opts.FlushTimeout)
+		},
+		opts: opts,
+	}
+	return b.run(ctx, time.Duration(cfg.GlobalConfig.EvaluationInterval), ruleFiles)
+}
+
+// backfiller evaluates recording rules with range queries and writes the results.
+type backfiller struct {
+	logger     log.Logger
+	api        v1.API
+	appendable storage.Appendable
+	limiter    *rate.Limiter
+	retry      queryRetryOptions
+	// flush waits until the given number of samples, written since the last call,
+	// were sent.
+	flush func(ctx context.Context, samples float64) error
+	opts  *backfillOptions
+}
+
+func (b *backfiller) run(ctx context.Context, interval time.Duration, files []string) error {
+	manager := rules.NewManager(&rules.ManagerOptions{Logger: b.logger})
+	groups, errs := manager.LoadGroups(interval, labels.EmptyLabels(), "", nil, files...)
+	if len(errs) > 0 {
+		return fmt.Errorf("load rule groups: %w", errors.Join(errs...))
+	}
+
+	checkpoint, err := loadBackfillCheckpoint(b.opts.CheckpointFile, b.opts.start, b.opts.end)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		g := groups[key]
+		for i, r := range g.Rules() {
+			rule, ok := r.(*rules.RecordingRule)
+			if !ok {
+				_ = level.Debug(b.logger).Log("msg", "Skipping alerting rule", "group", g.Name(), "rule", r.Name())
+				continue
+			}
+			ruleKey := fmt.Sprintf("%s;%d;%s", key, i, rule.Name())
+			if err := b.backfillRule(ctx, g, rule, ruleKey, checkpoint); err != nil {
+				return fmt.Errorf("backfill rule %q of group %q: %w", rule.Name(), g.Name(), err)
+			}
+		}
+	}
+	_ = level.Info(b.logger).Log("msg", "Backfill completed", "rules", len(checkpoint.Rules))
+	return nil
+}
+
+// backfillRule evaluates a recording rule chunk by chunk. Evaluation timestamps are aligned
+// to the group interval.
+func (b *backfiller) backfillRule(ctx context.Context, g *rules.Group, rule *rules.RecordingRule, key string, checkpoint *backfillCheckpoint) error {
+	interval := g.Interval()
+	start := b.opts.start.Truncate(interval)
+	if start.Before(b.opts.start) {
+		start = start.Add(interval)
+	}
+	if done, ok := checkpoint.Rules[key]; ok {
+		start = done.Add(interval)
+	}
+	// Each chunk covers at least one evaluation.
+	chunkSize := max(b.opts.ChunkSize, interval)
+
+	for chunkStart := start; !chunkStart.After(b.opts.end); {
+		chunkEnd := chunkStart.Add(chunkSize - interval)
+		if chunkEnd.After(b.opts.end) {
+			chunkEnd = b.opts.end
+		}
+		n, err := b.backfillChunk(ctx, rule, v1.Range{Start: chunkStart, End: chunkEnd, Step: interval})
+		if err != nil {
+			return err
+		}
+		if err := b.flush(ctx, float64(n)); err != nil {
+			return err
+		}
+		if err := checkpoint.done(key, chunkEnd); err != nil {
+			return err
+		}
+		_ = level.Info(b.logger).Log("msg", "Backfilled chunk", "group", g.Name(), "rule", rule.Name(), "start", chunkStart, "end", chunkEnd, "samples", n)
+
+		chunkStart = chunkEnd.Add(interval)
+	}
+	return nil
+}
+
+// backfillChunk evaluates the rule over the given range and writes the results. It returns
+// the number of written samples.
+func (b *backfiller) backfillChunk(ctx context.Context, rule *rules.RecordingRule, r v1.Range) (int, error) {
+	query := rule.Query().String()
+	onRetry := func(err error, backoff time.Duration) {
+		_ = level.Debug(b.logger).Log("msg", "Retrying failed range query", "query", query, "backoff", backoff, "err", err)
+	}
+	res, err := retryQuery(ctx, b.retry, onRetry, func(ctx context.Context) (model.Value, error) {
+		res, warnings, err := b.api.QueryRange(ctx, query, r)
+		if len(warnings) > 0 {
+			_ = level.Warn(b.logger).Log("msg", "Range query returned warnings", "query", query, "warn", warnings)
+		}
+		return res, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("execute range query: %w", err)
+	}
+	v, err := convertModelToPromQLValue(res)
+	if err != nil {
+		return 0, err
+	}
+	matrix, ok := v.(promql.Matrix)
+	if !ok {
+		return 0, fmt.Errorf("range query returned unexpected type %v", v.Type())
+	}
+
+	app := b.appendable.Appender(ctx)
+	n := 0
+	for _, series := range matrix {
+		// Label the results the same way recording rules do.
+		lb := labels.NewBuilder(series.Metric)
+		lb.Set(labels.MetricName, rule.Name())
+		rule.Labels().Range(func(l labels.Label) {
+			lb.Set(l.Name, l.Value)
+		})
+		lset := lb.Labels()
+
+		for _, p := range series.Floats {
+			if err := b.limiter.Wait(ctx); err != nil {
+				return 0, errors.Join(err, app.Rollback())
+			}
+			if _, err := app.Append(0, lset, p.T, p.F); err != nil {
+				return 0, errors.Join(err, app.Rollback())
+			}
+			n++
+		}
+		for _, p := range series.Histograms {
+			if err := b.limiter.Wait(ctx); err != nil {
+				return 0, errors.Join(err, app.Rollback())
+			}
+			if _, err := app.AppendHistogram(0, lset, p.T, nil, p.H); err != nil {
+				return 0, errors.Join(err, app.Rollback())
+			}
+			n++
+		}
+	}
+	return n, app.Commit()
+}
+
+// backfillCheckpoint records until which timestamp every rule was backfilled.
+type backfillCheckpoint struct {
+	Start time.Time            `json:"start"`
+	End   time.Time            `json:"end"`
+	Rules map[string]time.Time `json:"rules"`
+
+	file string
+}
+
+// loadBackfillCheckpoint loads the checkpoint for the given time range from the file. An empty
+// checkpoint is returned if the file doesn't exist. The checkpoint is not persisted if the file
+// is empty.
+func loadBackfillCheckpoint(file string, start, end time.Time) (*backfillCheckpoint, error) {
+	c := &backfillCheckpoint{Start: start, End: end, Rules: map[string]time.Time{}, file: file}
+	if file == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %q: %w", file, err)
+	}
+	if !c.Start.Equal(start) || !c.End.Equal(end) {
+		return nil, fmt.Errorf("checkpoint %q is for time range %s to %s", file, c.Start, c.End)
+	}
+	if c.Rules == nil {
+		c.Rules = map[string]time.Time{}
+	}
+	return c, nil
+}
+
+// done records that the rule with the given key was backfilled until t.
+func (c *backfillCheckpoint) done(key string, t time.Time) error {
+	c.Rules[key] = t
+	if c.file == "" {
+		return nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	// Replace the file atomically so that a crash doesn't leave a partial checkpoint.
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.file); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// waitExported waits until the exporter processed all samples that were passed to it. The
+// exporter doesn't offer a flush, so progress is tracked through its metrics. As the backfill
+// stops on the first failure, any dropped sample or failed request fails the wait.
+func waitExported(ctx context.Context, g prometheus.Gatherer, samples float64, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		stats, err := gatherExportStats(g)
+		if err != nil {
+			return err
+		}
+		if stats.dropped > 0 {
+			return fmt.Errorf("exporter dropped %v samples, consider lowering --backfill.samples-per-second", stats.dropped)
+		}
+		if stats.sendErrors > 0 {
+			return errors.New("sending samples to GCM failed, see exporter logs")
+		}
+		if stats.sent >= stats.exported {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %v samples to be sent: %w", samples, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+type exportStats struct {
+	exported, sent, dropped, sendErrors float64
+}
+
+func gatherExportStats(g prometheus.Gatherer) (exportStats, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return exportStats{}, fmt.Errorf("gather exporter metrics: %w", err)
+	}
+	var s exportStats
+	for _, mf := range mfs {
+		var v *float64
+		switch mf.GetName() {
+		case "gcm_export_samples_exported_total":
+			v = &s.exported
+		case "gcm_export_samples_sent_total":
+			v = &s.sent
+		case "gcm_export_samples_dropped_total":
+			v = &s.dropped
+		case "gcm_export_samples_send_errors_total":
+			v = &s.sendErrors
+		default:
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			*v += m.GetCounter().GetValue()
+		}
+	}
+	return s, nil
+}
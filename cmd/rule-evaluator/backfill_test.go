@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"golang.org/x/time/rate"
+)
+
+// rangeAPI is a v1.API that answers range queries with one series holding the timestamp of
+// every step as value.
+type rangeAPI struct {
+	v1.API
+	queries []string
+	ranges  []v1.Range
+}
+
+func (a *rangeAPI) QueryRange(_ context.Context, query string, r v1.Range, _ ...v1.Option) (model.Value, v1.Warnings, error) {
+	a.queries = append(a.queries, query)
+	a.ranges = append(a.ranges, r)
+
+	series := &model.SampleStream{Metric: model.Metric{"job": "foo"}}
+	for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+		series.Values = append(series.Values, model.SamplePair{
+			Timestamp: model.TimeFromUnixNano(t.UnixNano()),
+			Value:     model.SampleValue(t.Unix()),
+		})
+	}
+	return model.Matrix{series}, nil, nil
+}
+
+func TestBackfill(t *testing.T) {
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: test
+  interval: 1m
+  rules:
+  - record: job:up:sum
+    expr: sum by (job) (up)
+    labels:
+      source: backfill
+  - alert: Down
+    expr: up == 0
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	start := time.Unix(1699999980, 0).UTC() // Aligned to the minute.
+	opts := &backfillOptions{
+		Start:            "1699999980",
+		End:              "1700000280",
+		ChunkSize:        2 * time.Minute,
+		SamplesPerSecond: 1,
+		FlushTimeout:     time.Minute,
+		CheckpointFile:   checkpointFile,
+	}
+	if err := opts.validate(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	newBackfiller := func(api *rangeAPI, app *testAppender, flushed *float64) *backfiller {
+		return &backfiller{
+			logger:     log.NewNopLogger(),
+			api:        api,
+			appendable: testAppendable{app: app},
+			limiter:    rate.NewLimiter(rate.Inf, 1),
+			flush: func(_ context.Context, samples float64) error {
+				*flushed += samples
+				return nil
+			},
+			opts: opts,
+		}
+	}
+
+	api := &rangeAPI{}
+	app := &testAppender{}
+	var flushed float64
+	if err := newBackfiller(api, app, &flushed).run(t.Context(), time.Minute, []string{ruleFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the recording rule is backfilled, in chunks of 2 evaluations.
+	wantRanges := []v1.Range{
+		{Start: start, End: start.Add(time.Minute), Step: time.Minute},
+		{Start: start.Add(2 * time.Minute), End: start.Add(3 * time.Minute), Step: time.Minute},
+		{Start: start.Add(4 * time.Minute), End: start.Add(5 * time.Minute), Step: time.Minute},
+	}
+	if diff := cmp.Diff(wantRanges, api.ranges); diff != "" {
+		t.Errorf("unexpected ranges (-want, +got): %s", diff)
+	}
+	for _, q := range api.queries {
+		if q != "sum by (job) (up)" {
+			t.Errorf("unexpected query %q", q)
+		}
+	}
+
+	var want []testSample
+	for i := range 6 {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		want = append(want, testSample{
+			lset: labels.FromStrings("__name__", "job:up:sum", "job", "foo", "source", "backfill"),
+			t:    ts.UnixMilli(),
+			v:    float64(ts.Unix()),
+		})
+	}
+	if diff := cmp.Diff(want, app.samples, cmp.AllowUnexported(testSample{}), cmp.Comparer(labels.Equal)); diff != "" {
+		t.Errorf("unexpected samples (-want, +got): %s", diff)
+	}
+	if flushed != 6 {
+		t.Errorf("expected 6 flushed samples, got %v", flushed)
+	}
+
+	// A completed backfill is not repeated.
+	api = &rangeAPI{}
+	if err := newBackfiller(api, &testAppender{}, &flushed).run(t.Context(), time.Minute, []string{ruleFile}); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.ranges) != 0 {
+		t.Errorf("expected no queries for completed backfill, got %v", api.ranges)
+	}
+
+	// A partial backfill resumes after the last completed chunk.
+	checkpoint, err := loadBackfillCheckpoint(checkpointFile, opts.start, opts.end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range checkpoint.Rules {
+		if err := checkpoint.done(key, start.Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api = &rangeAPI{}
+	if err := newBackfiller(api, &testAppender{}, &flushed).run(t.Context(), time.Minute, []string{ruleFile}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantRanges[1:], api.ranges); diff != "" {
+		t.Errorf("unexpected ranges after resume (-want, +got): %s", diff)
+	}
+
+	// The checkpoint can't be used for another time range.
+	if _, err := loadBackfillCheckpoint(checkpointFile, opts.start.Add(-time.Hour), opts.end); err == nil {
+		t.Error("expected error for checkpoint of different time range")
+	}
+}
+
+func TestBackfillOptionsValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		name    string
+		opts    backfillOptions
+		wantErr bool
+	}{
+		{
+			name: "end defaults to now",
+			opts: backfillOptions{Start: "2023-11-14T21:00:00Z", ChunkSize: time.Hour, SamplesPerSecond: 1, FlushTimeout: time.Minute},
+		},
+		{
+			name:    "invalid start",
+			opts:    backfillOptions{Start: "yesterday", ChunkSize: time.Hour, SamplesPerSecond: 1, FlushTimeout: time.Minute},
+			wantErr: true,
+		},
+		{
+			name:    "start after end",
+			opts:    backfillOptions{Start: "1700000100", End: "1700000000", ChunkSize: time.Hour, SamplesPerSecond: 1, FlushTimeout: time.Minute},
+			wantErr: true,
+		},
+		{
+			name:    "no rate",
+			opts:    backfillOptions{Start: "1600000000", ChunkSize: time.Hour, FlushTimeout: time.Minute},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.validate(now)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if err == nil && !tc.opts.end.Equal(now) {
+				t.Errorf("expected end %s, got %s", now, tc.opts.end)
+			}
+		})
+	}
+}
+
+func TestWaitExported(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	exported := prometheus.NewCounter(prometheus.CounterOpts{Name: "gcm_export_samples_exported_total"})
+	sent := prometheus.NewCounter(prometheus.CounterOpts{Name: "gcm_export_samples_sent_total"})
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "gcm_export_samples_dropped_total"}, []string{"reason"})
+	reg.MustRegister(exported, sent, dropped)
+
+	exported.Add(10)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		sent.Add(10)
+	}()
+	if err := waitExported(t.Context(), reg, 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	exported.Add(10)
+	if err := waitExported(t.Context(), reg, 10, 300*time.Millisecond); err == nil {
+		t.Error("expected timeout waiting for samples")
+	}
+
+	dropped.WithLabelValues("queue-full").Add(10)
+	if err := waitExported(t.Context(), reg, 10, time.Minute); err == nil {
+		t.Error("expected error for dropped samples")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+)
+
+const alertForStateMetricName = "ALERTS_FOR_STATE"
+
+// forStateQueryable is the queryable of the rules manager, which reads the ALERTS_FOR_STATE
+// series back to restore the 'for' state of alerts.
+//
+// The exporter attaches the external labels, including the default project_id, location
+// and cluster, to all series that don't set them. The restore compares the series with the
+// labels of the active alerts, which don't have these labels unless the rule query
+// returned them, e.g. for non-aggregated GlobalRules queries. ALERTS_FOR_STATE series are
+// thus returned with the labels of the active alert they were written for, if any.
+type forStateQueryable struct {
+	storage.Queryable
+
+	mtx            sync.RWMutex
+	externalLabels labels.Labels
+	alertingRules  []*rules.AlertingRule
+}
+
+// SetExternalLabels sets the labels the exporter attaches to series that don't have them.
+func (q *forStateQueryable) SetExternalLabels(lset labels.Labels) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.externalLabels = lset
+}
+
+// SetAlertingRules sets the alerting rules whose alerts are restored. They are set after
+// rule updates rather than read from the rules manager, which holds its lock while waiting
+// for groups to stop, including groups that are restoring.
+func (q *forStateQueryable) SetAlertingRules(rs []*rules.AlertingRule) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.alertingRules = rs
+}
+
+// Querier implements storage.Queryable.
+func (q *forStateQueryable) Querier(mint, maxt int64) (storage.Querier, error) {
+	querier, err := q.Queryable.Querier(mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	return &forStateQuerier{
+		Querier:        querier,
+		externalLabels: q.externalLabels,
+		alertingRules:  q.alertingRules,
+	}, nil
+}
+
+type forStateQuerier struct {
+	storage.Querier
+
+	externalLabels labels.Labels
+	alertingRules  []*rules.AlertingRule
+}
+
+// Select implements storage.Querier. Restores select series unsorted, sorted selects are
+// passed through as the alert labels may not keep their order.
+func (q *forStateQuerier) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	set := q.Querier.Select(ctx, sortSeries, hints, matchers...)
+	if sortSeries || q.externalLabels.IsEmpty() {
+		return set
+	}
+	var alertName string
+	isForState := false
+	for _, m := range matchers {
+		switch {
+		case m.Type == labels.MatchEqual && m.Name == model.MetricNameLabel:
+			isForState = m.Value == alertForStateMetricName
+		case m.Type == labels.MatchEqual && m.Name == labels.AlertName:
+			alertName = m.Value
+		}
+	}
+	if !isForState || alertName == "" {
+		return set
+	}
+	var alerts []labels.Labels
+	for _, r := range q.alertingRules {
+		if r.Name() != alertName {
+			continue
+		}
+		for _, a := range r.ActiveAlerts() {
+			alerts = append(alerts, a.Labels)
+		}
+	}
+	return &forStateSeriesSet{SeriesSet: set, matchers: matchers, externalLabels: q.externalLabels, alerts: alerts}
+}
+
+type forStateSeriesSet struct {
+	storage.SeriesSet
+
+	matchers       []*labels.Matcher
+	externalLabels labels.Labels
+	alerts         []labels.Labels
+}
+
+func (s *forStateSeriesSet) At() storage.Series {
+	series := s.SeriesSet.At()
+	lset, ok := s.alertLabels(series.Labels())
+	if !ok {
+		return series
+	}
+	return &labeledSeries{Series: series, lset: lset}
+}
+
+// alertLabels returns the series labels with the labels of the active alert the series
+// was written for. These are the series labels without the external labels that the alert
+// lacks, and that aren't constrained by a matcher and hold the external label value. If
+// several alerts match, the one with most labels is the one the series was written for.
+func (s *forStateSeriesSet) alertLabels(lset labels.Labels) (labels.Labels, bool) {
+	metricName := lset.Get(model.MetricNameLabel)
+	lset = lset.DropMetricName()
+
+	var (
+		best  labels.Labels
+		found bool
+	)
+	for _, alert := range s.alerts {
+		if found && alert.Len() <= best.Len() {
+			continue
+		}
+		if s.exportedFrom(lset, alert) {
+			best, found = alert, true
+		}
+	}
+	if !found {
+		return labels.EmptyLabels(), false
+	}
+	b := labels.NewBuilder(best)
+	b.Set(model.MetricNameLabel, metricName)
+	return b.Labels(), true
+}
+
+// exportedFrom returns whether the exporter writes the series labels for the alert labels.
+func (s *forStateSeriesSet) exportedFrom(lset, alert labels.Labels) bool {
+	ok := true
+	alert.Range(func(l labels.Label) {
+		if lset.Get(l.Name) != l.Value {
+			ok = false
+		}
+	})
+	lset.Range(func(l labels.Label) {
+		if alert.Has(l.Name) {
+			return
+		}
+		constrained := slices.ContainsFunc(s.matchers, func(m *labels.Matcher) bool {
+			return m.Name == l.Name
+		})
+		if constrained || s.externalLabels.Get(l.Name) != l.Value {
+			ok = false
+		}
+	})
+	return ok
+}
+
+// labeledSeries is a series with other labels.
+type labeledSeries struct {
+	storage.Series
+	lset labels.Labels
+}
+
+func (s *labeledSeries) Labels() labels.Labels {
+	return s.lset
+}
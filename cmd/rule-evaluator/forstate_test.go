@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+func TestForStateSeriesSet(t *testing.T) {
+	forState := func(lset ...string) labels.Labels {
+		return labels.FromStrings(append([]string{model.MetricNameLabel, alertForStateMetricName}, lset...)...)
+	}
+	alerts := []labels.Labels{
+		labels.FromStrings("alertname", "Down", "job", "scoped"),
+		labels.FromStrings("alertname", "Down", "job", "global", "project_id", "p1", "location", "l1"),
+		labels.FromStrings("alertname", "Down", "job", "both"),
+		labels.FromStrings("alertname", "Down", "job", "both", "project_id", "p1"),
+	}
+	var m promql.Matrix
+	for _, lset := range []labels.Labels{
+		// Export labels attached to an alert without them.
+		forState("alertname", "Down", "job", "scoped", "project_id", "p1", "location", "l1", "cluster", "c1"),
+		// Export labels partially returned by the rule query.
+		forState("alertname", "Down", "job", "global", "project_id", "p1", "location", "l1", "cluster", "c1"),
+		// The alert with the most matching labels is the one the series was written for.
+		forState("alertname", "Down", "job", "both", "project_id", "p1", "location", "l1", "cluster", "c1"),
+		// Labels that aren't external labels or hold other values are never dropped.
+		forState("alertname", "Down", "job", "scoped", "project_id", "p2"),
+		forState("alertname", "Down", "job", "scoped", "team", "a"),
+		// Labels of the matchers are never dropped.
+		forState("alertname", "Down", "job", "scoped", "severity", "page"),
+	} {
+		m = append(m, promql.Series{Metric: lset, Floats: []promql.FPoint{{T: 1000, F: 1}}})
+	}
+
+	set := &forStateSeriesSet{
+		SeriesSet: newListSeriesSet(m, nil, nil),
+		matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, alertForStateMetricName),
+			labels.MustNewMatcher(labels.MatchEqual, "alertname", "Down"),
+			labels.MustNewMatcher(labels.MatchEqual, "severity", "page"),
+		},
+		externalLabels: labels.FromStrings("cluster", "c1", "location", "l1", "project_id", "p1", "severity", "page"),
+		alerts:         alerts,
+	}
+	var got []labels.Labels
+	for set.Next() {
+		got = append(got, set.At().Labels())
+	}
+	want := []labels.Labels{
+		forState("alertname", "Down", "job", "scoped"),
+		forState("alertname", "Down", "job", "global", "project_id", "p1", "location", "l1"),
+		forState("alertname", "Down", "job", "both", "project_id", "p1"),
+		forState("alertname", "Down", "job", "scoped", "project_id", "p2"),
+		forState("alertname", "Down", "job", "scoped", "team", "a"),
+		forState("alertname", "Down", "job", "scoped", "severity", "page"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected labels (-want, +got): %s", diff)
+	}
+}
+
+func TestForStateQuerierPassesThroughOtherSelects(t *testing.T) {
+	lset := labels.FromStrings(model.MetricNameLabel, "up", "project_id", "p1")
+	q := &forStateQuerier{
+		Querier: &queryAccess{
+			mint: 1,
+			maxt: 2,
+			query: func(context.Context, string, time.Time, v1.API) (parser.Value, v1.Warnings, error) {
+				return promql.Matrix{{Metric: lset, Floats: []promql.FPoint{{T: 1000, F: 1}}}}, nil, nil
+			},
+		},
+		externalLabels: labels.FromStrings("project_id", "p1"),
+	}
+	got := expandSeriesSet(q.Select(t.Context(), false, nil, labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, "up")))
+	want := promql.Matrix{{Metric: lset, Floats: []promql.FPoint{{T: 1000, F: 1}}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want, +got): %s", diff)
+	}
+}
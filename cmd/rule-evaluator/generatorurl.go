@@ -0,0 +1,182 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	// generatorURLPresetGoogleCloud links alerts to the Cloud Console metrics explorer.
+	generatorURLPresetGoogleCloud = "google_cloud"
+	// generatorURLPresetPrometheus links alerts to the table view of a Prometheus frontend
+	// at the generator URL.
+	generatorURLPresetPrometheus = "prometheus"
+)
+
+// generatorURLConfig selects how the generator URL of alerts, which links back to the
+// query of the alerting rule, is built. At most one of the fields may be set. If none is
+// set, the preset follows from the generator URL: Cloud Console links for the default
+// and Prometheus frontend links for any other URL.
+type generatorURLConfig struct {
+	// Preset is the name of a built-in generator URL.
+	Preset string `yaml:"preset,omitempty"`
+	// Template is a Go template executed with generatorURLData.
+	Template string `yaml:"template,omitempty"`
+}
+
+// generatorURLData is the data generator URL templates are executed with.
+type generatorURLData struct {
+	// GeneratorURL is the generator URL of the flags or the google_cloud section.
+	GeneratorURL string
+	// Expr is the query of the alerting rule.
+	Expr      string
+	ProjectID string
+	// StartTime and EndTime are the hour before the alert fired.
+	StartTime time.Time
+	EndTime   time.Time
+	// RuleName is the name of the alerting rule.
+	RuleName string
+	// Group and File identify the rule group of the alerting rule.
+	Group string
+	File  string
+	// Labels are the labels of the alert.
+	Labels map[string]string
+}
+
+var generatorURLFuncs = template.FuncMap{
+	"queryEscape": url.QueryEscape,
+	"pathEscape":  url.PathEscape,
+	"unixMilli":   func(t time.Time) int64 { return t.UnixMilli() },
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// generatorURL builds the generator URL of alerts.
+type generatorURL struct {
+	preset string
+	tmpl   *template.Template
+}
+
+func newGeneratorURL(cfg generatorURLConfig) (*generatorURL, error) {
+	if cfg.Preset != "" && cfg.Template != "" {
+		return nil, errors.New("only one of preset and template may be set")
+	}
+	switch cfg.Preset {
+	case "", generatorURLPresetGoogleCloud, generatorURLPresetPrometheus:
+	default:
+		return nil, fmt.Errorf("unknown preset %q, must be one of %q or %q", cfg.Preset, generatorURLPresetGoogleCloud, generatorURLPresetPrometheus)
+	}
+	g := &generatorURL{preset: cfg.Preset}
+	if cfg.Template != "" {
+		tmpl, err := template.New("generator_url").Option("missingkey=zero").Funcs(generatorURLFuncs).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		g.tmpl = tmpl
+	}
+	return g, nil
+}
+
+// url returns the generator URL for the data. It is empty if the preset lacks the
+// generator URL or project ID it needs.
+func (g *generatorURL) url(data *generatorURLData) (string, error) {
+	if g.tmpl != nil {
+		var b strings.Builder
+		if err := g.tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	preset := g.preset
+	if preset == "" {
+		if data.GeneratorURL == "" {
+			return "", nil
+		}
+		preset = generatorURLPresetPrometheus
+		if data.GeneratorURL == googleCloudBaseURL.String() {
+			preset = generatorURLPresetGoogleCloud
+		}
+	}
+	switch preset {
+	case generatorURLPresetGoogleCloud:
+		// Project ID is empty when the rule-evaluator is instantiated, before config-reloader runs.
+		if data.ProjectID == "" {
+			return "", nil
+		}
+		return googleCloudLink(data.ProjectID, data.Expr, data.EndTime, data.StartTime).String(), nil
+	case generatorURLPresetPrometheus:
+		if data.GeneratorURL == "" {
+			return "", nil
+		}
+		return data.GeneratorURL + strutil.TableLinkForExpression(data.Expr), nil
+	}
+	return "", nil
+}
+
+// newGeneratorURLData returns the template data for an alert of the rule group, which is
+// nil if unknown, e.g. for alerts resent on shutdown. The project ID is the one the rule
+// group queries, which query overrides may change.
+func newGeneratorURLData(opts *evaluatorOptions, overrides *queryOverrides, group *ruleGroupRef, expr string, alert *rules.Alert) *generatorURLData {
+	data := &generatorURLData{
+		Expr:      expr,
+		ProjectID: opts.ProjectID,
+		StartTime: alert.FiredAt.Add(-time.Hour),
+		EndTime:   alert.FiredAt,
+		RuleName:  alert.Labels.Get(labels.AlertName),
+		Labels:    alert.Labels.Map(),
+	}
+	if opts.GeneratorURL != nil {
+		data.GeneratorURL = opts.GeneratorURL.String()
+	}
+	if group != nil {
+		data.Group, data.File = group.name, group.file
+		if projectID := overrides.projectIDForGroup(group.file, group.name); projectID != "" {
+			data.ProjectID = projectID
+		}
+	}
+	return data
+}
+
+// ruleGroupRef identifies the rule group that is evaluated.
+type ruleGroupRef struct {
+	file, name string
+}
+
+type ruleGroupKey struct{}
+
+// withRuleGroup returns a context of an evaluation of the given rule group.
+func withRuleGroup(ctx context.Context, g *rules.Group) context.Context {
+	return context.WithValue(ctx, ruleGroupKey{}, &ruleGroupRef{file: g.File(), name: g.Name()})
+}
+
+// ruleGroupFromContext returns the evaluated rule group of the context, or nil.
+func ruleGroupFromContext(ctx context.Context) *ruleGroupRef {
+	g, _ := ctx.Value(ruleGroupKey{}).(*ruleGroupRef)
+	return g
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/version"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+)
+
+func TestGeneratorURL(t *testing.T) {
+	firedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	alert := &rules.Alert{
+		Labels:  labels.FromStrings(labels.AlertName, "HighErrorRate", "job", "api"),
+		FiredAt: firedAt,
+	}
+	group := &ruleGroupRef{file: "/etc/rules/rules__ns__name.yaml", name: "errors"}
+	frontendURL := Must(url.Parse("http://frontend:9090"))
+	googleCloudURL := googleCloudBaseURL
+
+	for _, tc := range []struct {
+		desc    string
+		cfg     generatorURLConfig
+		opts    evaluatorOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "default google cloud",
+			opts: evaluatorOptions{GeneratorURL: &googleCloudURL, ProjectID: "my-project"},
+			want: googleCloudLink("my-project", "up == 0", firedAt, firedAt.Add(-time.Hour)).String(),
+		},
+		{
+			desc: "default google cloud without project",
+			opts: evaluatorOptions{GeneratorURL: &googleCloudURL},
+		},
+		{
+			desc: "default prometheus",
+			opts: evaluatorOptions{GeneratorURL: frontendURL, ProjectID: "my-project"},
+			want: "http://frontend:9090/graph?g0.expr=up+%3D%3D+0&g0.tab=1",
+		},
+		{
+			desc: "no generator URL",
+			opts: evaluatorOptions{ProjectID: "my-project"},
+		},
+		{
+			desc: "google cloud preset",
+			cfg:  generatorURLConfig{Preset: generatorURLPresetGoogleCloud},
+			opts: evaluatorOptions{GeneratorURL: frontendURL, ProjectID: "my-project"},
+			want: googleCloudLink("my-project", "up == 0", firedAt, firedAt.Add(-time.Hour)).String(),
+		},
+		{
+			desc: "prometheus preset",
+			cfg:  generatorURLConfig{Preset: generatorURLPresetPrometheus},
+			opts: evaluatorOptions{GeneratorURL: frontendURL},
+			want: "http://frontend:9090/graph?g0.expr=up+%3D%3D+0&g0.tab=1",
+		},
+		{
+			desc: "template",
+			cfg: generatorURLConfig{
+				Template: `{{ .GeneratorURL }}/explore?left={{ printf "{\"queries\":[{\"expr\":%s}],\"range\":{\"from\":\"%d\",\"to\":\"%d\"}}" (json .Expr) (unixMilli .StartTime) (unixMilli .EndTime) | queryEscape }}` +
+					`&rule={{ .RuleName }}&group={{ .Group }}&project={{ .ProjectID }}&job={{ .Labels.job }}`,
+			},
+			opts: evaluatorOptions{GeneratorURL: frontendURL, ProjectID: "my-project"},
+			want: "http://frontend:9090/explore?left=%7B%22queries%22%3A%5B%7B%22expr%22%3A%22up+%3D%3D+0%22%7D%5D%2C%22range%22%3A%7B%22from%22%3A%221767319445000%22%2C%22to%22%3A%221767323045000%22%7D%7D" +
+				"&rule=HighErrorRate&group=errors&project=my-project&job=api",
+		},
+		{
+			desc:    "template execution error",
+			cfg:     generatorURLConfig{Template: `{{ .Unknown }}`},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			gen, err := newGeneratorURL(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := gen.url(newGeneratorURLData(&tc.opts, newQueryOverrides(t.Context(), version.Version), group, "up == 0", alert))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected URL %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGeneratorURLQueryOverride(t *testing.T) {
+	firedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	alert := &rules.Alert{
+		Labels:  labels.FromStrings(labels.AlertName, "HighErrorRate"),
+		FiredAt: firedAt,
+	}
+	googleCloudURL := googleCloudBaseURL
+	opts := &evaluatorOptions{
+		DisableAuth:  true,
+		TargetURL:    Must(url.Parse("https://monitoring.googleapis.com/v1/projects/" + projectIDVar + "/location/global/prometheus")),
+		ProjectID:    "cluster",
+		GeneratorURL: &googleCloudURL,
+	}
+	overrides := newQueryOverrides(t.Context(), version.Version)
+	if err := overrides.ApplyConfig([]queryOverride{
+		{Group: "central", ProjectID: "central"},
+		{Group: "target", TargetURL: "http://thanos:9090"},
+	}, opts); err != nil {
+		t.Fatal(err)
+	}
+	gen, err := newGeneratorURL(generatorURLConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		group         *ruleGroupRef
+		wantProjectID string
+	}{
+		{group: &ruleGroupRef{file: "rules.yaml", name: "central"}, wantProjectID: "central"},
+		{group: &ruleGroupRef{file: "rules.yaml", name: "target"}, wantProjectID: "cluster"},
+		{group: &ruleGroupRef{file: "rules.yaml", name: "other"}, wantProjectID: "cluster"},
+		{wantProjectID: "cluster"},
+	} {
+		got, err := gen.url(newGeneratorURLData(opts, overrides, tc.group, "up == 0", alert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := googleCloudLink(tc.wantProjectID, "up == 0", firedAt, firedAt.Add(-time.Hour)).String()
+		if got != want {
+			t.Errorf("group %v: expected URL %q, got %q", tc.group, want, got)
+		}
+	}
+}
+
+func TestGeneratorURLConfigInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		cfg  generatorURLConfig
+	}{
+		{desc: "unknown preset", cfg: generatorURLConfig{Preset: "grafana"}},
+		{desc: "preset and template", cfg: generatorURLConfig{Preset: generatorURLPresetPrometheus, Template: "http://grafana"}},
+		{desc: "invalid template", cfg: generatorURLConfig{Template: "{{ .Expr "}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := newGeneratorURL(tc.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
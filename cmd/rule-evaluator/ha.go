@@ -0,0 +1,654 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/utils/ptr"
+)
+
+// High availability modes of the rule-evaluator.
+const (
+	// haModeNone evaluates all rule groups, writes all results and sends all alerts.
+	haModeNone = "none"
+	// haModeLeader evaluates all rule groups on all replicas, but only the replica holding
+	// a Kubernetes Lease writes results and sends alerts. The other replicas keep their alert
+	// state up-to-date so that they can take over without restoring it.
+	haModeLeader = "leader"
+	// haModeShard distributes rule groups across all live replicas. Each replica renews
+	// its own Kubernetes Lease and rule groups are assigned through rendezvous hashing over
+	// the replicas with a valid lease.
+	haModeShard = "shard"
+)
+
+// shardLabel is set on the membership leases of a group of sharded replicas. Its value is
+// the --rules.ha.kube.name flag value.
+const shardLabel = "monitoring.googleapis.com/rule-evaluator-shard"
+
+// haOptions configures how multiple rule-evaluator replicas coordinate.
+type haOptions struct {
+	Mode           string
+	KubeConfigFile string
+	KubeNamespace  string
+	KubeName       string
+	Identity       string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+func (opts *haOptions) setupFlags(a *kingpin.Application) {
+	a.Flag("rules.ha.mode", fmt.Sprintf("How rule-evaluator replicas coordinate. %q writes results and sends alerts only on the replica holding a Kubernetes Lease. %q distributes rule groups across replicas by consistent hashing. Can be overridden by the configuration file.", haModeLeader, haModeShard)).
+		Default(opts.Mode).
+		EnumVar(&opts.Mode, haModeNone, haModeLeader, haModeShard)
+	a.Flag("rules.ha.kube.config", "Path to kube config file. Defaults to the in-cluster configuration.").
+		Default(opts.KubeConfigFile).
+		StringVar(&opts.KubeConfigFile)
+	a.Flag("rules.ha.kube.namespace", "Namespace of the HA Lease resources. Must be identical across replicas. May be set through the KUBE_NAMESPACE environment variable.").
+		Default(opts.KubeNamespace).
+		OverrideDefaultFromEnvar("KUBE_NAMESPACE").
+		StringVar(&opts.KubeNamespace)
+	a.Flag("rules.ha.kube.name", "Name of the HA Lease resource, or name prefix of the membership Lease resources when sharding. Must be identical across replicas.").
+		Default(opts.KubeName).
+		StringVar(&opts.KubeName)
+	a.Flag("rules.ha.identity", "Unique identity of the replica. Defaults to the hostname, i.e. the Pod name.").
+		Default(opts.Identity).
+		StringVar(&opts.Identity)
+	a.Flag("rules.ha.lease-duration", "Duration after which the lease of a replica that stopped renewing it expires.").
+		Default(opts.LeaseDuration.String()).
+		DurationVar(&opts.LeaseDuration)
+	a.Flag("rules.ha.renew-deadline", "Duration that the leader retries refreshing the lease before giving up.").
+		Default(opts.RenewDeadline.String()).
+		DurationVar(&opts.RenewDeadline)
+	a.Flag("rules.ha.retry-period", "Duration between lease renewals and acquisition attempts.").
+		Default(opts.RetryPeriod.String()).
+		DurationVar(&opts.RetryPeriod)
+}
+
+func (opts *haOptions) validate() error {
+	if opts.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("get hostname for --rules.ha.identity: %w", err)
+		}
+		opts.Identity = hostname
+	}
+	if opts.LeaseDuration <= opts.RenewDeadline {
+		return fmt.Errorf("--rules.ha.lease-duration (%s) must be greater than --rules.ha.renew-deadline (%s)", opts.LeaseDuration, opts.RenewDeadline)
+	}
+	if opts.RetryPeriod <= 0 || opts.RenewDeadline <= opts.RetryPeriod {
+		return fmt.Errorf("--rules.ha.retry-period (%s) must be positive and smaller than --rules.ha.renew-deadline (%s)", opts.RetryPeriod, opts.RenewDeadline)
+	}
+	return nil
+}
+
+// haConfig is the high availability section of the configuration file.
+type haConfig struct {
+	// Mode overrides the --rules.ha.mode flag if set.
+	Mode string `yaml:"mode,omitempty"`
+}
+
+type haMetrics struct {
+	leader            prometheus.Gauge
+	leaderTransitions prometheus.Counter
+	members           prometheus.Gauge
+	ownedGroups       prometheus.Gauge
+	groupHandovers    *prometheus.CounterVec
+}
+
+func newHAMetrics(reg prometheus.Registerer) *haMetrics {
+	m := &haMetrics{
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rule_evaluator_ha_leader",
+			Help: "Whether this replica currently holds the leader lease. Always 0 if not running in leader mode.",
+		}),
+		leaderTransitions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rule_evaluator_ha_leader_transitions_total",
+			Help: "Number of times this replica acquired or lost the leader lease.",
+		}),
+		members: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rule_evaluator_ha_members",
+			Help: "Number of live replicas rule groups are sharded across. Always 0 if not running in shard mode, or if this replica did not sync the members yet and evaluates no rule groups.",
+		}),
+		ownedGroups: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rule_evaluator_ha_owned_groups",
+			Help: "Number of rule groups evaluated by this replica.",
+		}),
+		groupHandovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rule_evaluator_ha_group_handovers_total",
+			Help: "Number of times this replica acquired or released the ownership of a rule group.",
+		}, []string{"change"}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.leader, m.leaderTransitions, m.members, m.ownedGroups, m.groupHandovers)
+	}
+	return m
+}
+
+// haCoordinator decides which rule groups a replica evaluates and whether it writes results
+// and sends alerts. The mode can be changed at runtime through the configuration file.
+type haCoordinator struct {
+	ctx     context.Context
+	logger  log.Logger
+	opts    haOptions
+	metrics *haMetrics
+	// newClient returns the Kubernetes client used for leases.
+	newClient func() (kubernetes.Interface, error)
+
+	// applyMtx serializes mode switches, which make Kubernetes calls before taking mtx.
+	applyMtx sync.Mutex
+
+	mtx     sync.RWMutex
+	mode    string
+	cancel  context.CancelFunc
+	done    chan struct{}
+	elector *leaderelection.LeaderElector
+	members *shardMembers
+	// owned holds whether a rule group, by group key, was owned in its last evaluation.
+	owned map[string]bool
+}
+
+func newHACoordinator(ctx context.Context, logger log.Logger, reg prometheus.Registerer, opts haOptions) *haCoordinator {
+	return &haCoordinator{
+		ctx:     ctx,
+		logger:  logger,
+		opts:    opts,
+		metrics: newHAMetrics(reg),
+		newClient: func() (kubernetes.Interface, error) {
+			cfg, err := loadKubeConfig(opts.KubeConfigFile)
+			if err != nil {
+				return nil, fmt.Errorf("load kube config: %w", err)
+			}
+			return kubernetes.NewForConfig(cfg)
+		},
+		mode:  haModeNone,
+		owned: map[string]bool{},
+	}
+}
+
+func loadKubeConfig(kubeconfigPath string) (*rest.Config, error) {
+	if kubeconfigPath == "" {
+		cfg, err := rest.InClusterConfig()
+		if err == nil {
+			return cfg, nil
+		}
+		// Fallback to default config.
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfigPath
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, nil).ClientConfig()
+}
+
+// ApplyConfig switches to the configured mode, or the --rules.ha.mode flag value if unset.
+func (c *haCoordinator) ApplyConfig(cfg haConfig) error {
+	mode := c.opts.Mode
+	if cfg.Mode != "" {
+		mode = cfg.Mode
+	}
+	if mode == "" {
+		mode = haModeNone
+	}
+
+	c.applyMtx.Lock()
+	defer c.applyMtx.Unlock()
+
+	c.mtx.RLock()
+	current := c.mode
+	c.mtx.RUnlock()
+	if mode == current {
+		return nil
+	}
+
+	var (
+		elector *leaderelection.LeaderElector
+		members *shardMembers
+		run     func(context.Context)
+	)
+	switch mode {
+	case haModeNone:
+	case haModeLeader, haModeShard:
+		if c.opts.KubeNamespace == "" || c.opts.KubeName == "" {
+			return errors.New("--rules.ha.kube.namespace and --rules.ha.kube.name are required for high availability")
+		}
+		client, err := c.newClient()
+		if err != nil {
+			return fmt.Errorf("create Kubernetes client: %w", err)
+		}
+		if mode == haModeLeader {
+			elector, err = c.newLeaderElector(client)
+			if err != nil {
+				return fmt.Errorf("create leader elector: %w", err)
+			}
+			run = func(ctx context.Context) {
+				// The elector blocks until it acquired the lease once but exits
+				// when losing it. Thus we need to run it in a loop.
+				for ctx.Err() == nil {
+					elector.Run(ctx)
+				}
+			}
+		} else {
+			members = newShardMembers(c.logger, client, c.opts, c.metrics)
+			// Sync once upfront so that groups are evaluated right away. This happens
+			// before taking the lock, so that evaluations aren't blocked by Kubernetes calls.
+			if err := members.sync(c.ctx); err != nil {
+				_ = level.Warn(c.logger).Log("msg", "Initial sync of shard members failed, no rule groups are evaluated until it succeeds", "err", err)
+			}
+			run = members.run
+		}
+	default:
+		return fmt.Errorf("unknown high availability mode %q", mode)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.cancel, c.done = nil, nil
+	c.metrics.leader.Set(0)
+	c.metrics.members.Set(0)
+
+	_ = level.Info(c.logger).Log("msg", "Switching high availability mode", "from", c.mode, "to", mode)
+	c.mode, c.elector, c.members = mode, elector, members
+	if run != nil {
+		ctx, cancel := context.WithCancel(c.ctx)
+		done := make(chan struct{})
+		c.cancel, c.done = cancel, done
+		go func() {
+			defer close(done)
+			run(ctx)
+		}()
+	}
+	return nil
+}
+
+func (c *haCoordinator) newLeaderElector(client kubernetes.Interface) (*leaderelection.LeaderElector, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: c.opts.KubeNamespace,
+			Name:      c.opts.KubeName,
+		},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: c.opts.Identity},
+	}
+	// OnStoppedLeading is also called when the lease was never acquired.
+	var leading atomic.Bool
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: c.opts.LeaseDuration,
+		RenewDeadline: c.opts.RenewDeadline,
+		RetryPeriod:   c.opts.RetryPeriod,
+		// Hand over quickly on shutdown. Unlike exported samples, duplicate or missing
+		// rule evaluations around a handover are tolerable.
+		ReleaseOnCancel: true,
+		Name:            c.opts.KubeName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				_ = level.Info(c.logger).Log("msg", "Acquired leader lease", "identity", c.opts.Identity)
+				leading.Store(true)
+				c.metrics.leader.Set(1)
+				c.metrics.leaderTransitions.Inc()
+			},
+			OnStoppedLeading: func() {
+				if !leading.Swap(false) {
+					return
+				}
+				_ = level.Info(c.logger).Log("msg", "Lost leader lease", "identity", c.opts.Identity)
+				c.metrics.leader.Set(0)
+				c.metrics.leaderTransitions.Inc()
+			},
+			OnNewLeader: func(identity string) {
+				_ = level.Debug(c.logger).Log("msg", "Observed new leader", "leader", identity)
+			},
+		},
+	})
+}
+
+// Stop stops participating in leader election or sharding. It waits until the leader lease
+// is released or the membership lease is deleted.
+func (c *haCoordinator) Stop() {
+	c.mtx.Lock()
+	cancel, done := c.cancel, c.done
+	c.mtx.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// active returns whether the replica writes rule results and sends alerts.
+func (c *haCoordinator) active() bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.mode == haModeLeader {
+		return c.elector.IsLeader()
+	}
+	return true
+}
+
+// ownsGroup returns whether the replica evaluates the rule group with the given key.
+func (c *haCoordinator) ownsGroup(key string) bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.mode == haModeShard {
+		return c.members.owns(key)
+	}
+	return true
+}
+
+// evalIterationFunc implements rules.GroupEvalIterationFunc. Rule groups owned by another
+// replica are skipped. When the ownership of a group moves to this replica, the alert state
+// written by the previous owner is restored after the first evaluation.
+func (c *haCoordinator) evalIterationFunc(ctx context.Context, g *rules.Group, evalTimestamp time.Time) {
+	key := rules.GroupKey(g.File(), g.Name())
+	owns := c.ownsGroup(key)
+
+	c.mtx.Lock()
+	owned, seen := c.owned[key]
+	c.owned[key] = owns
+	c.updateOwnedGroupsLocked()
+	c.mtx.Unlock()
+
+	switch {
+	case seen && owns && !owned:
+		c.metrics.groupHandovers.WithLabelValues("acquired").Inc()
+	case seen && !owns && owned:
+		c.metrics.groupHandovers.WithLabelValues("released").Inc()
+	}
+	if !owns {
+		return
+	}
+	rules.DefaultEvalIterationFunc(ctx, g, evalTimestamp)
+
+	if seen && !owned {
+		_ = level.Info(c.logger).Log("msg", "Acquired rule group, restoring alert state", "group", key)
+		g.RestoreForState(time.Now())
+	}
+}
+
+// syncGroups forgets the ownership of rule groups that no longer exist.
+func (c *haCoordinator) syncGroups(groups []*rules.Group) {
+	keys := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		keys[rules.GroupKey(g.File(), g.Name())] = struct{}{}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for key := range c.owned {
+		if _, ok := keys[key]; !ok {
+			delete(c.owned, key)
+		}
+	}
+	c.updateOwnedGroupsLocked()
+}
+
+func (c *haCoordinator) updateOwnedGroupsLocked() {
+	var n int
+	for _, owns := range c.owned {
+		if owns {
+			n++
+		}
+	}
+	c.metrics.ownedGroups.Set(float64(n))
+}
+
+// shardMembers maintains the membership lease of a replica and tracks the replicas with
+// valid leases.
+type shardMembers struct {
+	logger  log.Logger
+	client  kubernetes.Interface
+	opts    haOptions
+	metrics *haMetrics
+
+	mtx     sync.RWMutex
+	members []string
+}
+
+func newShardMembers(logger log.Logger, client kubernetes.Interface, opts haOptions, metrics *haMetrics) *shardMembers {
+	return &shardMembers{
+		logger:  logger,
+		client:  client,
+		opts:    opts,
+		metrics: metrics,
+	}
+}
+
+func (m *shardMembers) leaseName() string {
+	return fmt.Sprintf("%s-%s", m.opts.KubeName, m.opts.Identity)
+}
+
+// run renews the membership lease until the context is canceled and deletes it afterwards,
+// so that the other replicas take over the rule groups right away.
+func (m *shardMembers) run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.RetryPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), m.opts.RenewDeadline)
+			defer cancel()
+			err := m.client.CoordinationV1().Leases(m.opts.KubeNamespace).Delete(ctx, m.leaseName(), metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				_ = level.Warn(m.logger).Log("msg", "Deleting shard membership lease failed", "err", err)
+			}
+			return
+		case <-ticker.C:
+			if err := m.sync(ctx); err != nil {
+				_ = level.Warn(m.logger).Log("msg", "Syncing shard members failed", "err", err, "participating", m.participating())
+			}
+		}
+	}
+}
+
+// sync renews the own membership lease and updates the live members. If renewal or listing
+// fails, the previously observed members are kept. Leases of replicas that stopped without
+// deleting them, e.g. crashed pods, are deleted once they expired for another lease
+// duration.
+func (m *shardMembers) sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.RenewDeadline)
+	defer cancel()
+
+	now := metav1.NewMicroTime(time.Now())
+	leases := m.client.CoordinationV1().Leases(m.opts.KubeNamespace)
+
+	lease, err := leases.Get(ctx, m.leaseName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: m.opts.KubeNamespace,
+				Name:      m.leaseName(),
+				Labels:    map[string]string{shardLabel: m.opts.KubeName},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(m.opts.Identity),
+				LeaseDurationSeconds: ptr.To(int32(m.opts.LeaseDuration.Seconds())),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create lease: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("get lease: %w", err)
+	default:
+		lease.Spec.HolderIdentity = ptr.To(m.opts.Identity)
+		lease.Spec.LeaseDurationSeconds = ptr.To(int32(m.opts.LeaseDuration.Seconds()))
+		lease.Spec.RenewTime = &now
+		if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update lease: %w", err)
+		}
+	}
+
+	list, err := leases.List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", shardLabel, m.opts.KubeName),
+	})
+	if err != nil {
+		return fmt.Errorf("list leases: %w", err)
+	}
+	members := []string{m.opts.Identity}
+	for _, l := range list.Items {
+		if l.Spec.HolderIdentity == nil || l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		leaseDuration := time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second
+		expiry := l.Spec.RenewTime.Add(leaseDuration)
+		if expiry.Add(leaseDuration).Before(now.Time) {
+			m.deleteLease(ctx, &l)
+			continue
+		}
+		if expiry.Before(now.Time) || slices.Contains(members, *l.Spec.HolderIdentity) {
+			continue
+		}
+		members = append(members, *l.Spec.HolderIdentity)
+	}
+	slices.Sort(members)
+
+	m.mtx.Lock()
+	if len(m.members) == 0 {
+		_ = level.Info(m.logger).Log("msg", "Participating in rule group sharding", "members", fmt.Sprint(members))
+	} else if !slices.Equal(m.members, members) {
+		_ = level.Info(m.logger).Log("msg", "Shard members changed", "members", fmt.Sprint(members))
+	}
+	m.members = members
+	m.mtx.Unlock()
+
+	m.metrics.members.Set(float64(len(members)))
+	return nil
+}
+
+// deleteLease deletes the expired membership lease of another replica unless it was renewed
+// in the meantime.
+func (m *shardMembers) deleteLease(ctx context.Context, l *coordinationv1.Lease) {
+	err := m.client.CoordinationV1().Leases(l.Namespace).Delete(ctx, l.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &l.ResourceVersion},
+	})
+	switch {
+	case err == nil:
+		_ = level.Info(m.logger).Log("msg", "Deleted expired shard membership lease", "lease", l.Name)
+	case !apierrors.IsNotFound(err) && !apierrors.IsConflict(err):
+		_ = level.Warn(m.logger).Log("msg", "Deleting expired shard membership lease failed", "lease", l.Name, "err", err)
+	}
+}
+
+// participating returns whether the replica synced the members, which include itself once
+// its lease exists.
+func (m *shardMembers) participating() bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return len(m.members) > 0
+}
+
+// owns returns whether the replica owns the rule group with the given key. Groups are
+// assigned through rendezvous hashing, so that only the groups of a joining or leaving
+// replica move.
+func (m *shardMembers) owns(key string) bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	// Until the members are known, the replica owns nothing, as the other replicas may
+	// own any group.
+	if len(m.members) == 0 {
+		return false
+	}
+	return rendezvousOwner(m.members, key) == m.opts.Identity
+}
+
+// rendezvousOwner returns the member with the highest hash for the key.
+func rendezvousOwner(members []string, key string) string {
+	var (
+		owner string
+		best  uint64
+	)
+	for _, member := range members {
+		if sum := xxhash.Sum64String(member + "\xff" + key); owner == "" || sum > best {
+			owner, best = member, sum
+		}
+	}
+	return owner
+}
+
+// haAppendable only appends samples while the replica is active.
+type haAppendable struct {
+	storage.Appendable
+	ha *haCoordinator
+}
+
+// Appender returns an appender discarding all samples if the replica is inactive.
+func (a haAppendable) Appender(ctx context.Context) storage.Appender {
+	if !a.ha.active() {
+		return discardAppender{}
+	}
+	return a.Appendable.Appender(ctx)
+}
+
+// discardAppender drops all samples appended by rules.
+type discardAppender struct{}
+
+func (discardAppender) Append(storage.SeriesRef, labels.Labels, int64, float64) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (discardAppender) AppendHistogram(storage.SeriesRef, labels.Labels, int64, *histogram.Histogram, *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (discardAppender) AppendExemplar(storage.SeriesRef, labels.Labels, exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (discardAppender) UpdateMetadata(storage.SeriesRef, labels.Labels, metadata.Metadata) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (discardAppender) AppendCTZeroSample(storage.SeriesRef, labels.Labels, int64, int64) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (discardAppender) Commit() error {
+	return nil
+}
+
+func (discardAppender) Rollback() error {
+	return nil
+}
@@ -0,0 +1,384 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func testHAOptions(identity string) haOptions {
+	return haOptions{
+		KubeNamespace: "gmp-system",
+		KubeName:      "rule-evaluator",
+		Identity:      identity,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+func TestRendezvousOwner(t *testing.T) {
+	members := []string{"a", "b", "c"}
+
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := range 300 {
+		key := fmt.Sprintf("/etc/rules/rules.yaml;group-%d", i)
+		owner := rendezvousOwner(members, key)
+		owners[key] = owner
+		counts[owner]++
+	}
+	for _, m := range members {
+		if counts[m] < 50 {
+			t.Errorf("expected groups to be distributed across members, got %v", counts)
+		}
+	}
+
+	// Only groups of the removed member must move.
+	for key, owner := range owners {
+		got := rendezvousOwner([]string{"a", "c"}, key)
+		if owner != "b" && got != owner {
+			t.Errorf("group %q moved from %q to %q", key, owner, got)
+		}
+	}
+}
+
+func TestShardMembersSync(t *testing.T) {
+	now := time.Now()
+	client := fake.NewClientset(
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "gmp-system",
+				Name:      "rule-evaluator-b",
+				Labels:    map[string]string{shardLabel: "rule-evaluator"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("b"),
+				LeaseDurationSeconds: ptr.To(int32(15)),
+				RenewTime:            &metav1.MicroTime{Time: now},
+			},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "gmp-system",
+				Name:      "rule-evaluator-c",
+				Labels:    map[string]string{shardLabel: "rule-evaluator"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("c"),
+				LeaseDurationSeconds: ptr.To(int32(15)),
+				RenewTime:            &metav1.MicroTime{Time: now.Add(-time.Minute)},
+			},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "gmp-system",
+				Name:      "other",
+				Labels:    map[string]string{shardLabel: "other"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("d"),
+				LeaseDurationSeconds: ptr.To(int32(15)),
+				RenewTime:            &metav1.MicroTime{Time: now},
+			},
+		},
+	)
+	m := newShardMembers(log.NewNopLogger(), client, testHAOptions("a"), newHAMetrics(nil))
+
+	for range 2 {
+		if err := m.sync(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"a", "b"}, m.members); diff != "" {
+			t.Errorf("unexpected members (-want, +got): %s", diff)
+		}
+	}
+	lease, err := client.CoordinationV1().Leases("gmp-system").Get(t.Context(), "rule-evaluator-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *lease.Spec.HolderIdentity; got != "a" {
+		t.Errorf("expected holder %q, got %q", "a", got)
+	}
+	if got := lease.Labels[shardLabel]; got != "rule-evaluator" {
+		t.Errorf("expected shard label %q, got %q", "rule-evaluator", got)
+	}
+	// The lease of c expired more than a lease duration ago.
+	if _, err := client.CoordinationV1().Leases("gmp-system").Get(t.Context(), "rule-evaluator-c", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected expired lease to be deleted, got %v", err)
+	}
+	if _, err := client.CoordinationV1().Leases("gmp-system").Get(t.Context(), "other", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected lease of other shard to be kept, got %v", err)
+	}
+
+	key := "/etc/rules/rules.yaml;group"
+	if got, want := m.owns(key), rendezvousOwner([]string{"a", "b"}, key) == "a"; got != want {
+		t.Errorf("expected ownership %v, got %v", want, got)
+	}
+
+	// The lease is deleted when the replica stops.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	m.run(ctx)
+	if _, err := client.CoordinationV1().Leases("gmp-system").Get(t.Context(), "rule-evaluator-a", metav1.GetOptions{}); err == nil {
+		t.Error("expected lease to be deleted")
+	}
+}
+
+func TestShardMembersOwnNothingUntilSynced(t *testing.T) {
+	var failList atomic.Bool
+	failList.Store(true)
+	client := fake.NewClientset()
+	client.PrependReactor("list", "leases", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failList.Load() {
+			return true, nil, apierrors.NewForbidden(coordinationv1.Resource("leases"), "", errors.New("missing RBAC"))
+		}
+		return false, nil, nil
+	})
+	m := newShardMembers(log.NewNopLogger(), client, testHAOptions("a"), newHAMetrics(nil))
+
+	if m.owns("group") || m.participating() {
+		t.Error("expected replica to own no groups before syncing")
+	}
+	// The own lease is created, but the other members are unknown.
+	if err := m.sync(t.Context()); err == nil {
+		t.Fatal("expected sync to fail")
+	}
+	if m.owns("group") || m.participating() {
+		t.Error("expected replica to own no groups while syncing fails")
+	}
+
+	failList.Store(false)
+	if err := m.sync(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if !m.owns("group") || !m.participating() {
+		t.Error("expected single replica to own all groups after syncing")
+	}
+}
+
+func TestHACoordinatorApplyConfig(t *testing.T) {
+	// A canceled context keeps the coordinator from acquiring the leader lease.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	opts := testHAOptions("a")
+	opts.Mode = haModeNone
+	c := newHACoordinator(ctx, log.NewNopLogger(), nil, opts)
+	c.newClient = func() (kubernetes.Interface, error) {
+		return fake.NewClientset(), nil
+	}
+
+	if err := c.ApplyConfig(haConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.active() || !c.ownsGroup("group") {
+		t.Error("expected replica to be active and own all groups without HA")
+	}
+
+	if err := c.ApplyConfig(haConfig{Mode: haModeLeader}); err != nil {
+		t.Fatal(err)
+	}
+	if c.active() {
+		t.Error("expected replica without leader lease to be inactive")
+	}
+	if !c.ownsGroup("group") {
+		t.Error("expected replica to own all groups in leader mode")
+	}
+
+	if err := c.ApplyConfig(haConfig{Mode: haModeShard}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.active() {
+		t.Error("expected replica to be active in shard mode")
+	}
+
+	if err := c.ApplyConfig(haConfig{Mode: "foo"}); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	c.Stop()
+}
+
+func TestHACoordinatorApplyConfigDoesNotBlockEvaluations(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var (
+		synced  = make(chan struct{})
+		release = make(chan struct{})
+		once    sync.Once
+	)
+	client := fake.NewClientset()
+	client.PrependReactor("get", "leases", func(k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(synced) })
+		<-release
+		return false, nil, nil
+	})
+	c := newHACoordinator(ctx, log.NewNopLogger(), nil, testHAOptions("a"))
+	c.newClient = func() (kubernetes.Interface, error) {
+		return client, nil
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.ApplyConfig(haConfig{Mode: haModeShard})
+	}()
+	<-synced
+
+	owns := make(chan bool, 1)
+	go func() {
+		owns <- c.ownsGroup("group") && c.active()
+	}()
+	select {
+	case ok := <-owns:
+		if !ok {
+			t.Error("expected replica to keep evaluating all groups during the mode switch")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("evaluations blocked by the initial sync of shard members")
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	c.Stop()
+}
+
+func TestHACoordinatorLeaderTransitions(t *testing.T) {
+	c := newHACoordinator(t.Context(), log.NewNopLogger(), nil, testHAOptions("a"))
+	elector, err := c.newLeaderElector(fake.NewClientset())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Canceled runs never acquire the lease.
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+	for range 3 {
+		elector.Run(canceled)
+	}
+	if got := testutil.ToFloat64(c.metrics.leaderTransitions); got != 0 {
+		t.Errorf("expected no leader transitions, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+	for testutil.ToFloat64(c.metrics.leader) != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := testutil.ToFloat64(c.metrics.leaderTransitions); got != 2 {
+		t.Errorf("expected 2 leader transitions, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.metrics.leader); got != 0 {
+		t.Errorf("expected leader gauge to be reset, got %v", got)
+	}
+}
+
+func TestHAAppendable(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	c := newHACoordinator(ctx, log.NewNopLogger(), nil, testHAOptions("a"))
+	c.newClient = func() (kubernetes.Interface, error) {
+		return fake.NewClientset(), nil
+	}
+	app := &testAppender{}
+	appendable := haAppendable{Appendable: testAppendable{app: app}, ha: c}
+
+	appendSample := func() {
+		a := appendable.Appender(t.Context())
+		if _, err := a.Append(0, labels.FromStrings("__name__", "foo"), 1000, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendSample()
+	if len(app.samples) != 1 {
+		t.Fatalf("expected sample to be appended, got %d samples", len(app.samples))
+	}
+
+	if err := c.ApplyConfig(haConfig{Mode: haModeLeader}); err != nil {
+		t.Fatal(err)
+	}
+	appendSample()
+	if len(app.samples) != 1 {
+		t.Fatalf("expected sample to be discarded, got %d samples", len(app.samples))
+	}
+	a, ok := appendable.Appender(t.Context()).(discardAppender)
+	if !ok {
+		t.Fatal("expected discarding appender for inactive replica")
+	}
+	lset := labels.FromStrings("__name__", "foo")
+	if _, err := a.AppendExemplar(0, lset, exemplar.Exemplar{Value: 1, Ts: 1000}); err != nil {
+		t.Error(err)
+	}
+	if _, err := a.UpdateMetadata(0, lset, metadata.Metadata{}); err != nil {
+		t.Error(err)
+	}
+	if _, err := a.AppendCTZeroSample(0, lset, 1000, 500); err != nil {
+		t.Error(err)
+	}
+	var _ storage.Appendable = appendable
+}
+
+func TestLoadConfigHighAvailability(t *testing.T) {
+	cfg, err := loadConfig([]byte(`
+rule_files:
+- /etc/rules/*.yaml
+rule_evaluator:
+  high_availability:
+    mode: shard
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/etc/rules/*.yaml"}, cfg.RuleFiles); diff != "" {
+		t.Errorf("unexpected rule files (-want, +got): %s", diff)
+	}
+	if got := cfg.RuleEvaluator.HighAvailability.Mode; got != haModeShard {
+		t.Errorf("expected mode %q, got %q", haModeShard, got)
+	}
+	if got := cfg.GlobalConfig.EvaluationInterval; got == 0 {
+		t.Error("expected Prometheus defaults to be applied")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// Bounds of the exponential schemas of native histograms.
+const (
+	minHistogramSchema = -4
+	maxHistogramSchema = 8
+)
+
+// convertSampleHistogram converts a native histogram as returned by the Prometheus query API
+// to a histogram.FloatHistogram.
+//
+// The API only returns the boundaries and counts of populated buckets. The schema is inferred
+// from the bucket widths, the bucket indexes from the boundaries furthest away from zero.
+func convertSampleHistogram(h *model.SampleHistogram) *histogram.FloatHistogram {
+	fh := &histogram.FloatHistogram{
+		Count: float64(h.Count),
+		Sum:   float64(h.Sum),
+	}
+	var positive, negative []*model.HistogramBucket
+	for _, b := range h.Buckets {
+		switch {
+		case b.Lower > 0:
+			positive = append(positive, b)
+		case b.Upper < 0:
+			negative = append(negative, b)
+		default:
+			// The zero bucket is the only one spanning zero, i.e. [-threshold, threshold].
+			fh.ZeroThreshold = float64(b.Upper)
+			fh.ZeroCount += float64(b.Count)
+		}
+	}
+	fh.Schema = inferHistogramSchema(positive, negative)
+	fh.PositiveSpans, fh.PositiveBuckets = histogramBucketsToSpans(positive, fh.Schema, func(b *model.HistogramBucket) float64 {
+		return float64(b.Upper)
+	})
+	fh.NegativeSpans, fh.NegativeBuckets = histogramBucketsToSpans(negative, fh.Schema, func(b *model.HistogramBucket) float64 {
+		return -float64(b.Lower)
+	})
+	return fh
+}
+
+// inferHistogramSchema returns the schema matching the ratio between bucket boundaries, which
+// is 2^(2^-schema). Buckets adjacent to the zero bucket may have their boundary clamped to the
+// zero threshold, which only ever decreases the ratio, so the largest ratio is used.
+func inferHistogramSchema(positive, negative []*model.HistogramBucket) int32 {
+	var ratio float64
+	for _, b := range positive {
+		ratio = max(ratio, float64(b.Upper/b.Lower))
+	}
+	for _, b := range negative {
+		ratio = max(ratio, float64(b.Lower/b.Upper))
+	}
+	if ratio <= 1 || math.IsInf(ratio, 0) {
+		return 0
+	}
+	schema := math.Round(-math.Log2(math.Log2(ratio)))
+	return int32(min(max(schema, minHistogramSchema), maxHistogramSchema))
+}
+
+// histogramBucketsToSpans converts buckets of the same sign to spans and absolute bucket counts.
+// The bound function returns the absolute value of the bucket boundary furthest away from zero.
+func histogramBucketsToSpans(buckets []*model.HistogramBucket, schema int32, bound func(*model.HistogramBucket) float64) ([]histogram.Span, []float64) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	type indexedBucket struct {
+		index int32
+		count float64
+	}
+	indexed := make([]indexedBucket, 0, len(buckets))
+	for _, b := range buckets {
+		// The upper bound of bucket i is 2^(i*2^-schema).
+		indexed = append(indexed, indexedBucket{
+			index: int32(math.Round(math.Ldexp(math.Log2(bound(b)), int(schema)))),
+			count: float64(b.Count),
+		})
+	}
+	slices.SortFunc(indexed, func(a, b indexedBucket) int {
+		return int(a.index - b.index)
+	})
+
+	var (
+		spans  []histogram.Span
+		counts = make([]float64, 0, len(indexed))
+		prev   int32
+	)
+	for i, b := range indexed {
+		switch {
+		case i == 0:
+			spans = append(spans, histogram.Span{Offset: b.index, Length: 1})
+		case b.index == prev+1:
+			spans[len(spans)-1].Length++
+		default:
+			spans = append(spans, histogram.Span{Offset: b.index - prev - 1, Length: 1})
+		}
+		counts = append(counts, b.count)
+		prev = b.index
+	}
+	return spans, counts
+}
+
+// histogramAppendable wraps a storage.Appendable that only supports float samples, such as the
+// GCM export storage. Native histogram samples, e.g. produced by recording rules, are appended
+// as classic histogram series with the _bucket, _count and _sum suffixes.
+type histogramAppendable struct {
+	storage.Appendable
+}
+
+// Appender returns a new appender for the wrapped storage.
+func (a histogramAppendable) Appender(ctx context.Context) storage.Appender {
+	return &histogramAppender{Appender: a.Appendable.Appender(ctx)}
+}
+
+type histogramAppender struct {
+	storage.Appender
+}
+
+// AppendHistogram appends the native histogram as cumulative classic histogram series.
+func (a *histogramAppender) AppendHistogram(_ storage.SeriesRef, lset labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	if fh == nil {
+		if h == nil {
+			return 0, errors.New("histogram sample is nil")
+		}
+		fh = h.ToFloat(nil)
+	}
+	name := lset.Get(labels.MetricName)
+	if name == "" {
+		return 0, errors.New("histogram sample has no metric name")
+	}
+	b := labels.NewBuilder(lset)
+
+	appendSeries := func(suffix string, v float64) error {
+		b.Set(labels.MetricName, name+suffix)
+		_, err := a.Appender.Append(0, b.Labels(), t, v)
+		return err
+	}
+
+	var cumulative float64
+	it := fh.AllBucketIterator()
+	for it.Next() {
+		bucket := it.At()
+		cumulative += bucket.Count
+		// The +Inf bucket is always appended below with the total count.
+		if math.IsInf(bucket.Upper, 1) {
+			continue
+		}
+		b.Set(labels.BucketLabel, strconv.FormatFloat(bucket.Upper, 'g', -1, 64))
+		if err := appendSeries("_bucket", cumulative); err != nil {
+			return 0, err
+		}
+	}
+	b.Set(labels.BucketLabel, "+Inf")
+	if err := appendSeries("_bucket", fh.Count); err != nil {
+		return 0, err
+	}
+	b.Del(labels.BucketLabel)
+
+	if err := appendSeries("_count", fh.Count); err != nil {
+		return 0, err
+	}
+	if err := appendSeries("_sum", fh.Sum); err != nil {
+		return 0, err
+	}
+	// Return 0 ID to indicate that we don't support fast path appending.
+	return 0, nil
+}
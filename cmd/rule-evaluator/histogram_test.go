@@ -0,0 +1,270 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// toSampleHistogram renders a histogram the same way the Prometheus query API does.
+func toSampleHistogram(h *histogram.FloatHistogram) *model.SampleHistogram {
+	sh := &model.SampleHistogram{
+		Count: model.FloatString(h.Count),
+		Sum:   model.FloatString(h.Sum),
+	}
+	it := h.AllBucketIterator()
+	for it.Next() {
+		b := it.At()
+		if b.Count == 0 {
+			continue
+		}
+		sh.Buckets = append(sh.Buckets, &model.HistogramBucket{
+			Lower: model.FloatString(b.Lower),
+			Upper: model.FloatString(b.Upper),
+			Count: model.FloatString(b.Count),
+		})
+	}
+	return sh
+}
+
+func TestConvertSampleHistogram(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		h    *histogram.FloatHistogram
+	}{
+		{
+			name: "empty",
+			h:    &histogram.FloatHistogram{},
+		},
+		{
+			name: "positive buckets with gaps",
+			h: &histogram.FloatHistogram{
+				Schema:          3,
+				Count:           10,
+				Sum:             42.5,
+				PositiveSpans:   []histogram.Span{{Offset: -2, Length: 2}, {Offset: 3, Length: 1}},
+				PositiveBuckets: []float64{2, 3, 5},
+			},
+		},
+		{
+			name: "negative, zero and positive buckets",
+			h: &histogram.FloatHistogram{
+				Schema:          0,
+				Count:           12,
+				Sum:             -3,
+				ZeroThreshold:   0.001,
+				ZeroCount:       4,
+				PositiveSpans:   []histogram.Span{{Offset: 0, Length: 3}},
+				PositiveBuckets: []float64{1, 2, 1},
+				NegativeSpans:   []histogram.Span{{Offset: 1, Length: 2}},
+				NegativeBuckets: []float64{3, 1},
+			},
+		},
+		{
+			name: "negative schema",
+			h: &histogram.FloatHistogram{
+				Schema:          -2,
+				Count:           3,
+				Sum:             1000,
+				PositiveSpans:   []histogram.Span{{Offset: 1, Length: 2}},
+				PositiveBuckets: []float64{1, 2},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := convertSampleHistogram(toSampleHistogram(tc.h))
+			if !got.Equals(tc.h) {
+				t.Errorf("expected histogram %s, got %s", tc.h, got)
+			}
+		})
+	}
+}
+
+func TestConvertModelToPromQLValue(t *testing.T) {
+	h := &histogram.FloatHistogram{
+		Schema:          1,
+		Count:           3,
+		Sum:             4,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}},
+		PositiveBuckets: []float64{1, 2},
+	}
+	for _, tc := range []struct {
+		name string
+		val  model.Value
+		want parser.Value
+	}{
+		{
+			name: "vector with histogram",
+			val: model.Vector{
+				{Metric: model.Metric{"__name__": "foo"}, Timestamp: 1000, Value: 1},
+				{Metric: model.Metric{"__name__": "bar"}, Timestamp: 1000, Histogram: toSampleHistogram(h)},
+			},
+			want: promql.Vector{
+				{Metric: labels.FromStrings("__name__", "foo"), T: 1000, F: 1},
+				{Metric: labels.FromStrings("__name__", "bar"), T: 1000, H: h},
+			},
+		},
+		{
+			name: "matrix with histograms",
+			val: model.Matrix{
+				{
+					Metric:     model.Metric{"__name__": "bar"},
+					Histograms: []model.SampleHistogramPair{{Timestamp: 1000, Histogram: toSampleHistogram(h)}},
+				},
+			},
+			want: promql.Matrix{
+				{
+					Metric:     labels.FromStrings("__name__", "bar"),
+					Floats:     []promql.FPoint{},
+					Histograms: []promql.HPoint{{T: 1000, H: h}},
+				},
+			},
+		},
+		{
+			name: "scalar",
+			val:  &model.Scalar{Timestamp: 1000, Value: 2},
+			want: promql.Scalar{T: 1000, V: 2},
+		},
+		{
+			name: "string",
+			val:  &model.String{Timestamp: 1000, Value: "foo"},
+			want: promql.String{T: 1000, V: "foo"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := convertModelToPromQLValue(tc.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.Comparer(func(a, b *histogram.FloatHistogram) bool {
+				if a == nil || b == nil {
+					return a == b
+				}
+				return a.Equals(b)
+			}), cmp.Comparer(labels.Equal)); diff != "" {
+				t.Errorf("unexpected result (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+type testSample struct {
+	lset labels.Labels
+	t    int64
+	v    float64
+}
+
+// testAppender records appended samples. It is shared by concurrent rule evaluations.
+type testAppender struct {
+	storage.Appender
+
+	mtx     sync.Mutex
+	samples []testSample
+}
+
+func (a *testAppender) Append(_ storage.SeriesRef, lset labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.samples = append(a.samples, testSample{lset: lset, t: t, v: v})
+	return 0, nil
+}
+
+func (a *testAppender) Commit() error {
+	return nil
+}
+
+func (a *testAppender) Rollback() error {
+	return nil
+}
+
+type testAppendable struct {
+	app *testAppender
+}
+
+func (a testAppendable) Appender(context.Context) storage.Appender {
+	return a.app
+}
+
+func TestHistogramAppender(t *testing.T) {
+	app := &testAppender{}
+	appendable := histogramAppendable{testAppendable{app: app}}
+
+	h := &histogram.FloatHistogram{
+		Schema:          0,
+		Count:           7,
+		Sum:             12,
+		ZeroThreshold:   0.5,
+		ZeroCount:       1,
+		PositiveSpans:   []histogram.Span{{Offset: 1, Length: 2}},
+		PositiveBuckets: []float64{2, 3},
+		NegativeSpans:   []histogram.Span{{Offset: 1, Length: 1}},
+		NegativeBuckets: []float64{1},
+	}
+	lset := labels.FromStrings("__name__", "job:latency:rate5m", "job", "foo")
+	if _, err := appendable.Appender(t.Context()).AppendHistogram(0, lset, 1000, nil, h); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []testSample{
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "-1"), t: 1000, v: 1},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "0.5"), t: 1000, v: 2},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "2"), t: 1000, v: 4},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "4"), t: 1000, v: 7},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "+Inf"), t: 1000, v: 7},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_count", "job", "foo"), t: 1000, v: 7},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_sum", "job", "foo"), t: 1000, v: 12},
+	}
+	if diff := cmp.Diff(want, app.samples, cmp.AllowUnexported(testSample{}), cmp.Comparer(labels.Equal)); diff != "" {
+		t.Errorf("unexpected samples (-want, +got): %s", diff)
+	}
+}
+
+func TestHistogramAppenderInfBucket(t *testing.T) {
+	app := &testAppender{}
+	appendable := histogramAppendable{testAppendable{app: app}}
+
+	// The top bucket counts observations of +Inf and has an upper bound of +Inf.
+	h := &histogram.FloatHistogram{
+		Schema:          0,
+		Count:           3,
+		Sum:             10,
+		PositiveSpans:   []histogram.Span{{Offset: 1024, Length: 2}},
+		PositiveBuckets: []float64{1, 2},
+	}
+	lset := labels.FromStrings("__name__", "job:latency:rate5m", "job", "foo")
+	if _, err := appendable.Appender(t.Context()).AppendHistogram(0, lset, 1000, nil, h); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []testSample{
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "1.7976931348623157e+308"), t: 1000, v: 1},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_bucket", "job", "foo", "le", "+Inf"), t: 1000, v: 3},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_count", "job", "foo"), t: 1000, v: 3},
+		{lset: labels.FromStrings("__name__", "job:latency:rate5m_sum", "job", "foo"), t: 1000, v: 10},
+	}
+	if diff := cmp.Diff(want, app.samples, cmp.AllowUnexported(testSample{}), cmp.Comparer(labels.Equal)); diff != "" {
+		t.Errorf("unexpected samples (-want, +got): %s", diff)
+	}
+}
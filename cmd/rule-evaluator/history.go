@@ -0,0 +1,211 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/cmd/rule-evaluator/internal"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// ruleKey identifies a rule within its group. Like the rules manager when it carries over
+// rule state on reload, rules are identified by their name, query and labels.
+func ruleKey(name, query string, lset labels.Labels) string {
+	return name + "\xff" + query + "\xff" + lset.String()
+}
+
+type queryRecordKey struct{}
+
+// queryRecord holds the results of the rule queries of one group evaluation.
+type queryRecord struct {
+	mtx     sync.Mutex
+	results map[string]queryResult
+}
+
+type queryResult struct {
+	samples  int
+	warnings []string
+}
+
+// withQueryRecord returns a context in which the query function records the results of
+// rule queries.
+func withQueryRecord(ctx context.Context) (context.Context, *queryRecord) {
+	rec := &queryRecord{results: map[string]queryResult{}}
+	return context.WithValue(ctx, queryRecordKey{}, rec), rec
+}
+
+// recordQuery records the result of a rule query if the context has a query record.
+// The rule is taken from the origin context set by the rule evaluation.
+func recordQuery(ctx context.Context, samples int, warnings []string) {
+	rec, ok := ctx.Value(queryRecordKey{}).(*queryRecord)
+	if !ok {
+		return
+	}
+	rule := rules.FromOriginContext(ctx)
+	if rule.Name == "" {
+		return
+	}
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+	rec.results[ruleKey(rule.Name, rule.Query, rule.Labels)] = queryResult{samples: samples, warnings: warnings}
+}
+
+func (rec *queryRecord) get(key string) queryResult {
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+	return rec.results[key]
+}
+
+// ruleHistory keeps the last evaluations of every rule in a ring buffer.
+type ruleHistory struct {
+	size int
+
+	mtx   sync.Mutex
+	rules map[string]*ruleHistoryEntry
+}
+
+type ruleHistoryEntry struct {
+	evaluations []internal.RuleEvaluation
+	next        int
+	// alerts holds the state of the active alerts after the last evaluation by label hash.
+	alerts map[uint64]alertState
+}
+
+type alertState struct {
+	labels labels.Labels
+	state  rules.AlertState
+}
+
+// newRuleHistory returns a history keeping the last size evaluations of every rule.
+// No history is kept if size is zero.
+func newRuleHistory(size int) *ruleHistory {
+	return &ruleHistory{
+		size:  size,
+		rules: map[string]*ruleHistoryEntry{},
+	}
+}
+
+func ruleHistoryKey(g *rules.Group, r rules.Rule) string {
+	return rules.GroupKey(g.File(), g.Name()) + "\xff" + ruleKey(r.Name(), r.Query().String(), r.Labels())
+}
+
+// record adds the evaluations of the group's rules that happened since start. Rules that
+// weren't evaluated, e.g. as the group is evaluated by another replica, are skipped.
+func (h *ruleHistory) record(g *rules.Group, start time.Time, rec *queryRecord) {
+	if h.size <= 0 {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, r := range g.Rules() {
+		ts := r.GetEvaluationTimestamp()
+		if ts.Before(start) {
+			continue
+		}
+		key := ruleHistoryKey(g, r)
+		entry, ok := h.rules[key]
+		if !ok {
+			entry = &ruleHistoryEntry{evaluations: make([]internal.RuleEvaluation, 0, h.size)}
+			h.rules[key] = entry
+		}
+		result := rec.get(ruleKey(r.Name(), r.Query().String(), r.Labels()))
+		evaluation := internal.RuleEvaluation{
+			Timestamp: ts,
+			Duration:  r.GetEvaluationDuration().Seconds(),
+			Samples:   result.samples,
+			Warnings:  result.warnings,
+		}
+		if err := r.LastError(); err != nil {
+			evaluation.Error = err.Error()
+		}
+		if ar, ok := r.(*rules.AlertingRule); ok {
+			evaluation.AlertTransitions = entry.updateAlerts(ar.ActiveAlerts())
+		}
+		entry.add(evaluation)
+	}
+}
+
+func (e *ruleHistoryEntry) add(evaluation internal.RuleEvaluation) {
+	if len(e.evaluations) < cap(e.evaluations) {
+		e.evaluations = append(e.evaluations, evaluation)
+		return
+	}
+	e.evaluations[e.next] = evaluation
+	e.next = (e.next + 1) % len(e.evaluations)
+}
+
+// updateAlerts sets the active alerts after an evaluation and returns the state changes
+// since the previous evaluation. Alerts that are no longer active became inactive.
+func (e *ruleHistoryEntry) updateAlerts(active []*rules.Alert) []internal.AlertTransition {
+	var transitions []internal.AlertTransition
+	alerts := make(map[uint64]alertState, len(active))
+	for _, a := range active {
+		h := a.Labels.Hash()
+		alerts[h] = alertState{labels: a.Labels, state: a.State}
+		from := rules.StateInactive
+		if prev, ok := e.alerts[h]; ok {
+			from = prev.state
+		}
+		if from != a.State {
+			transitions = append(transitions, internal.AlertTransition{Labels: a.Labels, From: from.String(), To: a.State.String()})
+		}
+	}
+	for h, prev := range e.alerts {
+		if _, ok := alerts[h]; !ok {
+			transitions = append(transitions, internal.AlertTransition{Labels: prev.labels, From: prev.state.String(), To: rules.StateInactive.String()})
+		}
+	}
+	e.alerts = alerts
+	return transitions
+}
+
+// RuleHistory implements internal.RuleHistoryRetriever.
+func (h *ruleHistory) RuleHistory(g *rules.Group, r rules.Rule) []internal.RuleEvaluation {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	entry, ok := h.rules[ruleHistoryKey(g, r)]
+	if !ok {
+		return nil
+	}
+	n := len(entry.evaluations)
+	evaluations := make([]internal.RuleEvaluation, 0, n)
+	for i := range n {
+		evaluations = append(evaluations, entry.evaluations[(entry.next+n-1-i)%n])
+	}
+	return evaluations
+}
+
+// sync drops the history of rules that are no longer loaded.
+func (h *ruleHistory) sync(groups []*rules.Group) {
+	keys := map[string]struct{}{}
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			keys[ruleHistoryKey(g, r)] = struct{}{}
+		}
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for key := range h.rules {
+		if _, ok := keys[key]; !ok {
+			delete(h.rules, key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/cmd/rule-evaluator/internal"
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+)
+
+func TestRuleHistory(t *testing.T) {
+	// The query function returns the alerting series of each evaluation and records the
+	// query result like the rule-evaluator's query function.
+	var (
+		series   []string
+		queryErr error
+	)
+	queryFunc := func(ctx context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		if queryErr != nil {
+			return nil, queryErr
+		}
+		var vec promql.Vector
+		for _, s := range series {
+			vec = append(vec, promql.Sample{T: timestamp.FromTime(ts), F: 1, Metric: labels.FromStrings("instance", s)})
+		}
+		recordQuery(ctx, len(vec), []string{"partial data"})
+		return vec, nil
+	}
+	rule := rules.NewAlertingRule("test", &parser.NumberLiteral{Val: 1}, 0, 0, labels.EmptyLabels(), labels.EmptyLabels(), nil, "", true, log.NewNopLogger())
+	group := rules.NewGroup(rules.GroupOptions{
+		Name:     "group",
+		File:     "rules.yaml",
+		Interval: time.Minute,
+		Rules:    []rules.Rule{rule},
+		Opts: &rules.ManagerOptions{
+			QueryFunc:  queryFunc,
+			Appendable: testAppendable{app: &testAppender{}},
+			Logger:     log.NewNopLogger(),
+			NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+			Metrics:    rules.NewGroupMetrics(nil),
+		},
+	})
+
+	h := newRuleHistory(2)
+	eval := func() {
+		ctx, rec := withQueryRecord(t.Context())
+		start := time.Now()
+		group.Eval(ctx, start)
+		h.record(group, start, rec)
+	}
+
+	series = []string{"a", "b"}
+	eval()
+	series = []string{"b"}
+	eval()
+	queryErr = errors.New("query failed")
+	eval()
+
+	got := h.RuleHistory(group, rule)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 evaluations, got %d", len(got))
+	}
+	if !got[0].Timestamp.After(got[1].Timestamp) {
+		t.Errorf("expected newest evaluation first, got %v before %v", got[0].Timestamp, got[1].Timestamp)
+	}
+	// Clear timestamps and durations for comparison.
+	for i := range got {
+		got[i].Timestamp, got[i].Duration = time.Time{}, 0
+	}
+	want := []internal.RuleEvaluation{
+		// Alerts keep their state if the evaluation fails.
+		{Error: "query failed"},
+		{
+			Samples:  1,
+			Warnings: []string{"partial data"},
+			AlertTransitions: []internal.AlertTransition{
+				{Labels: labels.FromStrings("alertname", "test", "instance", "a"), From: "firing", To: "inactive"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(labels.Equal)); diff != "" {
+		t.Errorf("unexpected history (-want, +got): %s", diff)
+	}
+
+	h.sync(nil)
+	if got := h.RuleHistory(group, rule); got != nil {
+		t.Errorf("expected history of removed rule to be dropped, got %v", got)
+	}
+}
+
+func TestRuleHistoryDisabled(t *testing.T) {
+	rule := rules.NewRecordingRule("test", &parser.NumberLiteral{Val: 1}, labels.EmptyLabels())
+	group := rules.NewGroup(rules.GroupOptions{Name: "group", File: "rules.yaml", Rules: []rules.Rule{rule}, Opts: &rules.ManagerOptions{}})
+	rule.SetEvaluationTimestamp(time.Now())
+
+	h := newRuleHistory(0)
+	_, rec := withQueryRecord(t.Context())
+	h.record(group, time.Time{}, rec)
+	if got := h.RuleHistory(group, rule); got != nil {
+		t.Errorf("expected no history, got %v", got)
+	}
+}
@@ -15,24 +15,138 @@
 package internal
 
 import (
+	"fmt"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/prometheus/prometheus/rules"
 	apiv1 "github.com/prometheus/prometheus/web/api/v1"
 )
 
+const (
+	stateFilterQueryParamName = "state"
+	includeSourceQueryParam   = "include_source"
+
+	alertsEndpoint = "/api/v1/alerts"
+)
+
 type alertsEndpointResponse struct {
-	Alerts []*apiv1.Alert `json:"alerts"`
+	Alerts []*alert `json:"alerts"`
 }
 
-func (api *API) HandleAlertsEndpoint(w http.ResponseWriter, _ *http.Request) {
-	activeAlerts := []*rules.Alert{}
-	for _, rule := range api.rulesManager.AlertingRules() {
-		activeAlerts = append(activeAlerts, rule.ActiveAlerts()...)
+// alert is an active alert, optionally annotated with the rule it originates from.
+type alert struct {
+	*apiv1.Alert
+	Source *alertSource `json:"source,omitempty"`
+}
+
+// alertSource identifies the rule an alert originates from.
+type alertSource struct {
+	Rule  string `json:"rule"`
+	Group string `json:"group"`
+	File  string `json:"file"`
+	// Kind, Namespace and Name identify the rules resource the file was generated
+	// from by the operator, if any.
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// HandleAlertsEndpoint returns the active alerts. The alerts can be filtered by their state,
+// label selectors and the rule, group and file they originate from.
+func (api *API) HandleAlertsEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		api.writeError(w, errorBadData, "failed to parse request parameters", http.StatusBadRequest, alertsEndpoint)
+		return
+	}
+
+	ruleFilters := sanitizeFilterList(r.Form[ruleFilterQueryParamName])
+	fileFilters := sanitizeFilterList(r.Form[fileFilterQueryParamName])
+	groupFilters := sanitizeFilterList(r.Form[groupFilterQueryParamName])
+
+	matcherSets, err := parseMatchersParam(sanitizeFilterList(r.Form[matchFilterQueryParamName]))
+	if err != nil {
+		api.writeError(w, errorBadData, fmt.Sprintf("invalid match[] parameter: %s", err), http.StatusBadRequest, alertsEndpoint)
+		return
+	}
+
+	stateFilter := strings.Trim(strings.ToLower(r.Form.Get(stateFilterQueryParamName)), " ")
+	if !slices.Contains([]string{"", rules.StatePending.String(), rules.StateFiring.String()}, stateFilter) {
+		api.writeError(w, errorBadData, "invalid state parameter", http.StatusBadRequest, alertsEndpoint)
+		return
+	}
+
+	includeSourceParam := strings.Trim(strings.ToLower(r.Form.Get(includeSourceQueryParam)), " ")
+	if !slices.Contains([]string{"", "true", "false"}, includeSourceParam) {
+		api.writeError(w, errorBadData, "invalid include_source parameter", http.StatusBadRequest, alertsEndpoint)
+		return
 	}
+	shouldIncludeSource := includeSourceParam == "true"
 
-	alertsResponse := alertsEndpointResponse{
-		Alerts: alertsToAPIAlerts(activeAlerts),
+	alerts := []*alert{}
+	for _, group := range api.rulesManager.RuleGroups() {
+		if len(groupFilters) > 0 && !slices.Contains(groupFilters, group.Name()) {
+			continue
+		}
+		if len(fileFilters) > 0 && !slices.Contains(fileFilters, group.File()) {
+			continue
+		}
+		for _, groupRule := range group.Rules() {
+			rule, ok := groupRule.(*rules.AlertingRule)
+			if !ok {
+				continue
+			}
+			if len(ruleFilters) > 0 && !slices.Contains(ruleFilters, rule.Name()) {
+				continue
+			}
+
+			var activeAlerts []*rules.Alert
+			for _, a := range rule.ActiveAlerts() {
+				if stateFilter != "" && a.State.String() != stateFilter {
+					continue
+				}
+				if !matchesMatcherSets(matcherSets, a.Labels) {
+					continue
+				}
+				activeAlerts = append(activeAlerts, a)
+			}
+
+			var source *alertSource
+			if shouldIncludeSource {
+				source = newAlertSource(rule.Name(), group.Name(), group.File())
+			}
+			for _, a := range alertsToAPIAlerts(activeAlerts) {
+				alerts = append(alerts, &alert{Alert: a, Source: source})
+			}
+		}
+	}
+	// Sort across rules for testability.
+	slices.SortStableFunc(alerts, func(a, b *alert) int {
+		return compareAPIAlerts(a.Alert, b.Alert)
+	})
+
+	api.writeSuccessResponse(w, http.StatusOK, alertsEndpoint, alertsEndpointResponse{Alerts: alerts})
+}
+
+// newAlertSource returns the source of an alert of the given rule. The rules resource is derived
+// from the file naming of the rule files generated by the operator.
+func newAlertSource(rule, group, file string) *alertSource {
+	source := &alertSource{Rule: rule, Group: group, File: file}
+
+	name, ok := strings.CutSuffix(filepath.Base(file), ".yaml")
+	if !ok {
+		return source
+	}
+	parts := strings.Split(name, "__")
+	switch {
+	case len(parts) == 3 && parts[0] == "rules":
+		source.Kind, source.Namespace, source.Name = "Rules", parts[1], parts[2]
+	case len(parts) == 2 && parts[0] == "clusterrules":
+		source.Kind, source.Name = "ClusterRules", parts[1]
+	case len(parts) == 2 && parts[0] == "globalrules":
+		source.Kind, source.Name = "GlobalRules", parts[1]
 	}
-	api.writeSuccessResponse(w, http.StatusOK, "/api/v1/alerts", alertsResponse)
+	return source
 }
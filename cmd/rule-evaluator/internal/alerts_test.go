@@ -16,9 +16,11 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
@@ -96,9 +98,15 @@ func TestAPI_HandleAlertsEndpoint(t *testing.T) {
 		t.Run(tcase.name, func(t *testing.T) {
 			t.Parallel()
 
+			groupRules := make([]rules.Rule, 0, len(tcase.alertingRules))
+			for _, r := range tcase.alertingRules {
+				groupRules = append(groupRules, r)
+			}
 			api := &API{
 				rulesManager: RuleGroupsRetrieverMock{
-					AlertingRulesFunc: func() []*rules.AlertingRule { return tcase.alertingRules },
+					RuleGroupsFunc: func() []*rules.Group {
+						return []*rules.Group{rules.NewGroup(rules.GroupOptions{Name: "test-group", Opts: &rules.ManagerOptions{}, Rules: groupRules})}
+					},
 				},
 				logger: logger,
 			}
@@ -116,3 +124,137 @@ func TestAPI_HandleAlertsEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestAPI_HandleAlertsEndpointFilters(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Unix(1700000000, 0).UTC()
+	// newActiveAlertRule returns an alerting rule with one active alert per given instance. The alerts
+	// are firing if the rule has no hold duration and pending otherwise.
+	newActiveAlertRule := func(name string, hold time.Duration, instances ...string) *rules.AlertingRule {
+		r := rules.NewAlertingRule(name, &parser.NumberLiteral{Val: 1}, hold, 0, labels.FromStrings("severity", "critical"), labels.EmptyLabels(), nil, "", true, log.NewNopLogger())
+		_, err := r.Eval(t.Context(), 0, ts, func(context.Context, string, time.Time) (promql.Vector, error) {
+			var vec promql.Vector
+			for _, instance := range instances {
+				vec = append(vec, promql.Sample{T: timestamp.FromTime(ts), F: 1, Metric: labels.FromStrings("instance", instance)})
+			}
+			return vec, nil
+		}, nil, 0)
+		require.NoError(t, err)
+		return r
+	}
+	groups := []*rules.Group{
+		rules.NewGroup(rules.GroupOptions{
+			Name: "group-1",
+			File: "/etc/rules/rules__ns-1__example.yaml",
+			Opts: &rules.ManagerOptions{},
+			Rules: []rules.Rule{
+				newActiveAlertRule("firing", 0, "a"),
+				newActiveAlertRule("pending", time.Hour, "b"),
+				rules.NewRecordingRule("recording", &parser.NumberLiteral{Val: 1}, labels.EmptyLabels()),
+			},
+		}),
+		rules.NewGroup(rules.GroupOptions{
+			Name:  "group-2",
+			File:  "/etc/rules/clusterrules__example.yaml",
+			Opts:  &rules.ManagerOptions{},
+			Rules: []rules.Rule{newActiveAlertRule("firing", 0, "c")},
+		}),
+	}
+	api := &API{
+		rulesManager: RuleGroupsRetrieverMock{RuleGroupsFunc: func() []*rules.Group { return groups }},
+		logger:       log.NewNopLogger(),
+	}
+
+	type testAlertsResponse struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Alerts []struct {
+				Labels map[string]string `json:"labels"`
+				State  string            `json:"state"`
+				Source *alertSource      `json:"source"`
+			} `json:"alerts"`
+		} `json:"data"`
+	}
+	for _, tcase := range []struct {
+		name          string
+		query         string
+		wantCode      int
+		wantInstances []string
+	}{
+		{name: "no filters", wantCode: http.StatusOK, wantInstances: []string{"a", "b", "c"}},
+		{name: "state filter", query: "state=firing", wantCode: http.StatusOK, wantInstances: []string{"a", "c"}},
+		{name: "match filter", query: `match[]={instance=~"a|b"}&match[]={alertname="none"}`, wantCode: http.StatusOK, wantInstances: []string{"a", "b"}},
+		{name: "rule filter", query: "rule_name[]=pending", wantCode: http.StatusOK, wantInstances: []string{"b"}},
+		{name: "group filter", query: "rule_group[]=group-2", wantCode: http.StatusOK, wantInstances: []string{"c"}},
+		{name: "file filter", query: "file[]=/etc/rules/rules__ns-1__example.yaml&state=pending", wantCode: http.StatusOK, wantInstances: []string{"b"}},
+		{name: "invalid state", query: "state=inactive", wantCode: http.StatusBadRequest},
+		{name: "invalid match", query: "match[]={", wantCode: http.StatusBadRequest},
+		{name: "invalid include_source", query: "include_source=foo", wantCode: http.StatusBadRequest},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			api.HandleAlertsEndpoint(w, httptest.NewRequest(http.MethodGet, alertsEndpoint+"?"+tcase.query, nil))
+			require.Equal(t, tcase.wantCode, w.Code)
+
+			var resp testAlertsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if tcase.wantCode != http.StatusOK {
+				assert.Equal(t, "error", resp.Status)
+				return
+			}
+			var instances []string
+			for _, a := range resp.Data.Alerts {
+				instances = append(instances, a.Labels["instance"])
+				assert.Nil(t, a.Source)
+			}
+			slices.Sort(instances)
+			assert.Equal(t, tcase.wantInstances, instances)
+		})
+	}
+
+	t.Run("include source", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		api.HandleAlertsEndpoint(w, httptest.NewRequest(http.MethodGet, alertsEndpoint+"?include_source=true&state=firing", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp testAlertsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		sources := map[string]*alertSource{}
+		for _, a := range resp.Data.Alerts {
+			assert.Equal(t, "firing", a.State)
+			sources[a.Labels["instance"]] = a.Source
+		}
+		assert.Equal(t, map[string]*alertSource{
+			"a": {Rule: "firing", Group: "group-1", File: "/etc/rules/rules__ns-1__example.yaml", Kind: "Rules", Namespace: "ns-1", Name: "example"},
+			"c": {Rule: "firing", Group: "group-2", File: "/etc/rules/clusterrules__example.yaml", Kind: "ClusterRules", Name: "example"},
+		}, sources)
+	})
+}
+
+func TestNewAlertSource(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		file string
+		want alertSource
+	}{
+		{file: "/etc/rules/rules__ns__name.yaml", want: alertSource{Kind: "Rules", Namespace: "ns", Name: "name"}},
+		{file: "clusterrules__name.yaml", want: alertSource{Kind: "ClusterRules", Name: "name"}},
+		{file: "/etc/rules/globalrules__name.yaml", want: alertSource{Kind: "GlobalRules", Name: "name"}},
+		{file: "/etc/rules/custom.yaml"},
+		{file: "/etc/rules/rules__ns__name.yml"},
+	} {
+		t.Run(tcase.file, func(t *testing.T) {
+			t.Parallel()
+
+			tcase.want.Rule, tcase.want.Group, tcase.want.File = "rule", "group", tcase.file
+			assert.Equal(t, &tcase.want, newAlertSource("rule", "group", tcase.file))
+		})
+	}
+}
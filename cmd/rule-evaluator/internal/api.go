@@ -71,13 +71,18 @@ type RuleRetriever interface {
 // API provides an HTTP API singleton for handling http endpoints in the rule evaluator.
 type API struct {
 	rulesManager RuleRetriever
+	queryFunc    rules.QueryFunc
+	history      RuleHistoryRetriever
 	logger       log.Logger
 }
 
-// NewAPI creates a new API instance.
-func NewAPI(logger log.Logger, rulesManager RuleRetriever) *API {
+// NewAPI creates a new API instance. The query function is used to evaluate rules on request.
+// The history may be nil if no evaluation history is kept.
+func NewAPI(logger log.Logger, rulesManager RuleRetriever, queryFunc rules.QueryFunc, history RuleHistoryRetriever) *API {
 	return &API{
 		rulesManager: rulesManager,
+		queryFunc:    queryFunc,
+		history:      history,
 		logger:       logger,
 	}
 }
@@ -137,22 +142,25 @@ func alertsToAPIAlerts(alerts []*rules.Alert) []*apiv1.Alert {
 		})
 	}
 	// Sort for testability.
-	slices.SortFunc(apiAlerts, func(a, b *apiv1.Alert) int {
-		ha, hb := a.Labels.Hash(), b.Labels.Hash()
-		if ha != hb {
-			if ha > hb {
-				return -1
-			}
-			return 1
+	slices.SortFunc(apiAlerts, compareAPIAlerts)
+	return apiAlerts
+}
+
+// compareAPIAlerts orders alerts by their labels, annotations and state.
+func compareAPIAlerts(a, b *apiv1.Alert) int {
+	ha, hb := a.Labels.Hash(), b.Labels.Hash()
+	if ha != hb {
+		if ha > hb {
+			return -1
 		}
-		ha, hb = a.Annotations.Hash(), b.Annotations.Hash()
-		if ha != hb {
-			if ha > hb {
-				return -1
-			}
-			return 1
+		return 1
+	}
+	ha, hb = a.Annotations.Hash(), b.Annotations.Hash()
+	if ha != hb {
+		if ha > hb {
+			return -1
 		}
-		return strings.Compare(a.State, b.State)
-	})
-	return apiAlerts
+		return 1
+	}
+	return strings.Compare(a.State, b.State)
 }
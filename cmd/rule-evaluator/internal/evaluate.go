@@ -0,0 +1,230 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	apiv1 "github.com/prometheus/prometheus/web/api/v1"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	rulesEvaluateEndpoint = "/api/v1/rules/evaluate"
+
+	startQueryParamName = "start"
+	endQueryParamName   = "end"
+	stepQueryParamName  = "step"
+
+	// defaultEvaluationStep is the step used if neither the request nor the rule group
+	// set an interval. Matches the Prometheus default evaluation interval.
+	defaultEvaluationStep = time.Minute
+	// maxEvaluationSteps limits the number of evaluations of each rule in a request,
+	// as every evaluation runs a query against GCM.
+	maxEvaluationSteps = 1000
+	// maxRuleGroupBytes limits the size of the request body.
+	maxRuleGroupBytes = 1 << 20
+)
+
+type rulesEvaluateEndpointResponse struct {
+	Steps []*evaluationStep `json:"steps"`
+}
+
+// evaluationStep holds the results of evaluating all rules of a group at one timestamp.
+type evaluationStep struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Rules     []*evaluatedRule `json:"rules"`
+}
+
+// evaluatedRule holds the results of one rule evaluation.
+type evaluatedRule struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Series are the samples that would be written for the rule.
+	Series promql.Vector `json:"series"`
+	// Alerts are the active alerts of an alerting rule after the evaluation.
+	Alerts []*apiv1.Alert `json:"alerts,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// HandleRulesEvaluateEndpoint evaluates the rule group in the request body over a time range
+// without writing results or sending alerts. The rule group is accepted as YAML or JSON in the
+// Prometheus rule file format, or in the format of the rule groups of the Rules resources.
+// The time range is set through the start, end and step parameters, which behave like the ones
+// of the Prometheus range query API. The step defaults to the group's interval.
+//
+// Rules are evaluated through the same query function as the running rule groups. Results of
+// a recording rule are not visible to subsequent rules in the group, as nothing is written.
+func (api *API) HandleRulesEvaluateEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.writeError(w, errorBadData, "only POST requests are allowed", http.StatusMethodNotAllowed, rulesEvaluateEndpoint)
+		return
+	}
+	if api.queryFunc == nil {
+		api.writeError(w, errorUnavailable, "rule evaluation is not available", http.StatusServiceUnavailable, rulesEvaluateEndpoint)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRuleGroupBytes))
+	if err != nil {
+		api.writeError(w, errorBadData, fmt.Sprintf("failed to read request body: %s", err), http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+	group, err := parseRuleGroup(body)
+	if err != nil {
+		api.writeError(w, errorBadData, err.Error(), http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+	groupRules, err := newRules(group, api.logger)
+	if err != nil {
+		api.writeError(w, errorBadData, err.Error(), http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+
+	query := r.URL.Query()
+	end := time.Now()
+	if s := query.Get(endQueryParamName); s != "" {
+		if end, err = promapi.ParseTime(s); err != nil {
+			api.writeError(w, errorBadData, fmt.Sprintf("invalid end parameter: %s", err), http.StatusBadRequest, rulesEvaluateEndpoint)
+			return
+		}
+	}
+	start := end
+	if s := query.Get(startQueryParamName); s != "" {
+		if start, err = promapi.ParseTime(s); err != nil {
+			api.writeError(w, errorBadData, fmt.Sprintf("invalid start parameter: %s", err), http.StatusBadRequest, rulesEvaluateEndpoint)
+			return
+		}
+	}
+	if end.Before(start) {
+		api.writeError(w, errorBadData, "end timestamp must not be before start time", http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+	step := time.Duration(group.Interval)
+	if step == 0 {
+		step = defaultEvaluationStep
+	}
+	if s := query.Get(stepQueryParamName); s != "" {
+		if step, err = promapi.ParseDuration(s); err != nil {
+			api.writeError(w, errorBadData, fmt.Sprintf("invalid step parameter: %s", err), http.StatusBadRequest, rulesEvaluateEndpoint)
+			return
+		}
+	}
+	if step <= 0 {
+		api.writeError(w, errorBadData, "zero or negative step is not accepted", http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+	if end.Sub(start)/step >= maxEvaluationSteps {
+		api.writeError(w, errorBadData, fmt.Sprintf("exceeded maximum of %d evaluation steps", maxEvaluationSteps), http.StatusBadRequest, rulesEvaluateEndpoint)
+		return
+	}
+
+	var queryOffset time.Duration
+	if group.QueryOffset != nil {
+		queryOffset = time.Duration(*group.QueryOffset)
+	}
+
+	resp := rulesEvaluateEndpointResponse{Steps: []*evaluationStep{}}
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		if err := r.Context().Err(); err != nil {
+			api.writeError(w, errorCanceled, err.Error(), http.StatusServiceUnavailable, rulesEvaluateEndpoint)
+			return
+		}
+		evalStep := &evaluationStep{Timestamp: ts}
+		for _, rule := range groupRules {
+			res := &evaluatedRule{Name: rule.Name(), Series: promql.Vector{}}
+			vec, err := rule.Eval(r.Context(), queryOffset, ts, api.queryFunc, nil, group.Limit)
+			if err != nil {
+				res.Error = err.Error()
+			} else if vec != nil {
+				res.Series = vec
+			}
+			switch rule := rule.(type) {
+			case *rules.AlertingRule:
+				res.Type = ruleKindAlerting
+				res.Alerts = alertsToAPIAlerts(rule.ActiveAlerts())
+			case *rules.RecordingRule:
+				res.Type = ruleKindRecording
+			}
+			evalStep.Rules = append(evalStep.Rules, res)
+		}
+		resp.Steps = append(resp.Steps, evalStep)
+	}
+	api.writeSuccessResponse(w, http.StatusOK, rulesEvaluateEndpoint, resp)
+}
+
+// parseRuleGroup parses and validates a single rule group.
+func parseRuleGroup(b []byte) (*rulefmt.RuleGroup, error) {
+	var group rulefmt.RuleGroup
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&group); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("missing rule group in request body")
+		}
+		return nil, fmt.Errorf("failed to parse rule group: %w", err)
+	}
+	// Run the upstream validation on the group.
+	b, err := yaml.Marshal(rulefmt.RuleGroups{Groups: []rulefmt.RuleGroup{group}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rule group: %w", err)
+	}
+	groups, errs := rulefmt.Parse(b)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid rule group: %w", errors.Join(errs...))
+	}
+	return &groups.Groups[0], nil
+}
+
+// newRules creates the rules of a group the same way the rules manager does. Alerting rules
+// are considered restored so that they return their series.
+func newRules(group *rulefmt.RuleGroup, logger log.Logger) ([]rules.Rule, error) {
+	var res []rules.Rule
+	for _, r := range group.Rules {
+		expr, err := parser.ParseExpr(r.Expr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression %q: %w", r.Expr.Value, err)
+		}
+		if r.Alert.Value != "" {
+			res = append(res, rules.NewAlertingRule(
+				r.Alert.Value,
+				expr,
+				time.Duration(r.For),
+				time.Duration(r.KeepFiringFor),
+				labels.FromMap(r.Labels),
+				labels.FromMap(r.Annotations),
+				labels.EmptyLabels(),
+				"",
+				true,
+				log.With(logger, "alert", r.Alert.Value),
+			))
+			continue
+		}
+		res = append(res, rules.NewRecordingRule(r.Record.Value, expr, labels.FromMap(r.Labels)))
+	}
+	return res, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvaluateResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Steps []struct {
+			Timestamp time.Time `json:"timestamp"`
+			Rules     []struct {
+				Name   string `json:"name"`
+				Type   string `json:"type"`
+				Series []struct {
+					Metric map[string]string `json:"metric"`
+					Value  []any             `json:"value"`
+				} `json:"series"`
+				Alerts []struct {
+					Labels map[string]string `json:"labels"`
+					State  string            `json:"state"`
+				} `json:"alerts"`
+				Error string `json:"error"`
+			} `json:"rules"`
+		} `json:"steps"`
+	} `json:"data"`
+}
+
+func TestAPI_HandleRulesEvaluateEndpoint(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1700000000, 0).UTC()
+	var queries []string
+	queryFunc := func(_ context.Context, q string, ts time.Time) (promql.Vector, error) {
+		queries = append(queries, q)
+		if q == "broken" {
+			return nil, errors.New("query failed")
+		}
+		return promql.Vector{
+			promql.Sample{T: timestamp.FromTime(ts), F: 1, Metric: labels.FromStrings("job", "foo")},
+		}, nil
+	}
+	api := NewAPI(log.NewNopLogger(), nil, queryFunc, nil)
+
+	evaluate := func(t *testing.T, method, params, body string) (int, testEvaluateResponse) {
+		t.Helper()
+		r := httptest.NewRequest(method, rulesEvaluateEndpoint+"?"+params, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		api.HandleRulesEvaluateEndpoint(w, r)
+
+		var resp testEvaluateResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	t.Run("prometheus format", func(t *testing.T) {
+		queries = nil
+		code, resp := evaluate(t, http.MethodPost, "start=1700000000&end=1700000120", `
+name: test
+interval: 1m
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    source: dry-run
+- alert: Up
+  expr: up == 1
+  for: 2m
+- record: broken:sum
+  expr: broken
+`)
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		require.Len(t, resp.Data.Steps, 3)
+		assert.Len(t, queries, 9)
+
+		for i, step := range resp.Data.Steps {
+			assert.Equal(t, start.Add(time.Duration(i)*time.Minute), step.Timestamp)
+			require.Len(t, step.Rules, 3)
+
+			recording := step.Rules[0]
+			assert.Equal(t, "job:up:sum", recording.Name)
+			assert.Equal(t, ruleKindRecording, recording.Type)
+			require.Len(t, recording.Series, 1)
+			assert.Equal(t, map[string]string{"__name__": "job:up:sum", "job": "foo", "source": "dry-run"}, recording.Series[0].Metric)
+			assert.Empty(t, recording.Alerts)
+
+			alerting := step.Rules[1]
+			assert.Equal(t, "Up", alerting.Name)
+			assert.Equal(t, ruleKindAlerting, alerting.Type)
+			require.Len(t, alerting.Alerts, 1)
+			assert.Equal(t, map[string]string{"alertname": "Up", "job": "foo"}, alerting.Alerts[0].Labels)
+			// ALERTS and ALERTS_FOR_STATE.
+			assert.Len(t, alerting.Series, 2)
+
+			assert.Equal(t, "query failed", step.Rules[2].Error)
+			assert.Empty(t, step.Rules[2].Series)
+		}
+		assert.Equal(t, "pending", resp.Data.Steps[0].Rules[1].Alerts[0].State)
+		assert.Equal(t, "pending", resp.Data.Steps[1].Rules[1].Alerts[0].State)
+		assert.Equal(t, "firing", resp.Data.Steps[2].Rules[1].Alerts[0].State)
+	})
+
+	t.Run("resource format", func(t *testing.T) {
+		body, err := json.Marshal(monitoringv1.RuleGroup{
+			Name:     "test",
+			Interval: "30s",
+			Rules: []monitoringv1.Rule{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			},
+		})
+		require.NoError(t, err)
+
+		code, resp := evaluate(t, http.MethodPost, "start=2023-11-14T22:13:20Z&end=2023-11-14T22:14:20Z", string(body))
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		// The step defaults to the group interval.
+		require.Len(t, resp.Data.Steps, 3)
+		assert.Equal(t, start.Add(30*time.Second), resp.Data.Steps[1].Timestamp)
+	})
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		params   string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "wrong method",
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "missing group",
+			method:   http.MethodPost,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid group",
+			method:   http.MethodPost,
+			body:     "name: test\nrules:\n- record: foo\n  alert: bar\n  expr: up\n",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown field",
+			method:   http.MethodPost,
+			body:     "name: test\nrulez: []\n",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "end before start",
+			method:   http.MethodPost,
+			params:   "start=1700000120&end=1700000000",
+			body:     "name: test\nrules:\n- record: foo\n  expr: up\n",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "too many steps",
+			method:   http.MethodPost,
+			params:   "start=0&end=1700000000&step=1",
+			body:     "name: test\nrules:\n- record: foo\n  expr: up\n",
+			wantCode: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := evaluate(t, tc.method, tc.params, tc.body)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, "error", resp.Status)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+)
+
+const rulesHistoryEndpoint = "/api/v1/rules/history"
+
+// RuleHistoryRetriever provides the recent evaluations of a rule.
+type RuleHistoryRetriever interface {
+	// RuleHistory returns the recent evaluations of the rule in the group, newest first.
+	RuleHistory(group *rules.Group, rule rules.Rule) []RuleEvaluation
+}
+
+// RuleEvaluation holds the outcome of one evaluation of a rule.
+type RuleEvaluation struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Duration is the evaluation duration in seconds.
+	Duration float64 `json:"duration"`
+	// Samples is the number of samples returned by the rule query.
+	Samples  int      `json:"samples"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// AlertTransitions are the alert state changes caused by the evaluation of an alerting rule.
+	AlertTransitions []AlertTransition `json:"alertTransitions,omitempty"`
+}
+
+// AlertTransition is a state change of an alert. States are inactive, pending or firing.
+type AlertTransition struct {
+	Labels labels.Labels `json:"labels"`
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+}
+
+type rulesHistoryEndpointResponse struct {
+	Groups []*ruleGroupHistory `json:"groups"`
+}
+
+type ruleGroupHistory struct {
+	Name  string         `json:"name"`
+	File  string         `json:"file"`
+	Rules []*ruleHistory `json:"rules"`
+}
+
+type ruleHistory struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"`
+	Query       string           `json:"query"`
+	Labels      labels.Labels    `json:"labels,omitempty"`
+	Evaluations []RuleEvaluation `json:"evaluations"`
+}
+
+// HandleRulesHistoryEndpoint returns the recent evaluations of all rules. Unlike the rules
+// endpoint, which only holds the last evaluation, this shows intermittent failures and
+// warnings. The rules can be filtered by their name, group and file.
+func (api *API) HandleRulesHistoryEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		api.writeError(w, errorBadData, "failed to parse request parameters", http.StatusBadRequest, rulesHistoryEndpoint)
+		return
+	}
+
+	ruleFilters := sanitizeFilterList(r.Form[ruleFilterQueryParamName])
+	fileFilters := sanitizeFilterList(r.Form[fileFilterQueryParamName])
+	groupFilters := sanitizeFilterList(r.Form[groupFilterQueryParamName])
+
+	groups := []*ruleGroupHistory{}
+	for _, group := range api.rulesManager.RuleGroups() {
+		if len(groupFilters) > 0 && !slices.Contains(groupFilters, group.Name()) {
+			continue
+		}
+		if len(fileFilters) > 0 && !slices.Contains(fileFilters, group.File()) {
+			continue
+		}
+		groupHistory := &ruleGroupHistory{Name: group.Name(), File: group.File(), Rules: []*ruleHistory{}}
+		for _, rule := range group.Rules() {
+			if len(ruleFilters) > 0 && !slices.Contains(ruleFilters, rule.Name()) {
+				continue
+			}
+			h := &ruleHistory{
+				Name:        rule.Name(),
+				Type:        ruleKindRecording,
+				Query:       rule.Query().String(),
+				Labels:      rule.Labels(),
+				Evaluations: []RuleEvaluation{},
+			}
+			if _, ok := rule.(*rules.AlertingRule); ok {
+				h.Type = ruleKindAlerting
+			}
+			if api.history != nil {
+				if evaluations := api.history.RuleHistory(group, rule); evaluations != nil {
+					h.Evaluations = evaluations
+				}
+			}
+			groupHistory.Rules = append(groupHistory.Rules, h)
+		}
+		if len(groupHistory.Rules) > 0 {
+			groups = append(groups, groupHistory)
+		}
+	}
+
+	api.writeSuccessResponse(w, http.StatusOK, rulesHistoryEndpoint, rulesHistoryEndpointResponse{Groups: groups})
+}
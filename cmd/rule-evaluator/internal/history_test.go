@@ -0,0 +1,104 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ruleHistoryRetrieverMock map[string][]RuleEvaluation
+
+func (m ruleHistoryRetrieverMock) RuleHistory(_ *rules.Group, r rules.Rule) []RuleEvaluation {
+	return m[r.Name()]
+}
+
+func TestAPI_HandleRulesHistoryEndpoint(t *testing.T) {
+	t.Parallel()
+
+	groups := []*rules.Group{
+		rules.NewGroup(rules.GroupOptions{
+			Name: "group-1",
+			File: "/etc/rules/a.yaml",
+			Opts: &rules.ManagerOptions{},
+			Rules: []rules.Rule{
+				rules.NewRecordingRule("recording", &parser.NumberLiteral{Val: 1}, labels.EmptyLabels()),
+				rules.NewAlertingRule("alerting", &parser.NumberLiteral{Val: 1}, 0, 0, labels.FromStrings("severity", "critical"), labels.EmptyLabels(), nil, "", true, log.NewNopLogger()),
+			},
+		}),
+		rules.NewGroup(rules.GroupOptions{
+			Name:  "group-2",
+			File:  "/etc/rules/b.yaml",
+			Opts:  &rules.ManagerOptions{},
+			Rules: []rules.Rule{rules.NewRecordingRule("other", &parser.NumberLiteral{Val: 2}, labels.EmptyLabels())},
+		}),
+	}
+	ts := time.Unix(1700000000, 0).UTC()
+	history := ruleHistoryRetrieverMock{
+		"recording": {
+			{Timestamp: ts.Add(time.Minute), Duration: 0.5, Samples: 1, Warnings: []string{"partial data"}},
+			{Timestamp: ts, Duration: 0.25, Error: "query failed"},
+		},
+		"alerting": {
+			{Timestamp: ts, Samples: 1, AlertTransitions: []AlertTransition{{Labels: labels.FromStrings("alertname", "alerting"), From: "inactive", To: "firing"}}},
+		},
+	}
+	api := NewAPI(log.NewNopLogger(), RuleGroupsRetrieverMock{RuleGroupsFunc: func() []*rules.Group { return groups }}, nil, history)
+
+	for _, tcase := range []struct {
+		name         string
+		query        string
+		expectedJSON string
+	}{
+		{
+			name:         "rule filter",
+			query:        "rule_name[]=recording",
+			expectedJSON: `{"status":"success","data":{"groups":[{"name":"group-1","file":"/etc/rules/a.yaml","rules":[{"name":"recording","type":"recording","query":"1","evaluations":[{"timestamp":"2023-11-14T22:14:20Z","duration":0.5,"samples":1,"warnings":["partial data"]},{"timestamp":"2023-11-14T22:13:20Z","duration":0.25,"samples":0,"error":"query failed"}]}]}]}}`,
+		},
+		{
+			name:         "alerting rule",
+			query:        "rule_name[]=alerting",
+			expectedJSON: `{"status":"success","data":{"groups":[{"name":"group-1","file":"/etc/rules/a.yaml","rules":[{"name":"alerting","type":"alerting","query":"1","labels":{"severity":"critical"},"evaluations":[{"timestamp":"2023-11-14T22:13:20Z","duration":0,"samples":1,"alertTransitions":[{"labels":{"alertname":"alerting"},"from":"inactive","to":"firing"}]}]}]}]}}`,
+		},
+		{
+			name:         "group filter without history",
+			query:        "rule_group[]=group-2",
+			expectedJSON: `{"status":"success","data":{"groups":[{"name":"group-2","file":"/etc/rules/b.yaml","rules":[{"name":"other","type":"recording","query":"2","evaluations":[]}]}]}}`,
+		},
+		{
+			name:         "file filter without match",
+			query:        "file[]=/etc/rules/c.yaml",
+			expectedJSON: `{"status":"success","data":{"groups":[]}}`,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			api.HandleRulesHistoryEndpoint(w, httptest.NewRequest(http.MethodGet, rulesHistoryEndpoint+"?"+tcase.query, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tcase.expectedJSON, w.Body.String())
+		})
+	}
+}
@@ -15,12 +15,16 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/rules"
 	apiv1 "github.com/prometheus/prometheus/web/api/v1"
 )
@@ -55,7 +59,55 @@ func sanitizeFilterList(filters []string) []string {
 }
 
 type rulesEndpointResponse struct {
-	Groups []*apiv1.RuleGroup `json:"groups"`
+	Groups         []*apiv1.RuleGroup `json:"groups"`
+	GroupNextToken string             `json:"groupNextToken,omitempty"`
+}
+
+// parseMatchersParam parses the series selectors of the match[] parameter.
+// Adapted from github.com/prometheus/prometheus/web/api/v1.
+func parseMatchersParam(matchers []string) ([][]*labels.Matcher, error) {
+	var matcherSets [][]*labels.Matcher
+	for _, s := range matchers {
+		selector, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		matcherSets = append(matcherSets, selector)
+	}
+
+OUTER:
+	for _, ms := range matcherSets {
+		for _, lm := range ms {
+			if lm != nil && !lm.Matches("") {
+				continue OUTER
+			}
+		}
+		return nil, errors.New("match[] must contain at least one non-empty matcher")
+	}
+	return matcherSets, nil
+}
+
+// matchesMatcherSets returns whether the labels match any of the matcher sets. Labels match an
+// empty list of matcher sets.
+func matchesMatcherSets(matcherSets [][]*labels.Matcher, lset labels.Labels) bool {
+	if len(matcherSets) == 0 {
+		return true
+	}
+	for _, matchers := range matcherSets {
+		if matchesAll(lset, matchers) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(lset labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lset.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
 }
 
 func (api *API) HandleRulesEndpoint(w http.ResponseWriter, r *http.Request) {
@@ -68,13 +120,15 @@ func (api *API) HandleRulesEndpoint(w http.ResponseWriter, r *http.Request) {
 	fileFilters := sanitizeFilterList(r.Form[fileFilterQueryParamName])
 	groupFilters := sanitizeFilterList(r.Form[groupFilterQueryParamName])
 
-	matchFilters := sanitizeFilterList(r.Form[matchFilterQueryParamName])
-	if len(matchFilters) > 0 {
-		// Todo: Once the github.com/prometheus/prometheus dependency is updated to v2.54.0 or higher, we can add
-		//       support for labels-matcher filter, using the match[] request parameter.
-		// Ref:  https://github.com/prometheus/prometheus/releases/tag/v2.54.0
-		// Ref:  https://github.com/prometheus/prometheus/pull/10194
-		api.writeError(w, errorBadData, "match[] parameter is not supported yet", http.StatusBadRequest, rulesEndpoint)
+	matcherSets, err := parseMatchersParam(sanitizeFilterList(r.Form[matchFilterQueryParamName]))
+	if err != nil {
+		api.writeError(w, errorBadData, fmt.Sprintf("invalid match[] parameter: %s", err), http.StatusBadRequest, rulesEndpoint)
+		return
+	}
+
+	groupLimit, groupNextToken, err := promapi.ParseRuleGroupsPagination(r.Form)
+	if err != nil {
+		api.writeError(w, errorBadData, err.Error(), http.StatusBadRequest, rulesEndpoint)
 		return
 	}
 
@@ -93,14 +147,20 @@ func (api *API) HandleRulesEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 	shouldExcludeAlertsFromAlertRules := excludeAlertsParam == "true"
 
-	apiGroups := api.groupsToAPIGroups(api.rulesManager.RuleGroups(), ruleFilters, fileFilters, groupFilters, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules)
-	responseObject := rulesEndpointResponse{Groups: apiGroups}
+	apiGroups := api.groupsToAPIGroups(api.rulesManager.RuleGroups(), ruleFilters, matcherSets, fileFilters, groupFilters, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules)
+	// The rules manager returns the groups sorted by file and name, which keeps the pages stable.
+	apiGroups, nextToken, err := promapi.PaginateRuleGroups(apiGroups, groupLimit, groupNextToken)
+	if err != nil {
+		api.writeError(w, errorBadData, err.Error(), http.StatusBadRequest, rulesEndpoint)
+		return
+	}
+	responseObject := rulesEndpointResponse{Groups: apiGroups, GroupNextToken: nextToken}
 
 	api.writeSuccessResponse(w, http.StatusOK, rulesEndpoint, responseObject)
 }
 
 // groupsToAPIGroups converts a slice of rules.Group to a slice of apiv1.RuleGroup.
-func (api *API) groupsToAPIGroups(groups []*rules.Group, ruleFilters, fileFilters, groupFilters []string, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules bool) []*apiv1.RuleGroup {
+func (api *API) groupsToAPIGroups(groups []*rules.Group, ruleFilters []string, matcherSets [][]*labels.Matcher, fileFilters, groupFilters []string, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules bool) []*apiv1.RuleGroup {
 	apiGroups := []*apiv1.RuleGroup{} // don't pre-allocate, we don't know how many rule groups we will return.
 	for _, group := range groups {
 		// If a rule_group parameter was specified, skip the rule group if it doesn't match any of the specified values.
@@ -113,7 +173,7 @@ func (api *API) groupsToAPIGroups(groups []*rules.Group, ruleFilters, fileFilter
 			continue
 		}
 
-		apiGroup := api.groupToAPIGroup(group, ruleFilters, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules)
+		apiGroup := api.groupToAPIGroup(group, ruleFilters, matcherSets, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules)
 
 		// If we filtered out all rules from the group, skip the group.
 		if len(apiGroup.Rules) == 0 {
@@ -126,7 +186,7 @@ func (api *API) groupsToAPIGroups(groups []*rules.Group, ruleFilters, fileFilter
 }
 
 // groupToAPIGroup converts a rules.Group to an apiv1.RuleGroup.
-func (api *API) groupToAPIGroup(group *rules.Group, ruleFilters []string, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules bool) *apiv1.RuleGroup {
+func (api *API) groupToAPIGroup(group *rules.Group, ruleFilters []string, matcherSets [][]*labels.Matcher, shouldReturnAlertRules, shouldReturnRecordingRules, shouldExcludeAlertsFromAlertRules bool) *apiv1.RuleGroup {
 	apiGroupRules := []apiv1.Rule{}
 	for _, groupRules := range group.Rules() {
 		// If a rule_name parameter was specified, skip the rule if it doesn't match any of the specified values.
@@ -134,6 +194,11 @@ func (api *API) groupToAPIGroup(group *rules.Group, ruleFilters []string, should
 			continue
 		}
 
+		// If a match parameter was specified, skip the rule if its labels don't match any of the specified selectors.
+		if !matchesMatcherSets(matcherSets, groupRules.Labels()) {
+			continue
+		}
+
 		switch rule := groupRules.(type) {
 		case *rules.AlertingRule:
 			if !shouldReturnAlertRules {
@@ -22,6 +22,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/GoogleCloudPlatform/prometheus-engine/internal/promapi"
 	"github.com/go-kit/log"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
@@ -44,6 +45,26 @@ func (r RuleGroupsRetrieverMock) AlertingRules() []*rules.AlertingRule {
 	return r.AlertingRulesFunc()
 }
 
+var testPaginationGroups = []*rules.Group{
+	testPaginationGroup("group-1", "rule-1", "a"),
+	testPaginationGroup("group-2", "rule-2", "b"),
+	testPaginationGroup("group-3", "rule-3", "b"),
+}
+
+func testPaginationGroup(name, rule, team string) *rules.Group {
+	return rules.NewGroup(rules.GroupOptions{
+		Name:     name,
+		File:     "file",
+		Interval: time.Minute,
+		Opts:     &rules.ManagerOptions{},
+		Rules:    []rules.Rule{rules.NewRecordingRule(rule, &parser.NumberLiteral{Val: 1}, labels.FromStrings("team", team))},
+	})
+}
+
+func testPaginationGroupJSON(name, rule, team string) string {
+	return fmt.Sprintf(`{"name":%q,"file":"file","interval":60,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z","rules":[{"name":%q,"query":"1","labels":{"team":%q},"health":"unknown","evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z","type":"recording"}]}`, name, rule, team)
+}
+
 func TestAPI_HandleRulesEndpoint(t *testing.T) {
 	t.Parallel()
 
@@ -55,10 +76,56 @@ func TestAPI_HandleRulesEndpoint(t *testing.T) {
 		expectedResponseBody string
 	}{
 		{
-			name:                 "match filters parameter not supported",
-			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?match[]=foo", nil),
+			name:                 "invalid match filter",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?match[]=foo{", nil),
+			expectedResponseCode: http.StatusBadRequest,
+			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"invalid match[] parameter: 1:5: parse error: unexpected end of input inside braces"}`,
+		},
+		{
+			name:                 "empty match filter",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+`?match[]={foo=""}`, nil),
 			expectedResponseCode: http.StatusBadRequest,
-			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"match[] parameter is not supported yet"}`,
+			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"invalid match[] parameter: match[] must contain at least one non-empty matcher"}`,
+		},
+		{
+			name:                 "match filter",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+`?match[]={team="a"}`, nil),
+			rules:                testPaginationGroups,
+			expectedResponseCode: http.StatusOK,
+			expectedResponseBody: `{"status":"success","data":{"groups":[` + testPaginationGroupJSON("group-1", "rule-1", "a") + `]}}`,
+		},
+		{
+			name:                 "invalid group limit",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?group_limit=0", nil),
+			expectedResponseCode: http.StatusBadRequest,
+			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"group_limit needs to be greater than 0"}`,
+		},
+		{
+			name:                 "next token without group limit",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?group_next_token=foo", nil),
+			expectedResponseCode: http.StatusBadRequest,
+			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"group_limit needs to be present in order to paginate over the groups"}`,
+		},
+		{
+			name:                 "invalid next token",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?group_limit=1&group_next_token=foo", nil),
+			rules:                testPaginationGroups,
+			expectedResponseCode: http.StatusBadRequest,
+			expectedResponseBody: `{"status":"error","errorType":"bad_data","error":"invalid group_next_token"}`,
+		},
+		{
+			name:                 "first page",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?group_limit=2", nil),
+			rules:                testPaginationGroups,
+			expectedResponseCode: http.StatusOK,
+			expectedResponseBody: `{"status":"success","data":{"groups":[` + testPaginationGroupJSON("group-1", "rule-1", "a") + `,` + testPaginationGroupJSON("group-2", "rule-2", "b") + `],"groupNextToken":"` + promapi.RuleGroupNextToken("file", "group-3") + `"}}`,
+		},
+		{
+			name:                 "last page",
+			req:                  httptest.NewRequest(http.MethodGet, rulesEndpoint+"?group_limit=2&group_next_token="+promapi.RuleGroupNextToken("file", "group-3"), nil),
+			rules:                testPaginationGroups,
+			expectedResponseCode: http.StatusOK,
+			expectedResponseBody: `{"status":"success","data":{"groups":[` + testPaginationGroupJSON("group-3", "rule-3", "b") + `]}}`,
 		},
 		{
 			name:                 "invalid rule type filter",
@@ -242,7 +309,7 @@ func TestAPI_groupsToAPIGroups(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			api := &API{logger: log.NewNopLogger()}
-			result := api.groupsToAPIGroups(tt.args.groups, nil, tt.args.fileFilters, tt.args.groupFilters, true, true, false)
+			result := api.groupsToAPIGroups(tt.args.groups, nil, nil, tt.args.fileFilters, tt.args.groupFilters, true, true, false)
 			assert.Len(t, result, len(tt.want))
 			for i := range result {
 				assert.Equal(t, tt.want[i].Name, result[i].Name) // no need to deep-assert, test below do that already.
@@ -256,6 +323,7 @@ func TestAPI_groupToAPIGroup(t *testing.T) {
 	type args struct {
 		group                             *rules.Group
 		ruleFilters                       []string
+		matcherSets                       [][]*labels.Matcher
 		shouldReturnAlertRules            bool
 		shouldReturnRecordingRules        bool
 		shouldExcludeAlertsFromAlertRules bool
@@ -392,6 +460,55 @@ func TestAPI_groupToAPIGroup(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "skips rules due to matcherSets parameter",
+			args: args{
+				group: rules.NewGroup(rules.GroupOptions{
+					Name:     "test-group",
+					File:     "test-file",
+					Interval: time.Second * 10,
+					Limit:    100,
+					Opts:     &rules.ManagerOptions{},
+					Rules: []rules.Rule{
+						rules.NewRecordingRule("test-1", &parser.NumberLiteral{Val: 11}, []labels.Label{{Name: "foo", Value: "bar"}}),
+						rules.NewRecordingRule("test-2", &parser.NumberLiteral{Val: 22}, []labels.Label{{Name: "bar", Value: "baz"}}),
+						rules.NewAlertingRule("test-3", &parser.NumberLiteral{Val: 33}, time.Hour, time.Hour*4, []labels.Label{{Name: "severity", Value: "critical"}}, nil, nil, "", false, log.NewNopLogger()),
+						rules.NewAlertingRule("test-4", &parser.NumberLiteral{Val: 44}, time.Hour*3, time.Hour*4, []labels.Label{{Name: "severity", Value: "warning"}}, nil, nil, "", false, log.NewNopLogger()),
+					},
+				}),
+				ruleFilters: []string{},
+				matcherSets: [][]*labels.Matcher{
+					{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")},
+					{labels.MustNewMatcher(labels.MatchRegexp, "severity", "crit.*")},
+				},
+				shouldReturnAlertRules:            true,
+				shouldReturnRecordingRules:        true,
+				shouldExcludeAlertsFromAlertRules: false,
+			},
+			want: &apiv1.RuleGroup{
+				Name:           "test-group",
+				File:           "test-file",
+				Interval:       10,
+				Limit:          100,
+				EvaluationTime: 0,
+				LastEvaluation: time.Time{},
+				Rules: []apiv1.Rule{
+					&apiv1.RecordingRule{Name: "test-1", Query: "11", Labels: []labels.Label{{Name: "foo", Value: "bar"}}, Type: ruleKindRecording},
+					&apiv1.AlertingRule{
+						State:         "inactive",
+						Name:          "test-3",
+						Query:         "33",
+						Duration:      3600,
+						KeepFiringFor: 14400,
+						Labels:        []labels.Label{{Name: "severity", Value: "critical"}},
+						Annotations:   []labels.Label{},
+						Alerts:        []*apiv1.Alert{},
+						Health:        rules.HealthUnknown,
+						Type:          ruleKindAlerting,
+					},
+				},
+			},
+		},
 		{
 			name: "skips alert rules due to shouldReturnAlertRules parameter",
 			args: args{
@@ -482,7 +599,7 @@ func TestAPI_groupToAPIGroup(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			api := &API{logger: log.NewNopLogger()}
-			result := api.groupToAPIGroup(tt.args.group, tt.args.ruleFilters, tt.args.shouldReturnAlertRules, tt.args.shouldReturnRecordingRules, tt.args.shouldExcludeAlertsFromAlertRules)
+			result := api.groupToAPIGroup(tt.args.group, tt.args.ruleFilters, tt.args.matcherSets, tt.args.shouldReturnAlertRules, tt.args.shouldReturnRecordingRules, tt.args.shouldExcludeAlertsFromAlertRules)
 			// deep Eval.
 			assert.Equal(t, tt.want.Name, result.Name)
 			assert.Equal(t, tt.want.File, result.File)
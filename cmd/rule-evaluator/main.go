@@ -27,9 +27,11 @@ import (
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -66,8 +68,8 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tracing"
 	"github.com/prometheus/prometheus/util/annotations"
-	"github.com/prometheus/prometheus/util/strutil"
 
 	// Import to enable 'kubernetes_sd_configs' to SD config register.
 	_ "github.com/prometheus/prometheus/discovery/kubernetes"
@@ -87,7 +89,7 @@ var (
 			Name: "rule_evaluator_query_requests_total",
 			Help: "A counter for query requests sent to GCM.",
 		},
-		[]string{"code", "method"},
+		[]string{"code", "method", "error_class"},
 	)
 	queryHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -107,6 +109,9 @@ func main() {
 	logger = log.With(logger, "caller", log.DefaultCaller)
 
 	a := kingpin.New("rule", "The Prometheus Rule Evaluator")
+	a.Command("run", "Evaluate rules continuously.").Default()
+	backfillCmd := a.Command("backfill", "Evaluate recording rules over a past time range and write the results to Google Cloud Monitoring.")
+	testRulesCmd := a.Command("test-rules", "Run unit tests of Rules, ClusterRules and GlobalRules resources, scoped as by the operator.")
 	logLevel := a.Flag("log.level",
 		"The level of logging. Can be one of 'debug', 'info', 'warn', 'error'").Default(
 		"info").Enum("debug", "info", "warn", "error")
@@ -152,16 +157,54 @@ func main() {
 		ListenAddress: ":9091",
 		ConfigFile:    "prometheus.yml",
 		QueueCapacity: 10000,
+		// Defaults match the Prometheus server.
+		OutageTolerance: time.Hour,
+		ForGracePeriod:  10 * time.Minute,
+		QueryRetry: queryRetryOptions{
+			MaxRetries: 3,
+			MinBackoff: 250 * time.Millisecond,
+			MaxBackoff: 5 * time.Second,
+		},
+		HistorySize: 20,
 	}
 	defaultEvaluatorOpts.setupFlags(a)
 
+	haOpts := haOptions{
+		Mode:          haModeNone,
+		KubeName:      "rule-evaluator",
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+	haOpts.setupFlags(a)
+
+	shadowFlag := a.Flag("rules.shadow", "Evaluate rules without writing the results or sending alerts. Both are logged and counted instead. Overridden by the rule_evaluator.shadow configuration setting.").
+		Default("false").Bool()
+
+	remoteWriteOpts := remoteWriteOptions{
+		WALPath:       "data-remote-write/",
+		FlushDeadline: time.Minute,
+	}
+	remoteWriteOpts.setupFlags(a)
+
+	backfillOpts := backfillOptions{
+		ChunkSize:        time.Hour,
+		SamplesPerSecond: 1000,
+		FlushTimeout:     5 * time.Minute,
+	}
+	backfillOpts.setupFlags(backfillCmd)
+
+	var ruleTestOpts ruleTestOptions
+	ruleTestOpts.setupFlags(testRulesCmd)
+
 	extraArgs, err := exportsetup.ExtraArgs()
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "Error parsing commandline arguments", "err", err)
 		a.Usage(os.Args[1:])
 		os.Exit(2)
 	}
-	if _, err := a.Parse(append(os.Args[1:], extraArgs...)); err != nil {
+	cmd, err := a.Parse(append(os.Args[1:], extraArgs...))
+	if err != nil {
 		_ = level.Error(logger).Log("msg", "Error parsing commandline arguments", "err", err)
 		a.Usage(os.Args[1:])
 		os.Exit(2)
@@ -177,10 +220,49 @@ func main() {
 		logger = level.NewFilter(logger, level.AllowInfo())
 	}
 
+	// Rule unit tests run locally and don't depend on the evaluation flags.
+	if cmd == testRulesCmd.FullCommand() {
+		// Test series are stored in a TSDB, which writes through the global exporter. They
+		// must never be exported.
+		if err := exportsetup.SetGlobal(export.NopExporter()); err != nil {
+			_ = level.Error(logger).Log("msg", "Unable to set global exporter", "err", err)
+			os.Exit(1)
+		}
+		success, err := runRuleTests(os.Stdout, &ruleTestOpts)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "invalid command line argument", "err", err)
+			os.Exit(2)
+		}
+		if !success {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := defaultEvaluatorOpts.validate(); err != nil {
 		_ = level.Error(logger).Log("msg", "invalid command line argument", "err", err)
 		os.Exit(1)
 	}
+	if err := haOpts.validate(); err != nil {
+		_ = level.Error(logger).Log("msg", "invalid command line argument", "err", err)
+		os.Exit(1)
+	}
+	if err := remoteWriteOpts.validate(); err != nil {
+		_ = level.Error(logger).Log("msg", "invalid command line argument", "err", err)
+		os.Exit(1)
+	}
+
+	if cmd == backfillCmd.FullCommand() {
+		if err := backfillOpts.validate(time.Now()); err != nil {
+			_ = level.Error(logger).Log("msg", "invalid command line argument", "err", err)
+			os.Exit(1)
+		}
+		if err := runBackfill(ctx, logger, reg, &opts, &defaultEvaluatorOpts, &backfillOpts); err != nil {
+			_ = level.Error(logger).Log("msg", "Backfill failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	startTime := time.Now()
 
@@ -200,47 +282,90 @@ func main() {
 	}
 	notificationManager := notifier.NewManager(&notifierOptions, log.With(logger, "component", "notifier"))
 	rulesMetrics := rules.NewGroupMetrics(reg)
-	ruleEvaluator, err := newRuleEvaluator(ctx, logger, &defaultEvaluatorOpts, version.Version, destination, notificationManager, rulesMetrics)
+	tracingManager := tracing.NewManager(logger)
+	ha := newHACoordinator(ctx, log.With(logger, "component", "ha"), reg, haOpts)
+	shadow := newShadowMode(log.With(logger, "component", "shadow"), reg, *shadowFlag)
+	remoteWrite := newRemoteWriteStorage(logger, reg, remoteWriteOpts)
+	// GCM export only supports float samples, native histogram rule results are written as
+	// classic histogram series. Remote write destinations receive them as they are.
+	appendable := haAppendable{
+		Appendable: shadowAppendable{
+			Appendable: newFanoutAppendable(reg,
+				fanoutDestination{name: "gcm", Appendable: histogramAppendable{destination}},
+				fanoutDestination{name: "remote_write", Appendable: remoteWrite},
+			),
+			shadow: shadow,
+		},
+		ha: ha,
+	}
+	ruleEvaluator, err := newRuleEvaluator(ctx, logger, &defaultEvaluatorOpts, version.Version, appendable, notificationManager, rulesMetrics, ha, shadow)
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "Create rule-evaluator", "err", err)
 		os.Exit(1)
 	}
 
+	configStatus := &configStatus{}
 	reloaders := []reloader{
 		{
-			name:     "notify",
-			reloader: notificationManager.ApplyConfig,
+			name: "notify",
+			reloader: func(cfg *config) error {
+				return notificationManager.ApplyConfig(&cfg.Config)
+			},
 		}, {
-			name:     "exporter",
-			reloader: destination.ApplyConfig,
+			name: "exporter",
+			reloader: func(cfg *config) error {
+				return destination.ApplyConfig(&cfg.Config)
+			},
+		}, {
+			name: "remote_write",
+			reloader: func(cfg *config) error {
+				return remoteWrite.ApplyConfig(&cfg.Config)
+			},
 		}, {
 			name: "notify_sd",
-			reloader: func(cfg *promforkconfig.Config) error {
+			reloader: func(cfg *config) error {
 				c := make(map[string]discovery.Configs)
 				for k, v := range cfg.AlertingConfig.AlertmanagerConfigs.ToMap() {
 					c[k] = v.ServiceDiscoveryConfigs
 				}
 				return discoveryManager.ApplyConfig(c)
 			},
+		}, {
+			name: "ha",
+			reloader: func(cfg *config) error {
+				return ha.ApplyConfig(cfg.RuleEvaluator.HighAvailability)
+			},
+		}, {
+			name: "shadow",
+			reloader: func(cfg *config) error {
+				shadow.ApplyConfig(cfg.RuleEvaluator.Shadow)
+				return nil
+			},
 		}, {
 			name: "rules",
-			reloader: func(cfg *promforkconfig.Config) error {
-				// Don't modify defaults. Copy defaults and modify based on config.
-				evaluatorOpts := defaultEvaluatorOpts
-				if cfg.GoogleCloud.Query.CredentialsFile != "" {
-					evaluatorOpts.CredentialsFile = cfg.GoogleCloud.Query.CredentialsFile
+			reloader: func(cfg *config) error {
+				evaluatorOpts, err := defaultEvaluatorOpts.withConfig(&cfg.Config)
+				if err != nil {
+					return err
 				}
-				if cfg.GoogleCloud.Query.GeneratorURL != "" {
-					generatorURL, err := url.Parse(cfg.GoogleCloud.Query.GeneratorURL)
-					if err != nil {
-						return fmt.Errorf("unable to parse Google Cloud generator URL: %w", err)
-					}
-					evaluatorOpts.GeneratorURL = generatorURL
-				}
-				if cfg.GoogleCloud.Query.ProjectID != "" {
-					evaluatorOpts.ProjectID = cfg.GoogleCloud.Query.ProjectID
-				}
-				return ruleEvaluator.ApplyConfig(cfg, &evaluatorOpts)
+				ruleEvaluator.SetExportLabels(exportLabels(&cfg.Config, &opts.ExporterOpts))
+				return ruleEvaluator.ApplyConfig(&cfg.Config, evaluatorOpts, cfg.RuleEvaluator.QueryOverrides)
+			},
+		}, {
+			name: "alert_generator_url",
+			reloader: func(cfg *config) error {
+				return ruleEvaluator.ApplyGeneratorURL(cfg.RuleEvaluator.AlertGeneratorURL)
+			},
+		}, {
+			name: "tracing",
+			reloader: func(cfg *config) error {
+				return tracingManager.ApplyConfig(&cfg.Config)
+			},
+		}, {
+			name: "status",
+			reloader: func(cfg *config) error {
+				configStatus.ApplyConfig(cfg)
+				return nil
 			},
 		},
 	}
@@ -282,6 +407,15 @@ func main() {
 			ruleEvaluator.Stop()
 		})
 	}
+	{
+		// Tracing manager.
+		g.Add(func() error {
+			tracingManager.Run()
+			return nil
+		}, func(error) {
+			tracingManager.Stop()
+		})
+	}
 	{
 		// Notifier.
 		g.Add(func() error {
@@ -319,6 +453,19 @@ func main() {
 			cancelExporter()
 		})
 	}
+	{
+		// Remote write.
+		cancel := make(chan struct{})
+		g.Add(func() error {
+			<-cancel
+			return nil
+		}, func(error) {
+			if err := remoteWrite.Close(); err != nil {
+				_ = level.Error(logger).Log("msg", "Closing remote write storage failed", "err", err)
+			}
+			close(cancel)
+		})
+	}
 	cwd, err := os.Getwd()
 	reloadCh := make(chan chan error)
 	{
@@ -360,32 +507,26 @@ func main() {
 				ReloadConfigSuccess: configMetrics.lastReloadSuccess,
 				LastConfigTime:      configMetrics.lastReloadSuccessTime,
 			}
-			response := response{
-				Status: "success",
-				Data:   runtimeInfo,
-			}
-			data, err := json.Marshal(response)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to marshal status: %s", err), http.StatusInternalServerError)
-				return
-			}
-
-			if _, err := w.Write(data); err != nil {
-				_ = level.Error(logger).Log("msg", "Unable to write runtime info status", "err", err)
-			}
+			writeStatusResponse(logger, w, runtimeInfo)
 		})
+		// Show the applied configuration and flags, e.g. to check what the config-reloader delivered.
+		http.HandleFunc("/api/v1/status/config", configStatus.handlerFunc(logger))
+		http.HandleFunc("/api/v1/status/flags", flagsHandlerFunc(logger, a))
 
 		// https://prometheus.io/docs/prometheus/latest/querying/api/#build-information
 		buildInfoHandler := promapi.BuildinfoHandlerFunc(log.With(logger, "handler", "buildinfo"), "rule-evaluator", version.Version)
 		http.HandleFunc("/api/v1/status/buildinfo", buildInfoHandler)
 
 		// https://prometheus.io/docs/prometheus/latest/querying/api/#rules
-		apiHandler := internal.NewAPI(logger, ruleEvaluator.rulesManager)
+		apiHandler := internal.NewAPI(logger, ruleEvaluator.rulesManager, ruleEvaluator.queryFunc, ruleEvaluator.history)
 		http.HandleFunc("/api/v1/rules", apiHandler.HandleRulesEndpoint)
+		http.HandleFunc("/api/v1/rules/evaluate", apiHandler.HandleRulesEvaluateEndpoint)
+		http.HandleFunc("/api/v1/rules/history", apiHandler.HandleRulesHistoryEndpoint)
 		http.HandleFunc("/api/v1/rules/", http.NotFound)
 
 		// https://prometheus.io/docs/prometheus/latest/querying/api/#alerts
 		http.HandleFunc("/api/v1/alerts", apiHandler.HandleAlertsEndpoint)
+		http.HandleFunc("/api/v1/alertmanagers", alertmanagersHandlerFunc(logger, notificationManager))
 
 		g.Add(func() error {
 			_ = level.Info(logger).Log("msg", "Starting web server", "listen", defaultEvaluatorOpts.ListenAddress)
@@ -460,6 +601,14 @@ type evaluatorOptions struct {
 	ListenAddress   string
 	ConfigFile      string
 	QueueCapacity   int
+	OutageTolerance time.Duration
+	ForGracePeriod  time.Duration
+	QueryRetry      queryRetryOptions
+	// MaxConcurrentEvals is the maximum number of rules evaluated concurrently across all groups
+	// in addition to the groups' sequential evaluation. Zero disables concurrent evaluation.
+	MaxConcurrentEvals int64
+	// HistorySize is the number of evaluations kept per rule for the rules history endpoint.
+	HistorySize int
 }
 
 func (opts *evaluatorOptions) setupFlags(a *kingpin.Application) {
@@ -479,6 +628,18 @@ func (opts *evaluatorOptions) setupFlags(a *kingpin.Application) {
 		PlaceHolder("<FILE>").
 		StringVar(&opts.CredentialsFile)
 
+	a.Flag("query.retry.max-retries", "Maximum number of retries of a rule query that failed due to exhausted quota or unavailability. Retries are limited to the evaluation interval of the rule group.").
+		Default(strconv.Itoa(opts.QueryRetry.MaxRetries)).
+		IntVar(&opts.QueryRetry.MaxRetries)
+
+	a.Flag("query.retry.min-backoff", "Backoff before the first retry of a rule query. The backoff doubles with every retry and is jittered.").
+		Default(opts.QueryRetry.MinBackoff.String()).
+		DurationVar(&opts.QueryRetry.MinBackoff)
+
+	a.Flag("query.retry.max-backoff", "Maximum backoff between retries of a rule query.").
+		Default(opts.QueryRetry.MaxBackoff.String()).
+		DurationVar(&opts.QueryRetry.MaxBackoff)
+
 	a.Flag("query.debug.disable-auth", "Disable authentication (for debugging purposes).").
 		Default("false").
 		BoolVar(&opts.DisableAuth)
@@ -494,6 +655,22 @@ func (opts *evaluatorOptions) setupFlags(a *kingpin.Application) {
 	a.Flag("alertmanager.notification-queue-capacity", "The capacity of the queue for pending Alertmanager notifications.").
 		Default(strconv.Itoa(opts.QueueCapacity)).
 		IntVar(&opts.QueueCapacity)
+
+	a.Flag("rules.alert.for-outage-tolerance", "Max time to tolerate rule-evaluator outage for restoring \"for\" state of alert from the ALERTS_FOR_STATE series in Google Cloud Monitoring.").
+		Default(opts.OutageTolerance.String()).
+		DurationVar(&opts.OutageTolerance)
+
+	a.Flag("rules.alert.for-grace-period", "Minimum duration between alert and restored \"for\" state. This is maintained only for alerts with configured \"for\" time greater than grace period.").
+		Default(opts.ForGracePeriod.String()).
+		DurationVar(&opts.ForGracePeriod)
+
+	a.Flag("rules.max-concurrent-evals", "Maximum number of rules evaluated concurrently across all rule groups. Only rules that neither depend on the results of other rules in their group nor are depended on by them are evaluated concurrently. 0 evaluates all rules of a group sequentially.").
+		Default(strconv.FormatInt(opts.MaxConcurrentEvals, 10)).
+		Int64Var(&opts.MaxConcurrentEvals)
+
+	a.Flag("rules.history-size", "Number of recent evaluations kept per rule and served by the /api/v1/rules/history endpoint. 0 disables the history.").
+		Default(strconv.Itoa(opts.HistorySize)).
+		IntVar(&opts.HistorySize)
 }
 
 func (opts *evaluatorOptions) validate() error {
@@ -516,9 +693,50 @@ func (opts *evaluatorOptions) validate() error {
 		return fmt.Errorf("unable to parse --query.target-url value %q: %w", opts.TargetURL.String(), err)
 	}
 
+	if opts.OutageTolerance < 0 {
+		return fmt.Errorf("--rules.alert.for-outage-tolerance must not be negative, got %s", opts.OutageTolerance)
+	}
+	if opts.ForGracePeriod < 0 {
+		return fmt.Errorf("--rules.alert.for-grace-period must not be negative, got %s", opts.ForGracePeriod)
+	}
+	if opts.MaxConcurrentEvals < 0 {
+		return fmt.Errorf("--rules.max-concurrent-evals must not be negative, got %d", opts.MaxConcurrentEvals)
+	}
+	if opts.HistorySize < 0 {
+		return fmt.Errorf("--rules.history-size must not be negative, got %d", opts.HistorySize)
+	}
+	if opts.QueryRetry.MaxRetries < 0 {
+		return fmt.Errorf("--query.retry.max-retries must not be negative, got %d", opts.QueryRetry.MaxRetries)
+	}
+	if opts.QueryRetry.MinBackoff < 0 {
+		return fmt.Errorf("--query.retry.min-backoff must not be negative, got %s", opts.QueryRetry.MinBackoff)
+	}
+	if opts.QueryRetry.MaxBackoff < opts.QueryRetry.MinBackoff {
+		return fmt.Errorf("--query.retry.max-backoff must not be less than --query.retry.min-backoff, got %s", opts.QueryRetry.MaxBackoff)
+	}
+
 	return nil
 }
 
+// withConfig returns a copy of the options with the query settings of the configuration
+// file applied.
+func (opts evaluatorOptions) withConfig(cfg *promforkconfig.Config) (*evaluatorOptions, error) {
+	if cfg.GoogleCloud.Query.CredentialsFile != "" {
+		opts.CredentialsFile = cfg.GoogleCloud.Query.CredentialsFile
+	}
+	if cfg.GoogleCloud.Query.GeneratorURL != "" {
+		generatorURL, err := url.Parse(cfg.GoogleCloud.Query.GeneratorURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse Google Cloud generator URL: %w", err)
+		}
+		opts.GeneratorURL = generatorURL
+	}
+	if cfg.GoogleCloud.Query.ProjectID != "" {
+		opts.ProjectID = cfg.GoogleCloud.Query.ProjectID
+	}
+	return &opts, nil
+}
+
 func newAPI(ctx context.Context, opts *evaluatorOptions, version string) (v1.API, error) {
 	clientOpts := []option.ClientOption{
 		option.WithScopes("https://www.googleapis.com/auth/monitoring.read"),
@@ -537,11 +755,13 @@ func newAPI(ctx context.Context, opts *evaluatorOptions, version string) (v1.API
 	if err != nil {
 		return nil, err
 	}
-	roundTripper := promhttp.InstrumentRoundTripperCounter(queryCounter,
-		promhttp.InstrumentRoundTripperDuration(queryHistogram, transport))
+	roundTripper := classifyingRoundTripper{
+		next:    promhttp.InstrumentRoundTripperDuration(queryHistogram, transport),
+		counter: queryCounter,
+	}
 	client, err := api.NewClient(api.Config{
 		Address:      strings.ReplaceAll(opts.TargetURL.String(), projectIDVar, opts.ProjectID),
-		RoundTripper: roundTripper,
+		RoundTripper: tracingRoundTripper{next: roundTripper},
 	})
 	if err != nil {
 		return nil, err
@@ -565,33 +785,23 @@ func QueryFunc(ctx context.Context, q string, t time.Time, v1api v1.API) (parser
 	return v, warnings, err
 }
 
-// sendAlerts returns the rules.NotifyFunc for a Notifier.
-func sendAlerts(s *notifier.Manager, projectID string, generatorURL *url.URL) rules.NotifyFunc {
+// sendAlerts returns the rules.NotifyFunc for a Notifier. The generator URL of every alert
+// is built by the given function.
+func sendAlerts(s *notifier.Manager, generatorURL func(expr string, alert *rules.Alert) string) rules.NotifyFunc {
 	return func(_ context.Context, expr string, alerts ...*rules.Alert) {
 		var res []*notifier.Alert
 		for _, alert := range alerts {
 			a := &notifier.Alert{
-				StartsAt:    alert.FiredAt,
-				Labels:      alert.Labels,
-				Annotations: alert.Annotations,
+				StartsAt:     alert.FiredAt,
+				Labels:       alert.Labels,
+				Annotations:  alert.Annotations,
+				GeneratorURL: generatorURL(expr, alert),
 			}
 			if !alert.ResolvedAt.IsZero() {
 				a.EndsAt = alert.ResolvedAt
 			} else {
 				a.EndsAt = alert.ValidUntil
 			}
-			if generatorURL != nil {
-				if generatorURL.String() == googleCloudBaseURL.String() {
-					// Project ID is empty when the rule-evaluator is instantiated, before config-reloader runs.
-					if projectID != "" {
-						// If it's a GCM link (default), create the full URL for the alert.
-						a.GeneratorURL = googleCloudLink(projectID, expr, alert.FiredAt, alert.FiredAt.Add(-time.Hour)).String()
-					}
-				} else {
-					// Otherwise, if it was specified we assume it points to a Prometheus frontend.
-					a.GeneratorURL = generatorURL.String() + strutil.TableLinkForExpression(expr)
-				}
-			}
 			res = append(res, a)
 		}
 		if len(alerts) > 0 {
@@ -642,7 +852,7 @@ func googleCloudLink(projectID, expr string, endTime, startTime time.Time) *url.
 
 type reloader struct {
 	name     string
-	reloader func(*promforkconfig.Config) error
+	reloader func(*config) error
 }
 
 // configMetrics establishes reloading metrics similar to Prometheus' built-in ones.
@@ -718,8 +928,46 @@ func reloadConfig(filename string, logger log.Logger, metrics *configMetrics, rl
 	return nil
 }
 
-func loadConfig(content []byte) (*promforkconfig.Config, error) {
-	conf := promforkconfig.DefaultConfig
+// config is the rule-evaluator configuration file, i.e. a Prometheus configuration file with
+// an additional rule_evaluator section.
+type config struct {
+	promforkconfig.Config `yaml:",inline"`
+
+	RuleEvaluator ruleEvaluatorConfig `yaml:"rule_evaluator,omitempty"`
+}
+
+// ruleEvaluatorConfig holds rule-evaluator specific settings that override the flags.
+type ruleEvaluatorConfig struct {
+	HighAvailability haConfig `yaml:"high_availability,omitempty"`
+	// Shadow overrides the --rules.shadow flag if set.
+	Shadow *bool `yaml:"shadow,omitempty"`
+	// QueryOverrides evaluate matching rule groups against other projects or query targets.
+	// The first matching override applies.
+	QueryOverrides []queryOverride `yaml:"query_overrides,omitempty"`
+	// AlertGeneratorURL selects how the generator URL of alerts is built.
+	AlertGeneratorURL generatorURLConfig `yaml:"alert_generator_url,omitempty"`
+}
+
+func (c *config) UnmarshalYAML(value *yaml.Node) error {
+	// See: https://github.com/go-yaml/yaml/issues/125
+	// Since the Prometheus configuration uses a custom unmarshaler, it is unable to be
+	// unmarshal-ed unless we write our own.
+	if err := value.Decode(&c.Config); err != nil {
+		return err
+	}
+	// We must replicate the nested fields.
+	ruleEvaluatorConfig := struct {
+		RuleEvaluator ruleEvaluatorConfig `yaml:"rule_evaluator,omitempty"`
+	}{}
+	if err := value.Decode(&ruleEvaluatorConfig); err != nil {
+		return err
+	}
+	c.RuleEvaluator = ruleEvaluatorConfig.RuleEvaluator
+	return nil
+}
+
+func loadConfig(content []byte) (*config, error) {
+	conf := config{Config: promforkconfig.DefaultConfig}
 
 	// Don't expand external labels on config file loading. It's a feature we like but we
 	// want to remain compatible with Prometheus and this is still an experimental feature,
@@ -730,17 +978,33 @@ func loadConfig(content []byte) (*promforkconfig.Config, error) {
 	return &conf, nil
 }
 
+// exportLabels returns the labels the exporter attaches to all series that don't have them.
+// As in the exporter, project_id, location and cluster external labels take precedence over
+// the flag values.
+func exportLabels(cfg *promforkconfig.Config, opts *export.ExporterOpts) labels.Labels {
+	b := labels.NewBuilder(cfg.GlobalConfig.ExternalLabels)
+	for name, value := range map[string]string{
+		export.KeyProjectID: opts.ProjectID,
+		export.KeyLocation:  opts.Location,
+		export.KeyCluster:   opts.Cluster,
+	} {
+		if !cfg.GlobalConfig.ExternalLabels.Has(name) && value != "" {
+			b.Set(name, value)
+		}
+	}
+	return b.Labels()
+}
+
 // convertMetricToLabel converts model.Metric to labels.label.
 func convertMetricToLabel(metric model.Metric) labels.Labels {
-	ls := make(labels.Labels, 0, len(metric))
+	b := labels.NewScratchBuilder(len(metric))
 	for name, value := range metric {
-		l := labels.Label{
-			Name:  string(name),
-			Value: string(value),
-		}
-		ls = append(ls, l)
+		b.Add(string(name), string(value))
 	}
-	return ls
+	// Metrics are maps, so labels have to be sorted explicitly. Consumers such as the
+	// 'for' state restoration compare label sets by their string representation.
+	b.Sort()
+	return b.Labels()
 }
 
 // convertModelToPromQLValue converts model.Value type to promql type.
@@ -756,9 +1020,17 @@ func convertModelToPromQLValue(val model.Value) (parser.Value, error) {
 					F: float64(samplePair.Value),
 				}
 			}
+			var hpts []promql.HPoint
+			for _, histogramPair := range result.Histograms {
+				hpts = append(hpts, promql.HPoint{
+					T: int64(histogramPair.Timestamp),
+					H: convertSampleHistogram(histogramPair.Histogram),
+				})
+			}
 			m[i] = promql.Series{
-				Metric: convertMetricToLabel(result.Metric),
-				Floats: pts,
+				Metric:     convertMetricToLabel(result.Metric),
+				Floats:     pts,
+				Histograms: hpts,
 			}
 		}
 		return m, nil
@@ -768,14 +1040,30 @@ func convertModelToPromQLValue(val model.Value) (parser.Value, error) {
 		for i, result := range results {
 			v[i] = promql.Sample{
 				T:      int64(result.Timestamp),
-				F:      float64(result.Value),
 				Metric: convertMetricToLabel(result.Metric),
 			}
+			if result.Histogram != nil {
+				v[i].H = convertSampleHistogram(result.Histogram)
+			} else {
+				v[i].F = float64(result.Value)
+			}
 		}
 		return v, nil
 
+	case *model.Scalar:
+		return promql.Scalar{
+			T: int64(results.Timestamp),
+			V: float64(results.Value),
+		}, nil
+
+	case *model.String:
+		return promql.String{
+			T: int64(results.Timestamp),
+			V: results.Value,
+		}, nil
+
 	default:
-		return nil, fmt.Errorf("expected Prometheus results of type matrix or vector. actual results type: %v", val.Type())
+		return nil, fmt.Errorf("expected Prometheus results of type matrix, vector, scalar or string. actual results type: %v", val.Type())
 	}
 }
 
@@ -821,27 +1109,59 @@ func newListSeriesSet(v promql.Matrix, err error, w v1.Warnings) *listSeriesSet
 	return &listSeriesSet{m: v, idx: -1, err: err, warnings: convertV1WarningsToAnnotations(w)}
 }
 
+// convertMatchersToSelector converts []*labels.Matcher to a PromQL series selector.
+func convertMatchersToSelector(matchers []*labels.Matcher) string {
+	metricLabels := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		metricLabels = append(metricLabels, m.String())
+	}
+	return fmt.Sprintf("{%s}", strings.Join(metricLabels, ", "))
+}
+
 // convertMatchersToPromQL converts []*labels.Matcher to a PromQL query.
 func convertMatchersToPromQL(matchers []*labels.Matcher, d int64) (string, []string) {
-	metricLabels := make([]string, 0, len(matchers))
 	filteredMatchers := make([]string, 0, len(matchers))
 	for _, m := range matchers {
-		metricLabels = append(metricLabels, m.String())
 		filteredMatchers = append(filteredMatchers, m.Name)
 	}
-	queryExpression := fmt.Sprintf("{%s}[%ds]", strings.Join(metricLabels, ", "), d)
+	queryExpression := fmt.Sprintf("%s[%ds]", convertMatchersToSelector(matchers), d)
 	return queryExpression, filteredMatchers
 }
 
+// queryClient holds the GCM query API client. The client can be swapped at runtime, so
+// that credential, project or target URL changes don't require a new rules manager.
+type queryClient struct {
+	mtx sync.RWMutex
+	api v1.API
+}
+
+func newQueryClient(api v1.API) *queryClient {
+	return &queryClient{api: api}
+}
+
+// API returns the current query API client.
+func (c *queryClient) API() v1.API {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.api
+}
+
+// Set replaces the query API client. In-flight queries finish with the previous client.
+func (c *queryClient) Set(api v1.API) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.api = api
+}
+
 // queryStorage implements storage.Queryable.
 type queryStorage struct {
-	api v1.API
+	client *queryClient
 }
 
 // Querier provides querying access over time series data of a fixed time range.
 func (s *queryStorage) Querier(mint, maxt int64) (storage.Querier, error) {
 	db := &queryAccess{
-		api:   s.api,
+		api:   s.client.API(),
 		mint:  mint / 1000, // divide by 1000 to convert milliseconds to seconds.
 		maxt:  maxt / 1000,
 		query: QueryFunc,
@@ -851,9 +1171,6 @@ func (s *queryStorage) Querier(mint, maxt int64) (storage.Querier, error) {
 
 // queryAccess implements storage.Querier.
 type queryAccess struct {
-	// storage.LabelQuerier satisfies the interface. Calling related methods will result in panic.
-	storage.LabelQuerier
-
 	api   v1.API
 	mint  int64
 	maxt  int64
@@ -861,19 +1178,26 @@ type queryAccess struct {
 }
 
 // Select returns a set of series that matches the given label matchers and time range.
-func (db *queryAccess) Select(ctx context.Context, sort bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
-	if sort || hints != nil {
-		return newListSeriesSet(nil, errors.New("sorting series and select hints are not supported"), nil)
+// If hints are given, the time range is narrowed to the hinted start and end time.
+func (db *queryAccess) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	mint, maxt := db.mint, db.maxt
+	if hints != nil {
+		// Hints are in milliseconds, the querier range is in seconds.
+		if start := hints.Start / 1000; hints.Start != 0 && start > mint {
+			mint = start
+		}
+		if end := hints.End / 1000; hints.End != 0 && end < maxt {
+			maxt = end
+		}
 	}
 
-	duration := db.maxt - db.mint
+	duration := maxt - mint
 	if duration <= 0 { // not a valid time duration.
 		return newListSeriesSet(nil, nil, nil)
 	}
 
-	queryExpression, filteredMatchers := convertMatchersToPromQL(matchers, duration)
-	maxt := time.Unix(db.maxt, 0)
-	v, warnings, err := db.query(ctx, queryExpression, maxt, db.api)
+	queryExpression, _ := convertMatchersToPromQL(matchers, duration)
+	v, warnings, err := db.query(ctx, queryExpression, time.Unix(maxt, 0), db.api)
 	if err != nil {
 		return newListSeriesSet(nil, err, warnings)
 	}
@@ -882,16 +1206,49 @@ func (db *queryAccess) Select(ctx context.Context, sort bool, hints *storage.Sel
 	if !ok {
 		return newListSeriesSet(nil, fmt.Errorf("error querying Prometheus, expected type matrix response. actual type %v", v.Type()), nil)
 	}
-	// TODO(maxamin) GCM returns label names and values that are not in matchers.
-	// Ensure results from query are equivalent to the requested matchers because
-	// manager.go checks if returned labels have the same length as matchers.
-	// Upstream change to prometheus code may be necessary.
-	for i, sample := range m {
-		m[i].Metric = sample.Metric.MatchLabels(true, filteredMatchers...)
+	if sortSeries {
+		slices.SortFunc(m, func(a, b promql.Series) int {
+			return labels.Compare(a.Metric, b.Metric)
+		})
 	}
 	return newListSeriesSet(m, err, warnings)
 }
 
+// LabelValues returns all potential values for a label name within the querier time range.
+// If matchers are specified the returned result set is reduced to values of matching series.
+func (db *queryAccess) LabelValues(ctx context.Context, name string, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	values, warnings, err := db.api.LabelValues(ctx, name, convertMatchersToMatches(matchers), time.Unix(db.mint, 0), time.Unix(db.maxt, 0))
+	if err != nil {
+		return nil, convertV1WarningsToAnnotations(warnings), fmt.Errorf("query label values: %w", err)
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, string(v))
+	}
+	slices.Sort(result)
+	return result, convertV1WarningsToAnnotations(warnings), nil
+}
+
+// LabelNames returns all label names within the querier time range. If matchers are
+// specified the returned result set is reduced to label names of matching series.
+func (db *queryAccess) LabelNames(ctx context.Context, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	names, warnings, err := db.api.LabelNames(ctx, convertMatchersToMatches(matchers), time.Unix(db.mint, 0), time.Unix(db.maxt, 0))
+	if err != nil {
+		return nil, convertV1WarningsToAnnotations(warnings), fmt.Errorf("query label names: %w", err)
+	}
+	slices.Sort(names)
+	return names, convertV1WarningsToAnnotations(warnings), nil
+}
+
+// convertMatchersToMatches converts []*labels.Matcher to the match[] parameter of the
+// label APIs. No matchers select all series.
+func convertMatchersToMatches(matchers []*labels.Matcher) []string {
+	if len(matchers) == 0 {
+		return nil
+	}
+	return []string{convertMatchersToSelector(matchers)}
+}
+
 func (db *queryAccess) Close() error {
 	return nil
 }
@@ -900,14 +1257,29 @@ type ruleEvaluator struct {
 	ctx             context.Context
 	logger          log.Logger
 	version         string
-	appendable      storage.Appendable
 	notifierManager *notifier.Manager
-	rulesMetrics    *rules.Metrics
+	ha              *haCoordinator
+	shadow          *shadowMode
+
+	client         *queryClient
+	queryOverrides *queryOverrides
+	queryable      *forStateQueryable
+	queryFunc      rules.QueryFunc
+	rulesManager   *rules.Manager
+	history        *ruleHistory
 
-	queryFunc         rules.QueryFunc
-	rulesManager      *rules.Manager
-	lastEvaluatorOpts *evaluatorOptions
 	mtx               sync.Mutex
+	lastEvaluatorOpts *evaluatorOptions
+	generatorURL      *generatorURL
+	queryOffset       time.Duration
+
+	// externalURL is the external URL of the rules manager, which groups read when expanding
+	// templates. Option changes publish the new URL to pendingExternalURL. It is copied to
+	// externalURL once no group evaluation holds evalMtx, so that neither option changes nor
+	// other groups wait for a slow evaluation.
+	evalMtx            sync.RWMutex
+	externalURL        *url.URL
+	pendingExternalURL atomic.Pointer[url.URL]
 }
 
 // Returns the URL that points to the rule-evaluator instance (set by the user). By default, or if
@@ -933,79 +1305,192 @@ func newRuleEvaluator(
 	appendable storage.Appendable,
 	notifierManager *notifier.Manager,
 	rulesMetrics *rules.Metrics,
+	ha *haCoordinator,
+	shadow *shadowMode,
 ) (*ruleEvaluator, error) {
 	v1api, err := newAPI(ctx, evaluatorOpts, version)
 	if err != nil {
 		return nil, fmt.Errorf("query client: %w", err)
 	}
-	queryFunc := newQueryFunc(logger, v1api)
+	client := newQueryClient(v1api)
 
-	rulesManager := rules.NewManager(&rules.ManagerOptions{
-		ExternalURL: getExternalURL(evaluatorOpts.GeneratorURL, evaluatorOpts.ProjectID),
-		QueryFunc:   queryFunc,
-		Context:     ctx,
-		Appendable:  appendable,
-		Queryable: &queryStorage{
-			api: v1api,
-		},
-		Logger:     logger,
-		NotifyFunc: sendAlerts(notifierManager, evaluatorOpts.ProjectID, evaluatorOpts.GeneratorURL),
-		Metrics:    rulesMetrics,
-	})
-
-	evaluator := ruleEvaluator{
+	e := &ruleEvaluator{
 		ctx:             ctx,
 		logger:          logger,
 		version:         version,
-		appendable:      appendable,
 		notifierManager: notifierManager,
-		rulesMetrics:    rulesMetrics,
 
-		rulesManager:      rulesManager,
-		queryFunc:         queryFunc,
+		ha:                ha,
+		shadow:            shadow,
+		client:            client,
+		queryOverrides:    newQueryOverrides(ctx, version),
+		queryable:         &forStateQueryable{Queryable: &queryStorage{client: client}},
+		history:           newRuleHistory(evaluatorOpts.HistorySize),
 		lastEvaluatorOpts: evaluatorOpts,
+		generatorURL:      &generatorURL{},
+		externalURL:       &url.URL{},
+	}
+	if u := getExternalURL(evaluatorOpts.GeneratorURL, evaluatorOpts.ProjectID); u != nil {
+		*e.externalURL = *u
 	}
+	e.queryFunc = newQueryFunc(logger, client, e.queryRetryOptions)
+	// The rules manager lives as long as the rule-evaluator. Option changes are applied
+	// through the query client and the notify function, so that group and alert state
+	// survive a reload.
+	e.rulesManager = rules.NewManager(&rules.ManagerOptions{
+		ExternalURL:     e.externalURL,
+		QueryFunc:       e.queryFunc,
+		Context:         ctx,
+		Appendable:      appendable,
+		Queryable:       e.queryable,
+		Logger:          logger,
+		NotifyFunc:      e.sendAlerts,
+		Metrics:         rulesMetrics,
+		OutageTolerance: evaluatorOpts.OutageTolerance,
+		ForGracePeriod:  evaluatorOpts.ForGracePeriod,
+		// Groups without a query offset of their own use the one of the global configuration.
+		DefaultRuleQueryOffset: e.ruleQueryOffset,
+		// Independent rules of a group are evaluated concurrently, so that groups with many
+		// slow queries finish within their interval.
+		ConcurrentEvalsEnabled: evaluatorOpts.MaxConcurrentEvals > 0,
+		MaxConcurrentEvals:     evaluatorOpts.MaxConcurrentEvals,
+	})
+	return e, nil
+}
 
-	return &evaluator, nil
+// sendAlerts implements rules.NotifyFunc with the generator URL settings of the last
+// applied options and configuration. Alerts are only sent by active replicas, and only logged in shadow mode.
+func (e *ruleEvaluator) sendAlerts(ctx context.Context, expr string, alerts ...*rules.Alert) {
+	if !e.ha.active() {
+		return
+	}
+	if e.shadow.active() {
+		e.shadow.discardAlerts(expr, alerts...)
+		return
+	}
+	e.mtx.Lock()
+	opts, gen := e.lastEvaluatorOpts, e.generatorURL
+	e.mtx.Unlock()
+	group := ruleGroupFromContext(ctx)
+	sendAlerts(e.notifierManager, func(expr string, alert *rules.Alert) string {
+		u, err := gen.url(newGeneratorURLData(opts, e.queryOverrides, group, expr, alert))
+		if err != nil {
+			_ = level.Warn(e.logger).Log("msg", "Failed to build alert generator URL", "expr", expr, "err", err)
+		}
+		return u
+	})(ctx, expr, alerts...)
 }
 
-func (e *ruleEvaluator) ApplyConfig(cfg *promforkconfig.Config, evaluatorOpts *evaluatorOptions) error {
-	if evaluatorOpts != nil && !reflect.DeepEqual(evaluatorOpts, e.lastEvaluatorOpts) {
-		e.lastEvaluatorOpts = evaluatorOpts
+// setExternalURL publishes the external URL used in templates. It is applied right away if
+// no group is being evaluated, or else before a later evaluation.
+func (e *ruleEvaluator) setExternalURL(u *url.URL) {
+	if u == nil {
+		u = &url.URL{}
+	}
+	e.pendingExternalURL.Store(u)
+	e.applyExternalURL()
+}
+
+// applyExternalURL copies the published external URL, if any, to the rules manager. It
+// never waits for running group evaluations and leaves the URL pending instead.
+func (e *ruleEvaluator) applyExternalURL() {
+	if e.pendingExternalURL.Load() == nil || !e.evalMtx.TryLock() {
+		return
+	}
+	defer e.evalMtx.Unlock()
+	if u := e.pendingExternalURL.Swap(nil); u != nil {
+		*e.externalURL = *u
+	}
+}
+
+// ApplyGeneratorURL sets how the generator URL of alerts is built.
+func (e *ruleEvaluator) ApplyGeneratorURL(cfg generatorURLConfig) error {
+	gen, err := newGeneratorURL(cfg)
+	if err != nil {
+		return err
+	}
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.generatorURL = gen
+	return nil
+}
+
+// ruleQueryOffset returns the rule query offset of the last applied configuration.
+func (e *ruleEvaluator) ruleQueryOffset() time.Duration {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.queryOffset
+}
+
+// queryRetryOptions returns the query retry settings of the last applied options.
+func (e *ruleEvaluator) queryRetryOptions() queryRetryOptions {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.lastEvaluatorOpts.QueryRetry
+}
+
+// evalIterationFunc implements rules.GroupEvalIterationFunc. Evaluations, including query
+// retries, must finish before the next evaluation of the group is due.
+func (e *ruleEvaluator) evalIterationFunc(ctx context.Context, g *rules.Group, evalTimestamp time.Time) {
+	ctx, cancel := context.WithDeadline(ctx, evalTimestamp.Add(g.Interval()))
+	defer cancel()
+	if api := e.queryOverrides.forGroup(g.File(), g.Name()); api != nil {
+		ctx = withQueryAPI(ctx, api)
+	}
+	ctx = withRuleGroup(ctx, g)
+	ctx, queries := withQueryRecord(ctx)
+	start := time.Now()
+	ctx, sp := startGroupSpan(ctx, g, evalTimestamp)
+	e.applyExternalURL()
+	e.evalMtx.RLock()
+	e.ha.evalIterationFunc(ctx, g, evalTimestamp)
+	e.evalMtx.RUnlock()
+	// The last running evaluation applies a URL published while it ran.
+	e.applyExternalURL()
+	endGroupSpan(sp, g, start)
+	e.history.record(g, start, queries)
+}
+
+// ApplyConfig applies the options, the query overrides of rule groups and the rules. The
+// overrides are applied first, so that new groups never query the default target and a
+// failed override leaves the rules unchanged.
+func (e *ruleEvaluator) ApplyConfig(cfg *promforkconfig.Config, evaluatorOpts *evaluatorOptions, overrides []queryOverride) error {
+	e.mtx.Lock()
+	changed := evaluatorOpts != nil && !reflect.DeepEqual(evaluatorOpts, e.lastEvaluatorOpts)
+	overrideOpts := e.lastEvaluatorOpts
+	e.mtx.Unlock()
 
+	if changed {
+		overrideOpts = evaluatorOpts
+	}
+	if err := e.queryOverrides.ApplyConfig(overrides, overrideOpts); err != nil {
+		return err
+	}
+
+	if changed {
 		v1api, err := newAPI(e.ctx, evaluatorOpts, e.version)
 		if err != nil {
 			return fmt.Errorf("query client: %w", err)
 		}
-		queryFunc := newQueryFunc(e.logger, v1api)
-
-		rulesManager := rules.NewManager(&rules.ManagerOptions{
-			ExternalURL: getExternalURL(evaluatorOpts.GeneratorURL, evaluatorOpts.ProjectID),
-			QueryFunc:   queryFunc,
-			Context:     e.ctx,
-			Appendable:  e.appendable,
-			Queryable: &queryStorage{
-				api: v1api,
-			},
-			Logger:     e.logger,
-			NotifyFunc: sendAlerts(e.notifierManager, evaluatorOpts.ProjectID, evaluatorOpts.GeneratorURL),
-			Metrics:    e.rulesMetrics,
-		})
+		// Swap the client in place. Running groups pick it up with their next evaluation.
+		e.client.Set(v1api)
 
-		// Set new rule-manager and flag before stopping, so we can rerun with the new one.
 		e.mtx.Lock()
-		oldRuleManager := e.rulesManager
-		e.rulesManager = rulesManager
-		oldRuleManager.Stop()
-		e.queryFunc = queryFunc
+		e.lastEvaluatorOpts = evaluatorOpts
 		e.mtx.Unlock()
 
-		_, err = queryFunc(e.ctx, "vector(1)", time.Now())
+		e.setExternalURL(getExternalURL(evaluatorOpts.GeneratorURL, evaluatorOpts.ProjectID))
+
+		_, err = e.queryFunc(e.ctx, "vector(1)", time.Now())
 		if err != nil {
 			_ = level.Error(e.logger).Log("msg", "Error querying Prometheus instance", "err", err)
 		}
 	}
 
+	e.mtx.Lock()
+	e.queryOffset = time.Duration(cfg.GlobalConfig.RuleQueryOffset)
+	e.mtx.Unlock()
+
 	// Get all rule files matching the configuration paths.
 	var files []string
 	for _, pat := range cfg.RuleFiles {
@@ -1015,58 +1500,78 @@ func (e *ruleEvaluator) ApplyConfig(cfg *promforkconfig.Config, evaluatorOpts *e
 		}
 		files = append(files, fs...)
 	}
-	return e.rulesManager.Update(
+	if err := e.rulesManager.Update(
 		time.Duration(cfg.GlobalConfig.EvaluationInterval),
 		files,
 		cfg.GlobalConfig.ExternalLabels,
 		"",
-		nil,
-	)
+		e.evalIterationFunc,
+	); err != nil {
+		return err
+	}
+	e.ha.syncGroups(e.rulesManager.RuleGroups())
+	e.queryable.SetAlertingRules(e.rulesManager.AlertingRules())
+	e.history.sync(e.rulesManager.RuleGroups())
+	return nil
+}
+
+// SetExportLabels sets the labels the exporter attaches to series that don't have them,
+// so that they can be told apart from rule labels when reading series back.
+func (e *ruleEvaluator) SetExportLabels(lset labels.Labels) {
+	e.queryable.SetExternalLabels(lset)
 }
 
 func (e *ruleEvaluator) Query(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
-	// Copy the function in case it changes, but don't block until it completes.
-	e.mtx.Lock()
-	queryFunc := e.queryFunc
-	e.mtx.Unlock()
-	return queryFunc(ctx, q, t)
+	return e.queryFunc(ctx, q, t)
 }
 
 func (e *ruleEvaluator) Run() {
-	for {
-		// Copy the rule-manager before running, so we don't hold the lock.
-		e.mtx.Lock()
-		curr := e.rulesManager
-		e.mtx.Unlock()
-
-		// A nil indicates shutdown, otherwise it's a config update requiring restart.
-		if curr == nil {
-			break
-		}
-		curr.Run()
-	}
+	e.rulesManager.Run()
 }
 
 func (e *ruleEvaluator) Stop() {
-	e.mtx.Lock()
-	defer e.mtx.Unlock()
 	e.rulesManager.Stop()
-	e.rulesManager = nil
+	e.ha.Stop()
 }
 
-func newQueryFunc(logger log.Logger, v1api v1.API) rules.QueryFunc {
+func newQueryFunc(logger log.Logger, client *queryClient, retryOpts func() queryRetryOptions) rules.QueryFunc {
 	return func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
-		v, warnings, err := QueryFunc(ctx, q, t, v1api)
-		if len(warnings) > 0 {
-			_ = level.Warn(logger).Log("msg", "Querying Prometheus instance returned warnings", "warn", warnings)
+		onRetry := func(err error, backoff time.Duration) {
+			_ = level.Debug(logger).Log("msg", "Retrying failed query", "query", q, "backoff", backoff, "err", err)
 		}
+		// Warnings of the last attempt are recorded for the rule history.
+		var lastWarnings v1.Warnings
+		v, err := retryQuery(ctx, retryOpts(), onRetry, func(ctx context.Context) (parser.Value, error) {
+			api := client.API()
+			// Query overrides of rule groups take precedence.
+			if a, ok := queryAPIFromContext(ctx); ok {
+				api = a
+			}
+			v, warnings, err := QueryFunc(ctx, q, t, api)
+			if len(warnings) > 0 {
+				_ = level.Warn(logger).Log("msg", "Querying Prometheus instance returned warnings", "warn", warnings)
+			}
+			lastWarnings = warnings
+			return v, err
+		})
 		if err != nil {
+			traceQuery(ctx, q, 0, err)
 			return nil, fmt.Errorf("execute query: %w", err)
 		}
-		vec, ok := v.(promql.Vector)
-		if !ok {
-			return nil, fmt.Errorf("query Prometheus, Expected type vector response. Actual type %v", v.Type())
+		// Rules accept scalar results the same way the Prometheus engine does.
+		var vec promql.Vector
+		switch v := v.(type) {
+		case promql.Vector:
+			vec = v
+		case promql.Scalar:
+			vec = promql.Vector{promql.Sample{T: v.T, F: v.V, Metric: labels.EmptyLabels()}}
+		default:
+			err := fmt.Errorf("query Prometheus, Expected type vector or scalar response. Actual type %v", v.Type())
+			traceQuery(ctx, q, 0, err)
+			return nil, err
 		}
+		traceQuery(ctx, q, len(vec), nil)
+		recordQuery(ctx, len(vec), lastWarnings)
 		return vec, nil
 	}
 }
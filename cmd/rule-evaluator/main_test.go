@@ -18,7 +18,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,9 +33,13 @@ import (
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/version"
+	promforkconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/google/export"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/util/annotations"
@@ -85,6 +94,7 @@ func TestSelect(t *testing.T) {
 	cases := []struct {
 		description string
 		db          *queryAccess
+		hints       *storage.SelectHints
 		want        *listSeriesSet
 	}{
 		// Success case.
@@ -115,6 +125,107 @@ func TestSelect(t *testing.T) {
 				}},
 			},
 		},
+		{
+			description: "select hints narrow time range",
+			db: &queryAccess{
+				mint: 1000,
+				maxt: 2000,
+				query: func(_ context.Context, q string, timeValue time.Time, _ v1.API) (parser.Value, v1.Warnings, error) {
+					maxt := time.Unix(1800, 0)
+					expectedQuery := "{__name__=\"testLabel\"}[600s]"
+					if q != expectedQuery {
+						return nil, nil, fmt.Errorf("Expected query to be: %s, Actual query: %s ", expectedQuery, q)
+					}
+					if timeValue != maxt {
+						return nil, nil, fmt.Errorf("Expected t to be: %s, Actual t: %s ", maxt.String(), timeValue.String())
+					}
+					return promql.Matrix{{
+						Metric: labels.FromStrings(model.MetricNameLabel, "testLabel"),
+						Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+					}}, nil, nil
+				},
+			},
+			hints: &storage.SelectHints{Start: 1200000, End: 1800000},
+			want: &listSeriesSet{
+				m: promql.Matrix{{
+					Metric: labels.FromStrings(model.MetricNameLabel, "testLabel"),
+					Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+				}},
+			},
+		},
+		{
+			description: "select hints outside of querier range are ignored",
+			db: &queryAccess{
+				mint: 1000,
+				maxt: 2000,
+				query: func(_ context.Context, q string, timeValue time.Time, _ v1.API) (parser.Value, v1.Warnings, error) {
+					expectedQuery := "{__name__=\"testLabel\"}[1000s]"
+					if q != expectedQuery {
+						return nil, nil, fmt.Errorf("Expected query to be: %s, Actual query: %s ", expectedQuery, q)
+					}
+					if maxt := time.Unix(2000, 0); timeValue != maxt {
+						return nil, nil, fmt.Errorf("Expected t to be: %s, Actual t: %s ", maxt.String(), timeValue.String())
+					}
+					return promql.Matrix{}, nil, nil
+				},
+			},
+			hints: &storage.SelectHints{Start: 500000, End: 3000000},
+			want: &listSeriesSet{
+				m: promql.Matrix{},
+			},
+		},
+		{
+			description: "full label sets are returned in order",
+			db: &queryAccess{
+				mint: 1000,
+				maxt: 2000,
+				query: func(context.Context, string, time.Time, v1.API) (parser.Value, v1.Warnings, error) {
+					return promql.Matrix{
+						{
+							Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "instance", "b"),
+							Floats: []promql.FPoint{{T: 1700000, F: 2.0}},
+						},
+						{
+							Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "instance", "a"),
+							Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+						},
+					}, nil, nil
+				},
+			},
+			want: &listSeriesSet{
+				m: promql.Matrix{
+					{
+						Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "instance", "a"),
+						Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+					},
+					{
+						Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "instance", "b"),
+						Floats: []promql.FPoint{{T: 1700000, F: 2.0}},
+					},
+				},
+			},
+		},
+		{
+			description: "external labels attached by the exporter are kept",
+			db: &queryAccess{
+				mint: 1000,
+				maxt: 2000,
+				query: func(context.Context, string, time.Time, v1.API) (parser.Value, v1.Warnings, error) {
+					return promql.Matrix{{
+						Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "cluster", "c2", "job", "foo", "location", "l1", "project_id", "p1"),
+						Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+					}}, nil, nil
+				},
+			},
+			want: &listSeriesSet{
+				m: promql.Matrix{
+					{
+						Metric: labels.FromStrings(model.MetricNameLabel, "testLabel", "cluster", "c2", "job", "foo", "location", "l1", "project_id", "p1"),
+						Floats: []promql.FPoint{{T: 1700000, F: 1.0}},
+					},
+				},
+			},
+		},
 		// Error cases.
 		{
 			description: "queryfunc returns an error",
@@ -172,7 +283,7 @@ func TestSelect(t *testing.T) {
 				t.Errorf("Case %d: NewMatcher returned unexpected error: %s", i, err)
 			}
 
-			got := c.db.Select(t.Context(), false, nil, matchers)
+			got := c.db.Select(t.Context(), true, c.hints, matchers)
 			if !cmp.Equal(got.Err(), c.want.Err(), cmp.Comparer(cmpErrsEquality)) {
 				t.Errorf("Case %d: Expected error: %s, Actual error: %s", i, c.want.Err(), got.Err())
 			}
@@ -186,6 +297,113 @@ func TestSelect(t *testing.T) {
 	}
 }
 
+// labelAPI implements the label endpoints of v1.API.
+type labelAPI struct {
+	v1.API
+
+	matches    []string
+	start, end time.Time
+	names      []string
+	values     model.LabelValues
+	warnings   v1.Warnings
+	err        error
+}
+
+func (a *labelAPI) LabelNames(_ context.Context, matches []string, start, end time.Time, _ ...v1.Option) ([]string, v1.Warnings, error) {
+	a.matches, a.start, a.end = matches, start, end
+	return a.names, a.warnings, a.err
+}
+
+func (a *labelAPI) LabelValues(_ context.Context, _ string, matches []string, start, end time.Time, _ ...v1.Option) (model.LabelValues, v1.Warnings, error) {
+	a.matches, a.start, a.end = matches, start, end
+	return a.values, a.warnings, a.err
+}
+
+func TestLabelNames(t *testing.T) {
+	api := &labelAPI{
+		names:    []string{"job", "__name__", "instance"},
+		warnings: v1.Warnings{"warning test"},
+	}
+	db := &queryAccess{api: api, mint: 1000, maxt: 2000}
+
+	got, warnings, err := db.LabelNames(t.Context(),
+		labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, "up"),
+		labels.MustNewMatcher(labels.MatchRegexp, "job", "foo.*"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"__name__", "instance", "job"}, got); diff != "" {
+		t.Errorf("unexpected label names: %s", diff)
+	}
+	if diff := cmp.Diff([]string{`{__name__="up", job=~"foo.*"}`}, api.matches); diff != "" {
+		t.Errorf("unexpected matches: %s", diff)
+	}
+	if !api.start.Equal(time.Unix(1000, 0)) || !api.end.Equal(time.Unix(2000, 0)) {
+		t.Errorf("unexpected time range [%s, %s]", api.start, api.end)
+	}
+	if !compareAnnotationsEquality(warnings, annotations.New().Add(errors.New("warning test"))) {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	api.err = errors.New("query error")
+	if _, _, err := db.LabelNames(t.Context()); err == nil {
+		t.Error("expected error")
+	}
+	if api.matches != nil {
+		t.Errorf("expected no matches, got %v", api.matches)
+	}
+}
+
+func TestLabelValues(t *testing.T) {
+	api := &labelAPI{
+		values: model.LabelValues{"b", "c", "a"},
+	}
+	db := &queryAccess{api: api, mint: 1000, maxt: 2000}
+
+	got, _, err := db.LabelValues(t.Context(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, got); diff != "" {
+		t.Errorf("unexpected label values: %s", diff)
+	}
+	if api.matches != nil {
+		t.Errorf("expected no matches, got %v", api.matches)
+	}
+
+	api.err = errors.New("query error")
+	if _, _, err := db.LabelValues(t.Context(), "job"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestExportLabels(t *testing.T) {
+	cfg := &promforkconfig.Config{}
+	cfg.GlobalConfig.ExternalLabels = labels.FromStrings("location", "l2", "team", "a")
+
+	got := exportLabels(cfg, &export.ExporterOpts{ProjectID: "p1", Location: "l1"})
+	want := labels.FromStrings("location", "l2", "project_id", "p1", "team", "a")
+	if !labels.Equal(want, got) {
+		t.Errorf("expected labels %s, got %s", want, got)
+	}
+}
+
+func TestConvertMetricToLabel(t *testing.T) {
+	got := convertMetricToLabel(model.Metric{
+		"zone":                "us-central1-a",
+		model.MetricNameLabel: "ALERTS_FOR_STATE",
+		"alertname":           "TestAlert",
+	})
+	want := labels.FromStrings(model.MetricNameLabel, "ALERTS_FOR_STATE", "alertname", "TestAlert", "zone", "us-central1-a")
+	if !labels.Equal(got, want) {
+		t.Errorf("expected sorted labels %s, got %s", want, got)
+	}
+	if got.String() != want.String() {
+		t.Errorf("expected label string %s, got %s", want, got)
+	}
+}
+
 // Regression test against b/470033222.
 func TestGracefulShutdown(t *testing.T) {
 	re, err := newRuleEvaluator(
@@ -196,6 +414,8 @@ func TestGracefulShutdown(t *testing.T) {
 		},
 		version.Version,
 		nil, nil, nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -209,3 +429,376 @@ func TestGracefulShutdown(t *testing.T) {
 	re.Stop()
 	wg.Wait()
 }
+
+func TestApplyConfigSwapsQueryClient(t *testing.T) {
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		&evaluatorOptions{
+			DisableAuth: true,
+			TargetURL:   &url.URL{},
+			ProjectID:   "project-a",
+		},
+		version.Version,
+		nil, nil, nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rulesManager := re.rulesManager
+	api := re.client.API()
+
+	// Same options must not replace the client.
+	if err := re.ApplyConfig(&promforkconfig.Config{}, &evaluatorOptions{
+		DisableAuth: true,
+		TargetURL:   &url.URL{},
+		ProjectID:   "project-a",
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if re.client.API() != api {
+		t.Error("expected query client to be unchanged")
+	}
+
+	if err := re.ApplyConfig(&promforkconfig.Config{}, &evaluatorOptions{
+		DisableAuth: true,
+		TargetURL:   &url.URL{},
+		ProjectID:   "project-b",
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if re.client.API() == api {
+		t.Error("expected query client to be replaced")
+	}
+	if re.rulesManager != rulesManager {
+		t.Error("expected rules manager to be kept")
+	}
+	if got := re.lastEvaluatorOpts.ProjectID; got != "project-b" {
+		t.Errorf("expected options for project %q, got %q", "project-b", got)
+	}
+}
+
+func TestConcurrentRuleEvaluation(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: test
+  rules:
+  - record: a
+    expr: vector(1)
+  - record: b
+    expr: vector(2)
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		maxConcurrentEvals int64
+		wantInFlight       int32
+	}{
+		{maxConcurrentEvals: 0, wantInFlight: 1},
+		{maxConcurrentEvals: 2, wantInFlight: 2},
+	} {
+		t.Run(fmt.Sprint(tc.maxConcurrentEvals), func(t *testing.T) {
+			var (
+				mtx                   sync.Mutex
+				inFlight, maxInFlight int32
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				mtx.Lock()
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				mtx.Unlock()
+				defer func() {
+					mtx.Lock()
+					inFlight--
+					mtx.Unlock()
+				}()
+				// Give concurrent queries time to arrive.
+				time.Sleep(100 * time.Millisecond)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]}]}}`))
+			}))
+			defer srv.Close()
+
+			app := &testAppender{}
+			re, err := newRuleEvaluator(
+				t.Context(), log.NewNopLogger(),
+				&evaluatorOptions{
+					DisableAuth:        true,
+					TargetURL:          Must(url.Parse(srv.URL)),
+					MaxConcurrentEvals: tc.maxConcurrentEvals,
+				},
+				version.Version,
+				testAppendable{app: app}, nil, nil,
+				newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+				newShadowMode(log.NewNopLogger(), nil, false),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &promforkconfig.Config{
+				GlobalConfig: promforkconfig.GlobalConfig{EvaluationInterval: model.Duration(time.Minute)},
+				RuleFiles:    []string{ruleFile},
+			}
+			if err := re.ApplyConfig(cfg, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			groups := re.rulesManager.RuleGroups()
+			if len(groups) != 1 {
+				t.Fatalf("expected 1 group, got %d", len(groups))
+			}
+			groups[0].Eval(t.Context(), time.Now())
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if maxInFlight != tc.wantInFlight {
+				t.Errorf("expected %d concurrent queries, got %d", tc.wantInFlight, maxInFlight)
+			}
+		})
+	}
+}
+
+func TestRuleQueryOffset(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: default
+  rules:
+  - record: a
+    expr: vector(1)
+- name: own
+  query_offset: 5m
+  rules:
+  - record: b
+    expr: vector(2)
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mtx        sync.Mutex
+		queryTimes = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		mtx.Lock()
+		queryTimes[r.Form.Get("query")] = r.Form.Get("time")
+		mtx.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		&evaluatorOptions{DisableAuth: true, TargetURL: Must(url.Parse(srv.URL))},
+		version.Version,
+		testAppendable{app: &testAppender{}}, nil, nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := re.ApplyConfig(&promforkconfig.Config{
+		GlobalConfig: promforkconfig.GlobalConfig{
+			EvaluationInterval: model.Duration(time.Minute),
+			RuleQueryOffset:    model.Duration(2 * time.Minute),
+		},
+		RuleFiles: []string{ruleFile},
+	}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Unix(1700000000, 0)
+	groups := re.rulesManager.RuleGroups()
+	for _, g := range groups {
+		g.Eval(t.Context(), ts)
+	}
+	want := map[string]string{
+		"vector(1)": "1699999880",
+		"vector(2)": "1699999700",
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if diff := cmp.Diff(want, queryTimes); diff != "" {
+		t.Errorf("unexpected query times (-want, +got): %s", diff)
+	}
+
+	// Groups with the same interval are evaluated at different offsets within the interval,
+	// derived from a hash of their name and file.
+	offset := func(g *rules.Group) time.Duration {
+		return time.Duration(g.EvalTimestamp(ts.UnixNano()).UnixNano() % int64(g.Interval()))
+	}
+	if offset(groups[0]) == offset(groups[1]) {
+		t.Errorf("expected different evaluation offsets of groups, got %s", offset(groups[0]))
+	}
+}
+
+func TestApplyConfigUpdatesExternalURL(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: test
+  rules:
+  - alert: A
+    expr: vector(1)
+    annotations:
+      link: '{{ externalURL }}/alerts'
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]}]}}`))
+	}))
+	defer srv.Close()
+
+	opts := func(generatorURL string) *evaluatorOptions {
+		return &evaluatorOptions{
+			DisableAuth:  true,
+			TargetURL:    Must(url.Parse(srv.URL)),
+			ProjectID:    "test-project",
+			GeneratorURL: Must(url.Parse(generatorURL)),
+		}
+	}
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		opts("https://grafana.example.com"),
+		version.Version,
+		testAppendable{app: &testAppender{}}, notifier.NewManager(&notifier.Options{}, log.NewNopLogger()), nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &promforkconfig.Config{
+		GlobalConfig: promforkconfig.GlobalConfig{EvaluationInterval: model.Duration(time.Minute)},
+		RuleFiles:    []string{ruleFile},
+	}
+
+	for _, tc := range []struct {
+		generatorURL string
+		want         string
+	}{
+		{generatorURL: "https://grafana.example.com", want: "https://grafana.example.com/alerts"},
+		{generatorURL: "https://other.example.com/prometheus", want: "https://other.example.com/prometheus/alerts"},
+	} {
+		if err := re.ApplyConfig(cfg, opts(tc.generatorURL), nil); err != nil {
+			t.Fatal(err)
+		}
+		g := re.rulesManager.RuleGroups()[0]
+		g.Eval(t.Context(), time.Now())
+
+		alerts := g.Rules()[0].(*rules.AlertingRule).ActiveAlerts()
+		if len(alerts) != 1 {
+			t.Fatalf("expected 1 alert, got %d", len(alerts))
+		}
+		if got := alerts[0].Annotations.Get("link"); got != tc.want {
+			t.Errorf("expected link %q, got %q", tc.want, got)
+		}
+	}
+}
+
+func TestSetExternalURLDuringEvaluation(t *testing.T) {
+	e := &ruleEvaluator{externalURL: &url.URL{}}
+	want := Must(url.Parse("https://grafana.example.com"))
+
+	// A running evaluation doesn't block publishing the URL and keeps the URL it started with.
+	e.evalMtx.RLock()
+	e.setExternalURL(want)
+	if got := e.externalURL.String(); got != "" {
+		t.Errorf("expected URL to be pending during evaluation, got %q", got)
+	}
+	e.evalMtx.RUnlock()
+
+	e.applyExternalURL()
+	if got := e.externalURL.String(); got != want.String() {
+		t.Errorf("expected URL %q, got %q", want, got)
+	}
+}
+
+// Alerts of non-aggregated GlobalRules queries may carry the export labels of their source
+// series with the values of the rule-evaluator's own export labels.
+func TestRestoreForStateWithExportLabels(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: test
+  rules:
+  - alert: Down
+    expr: up == 0
+    for: 1h
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Unix(1700000000, 0)
+	activeAt := ts.Add(-30 * time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Form.Get("query"), "ALERTS_FOR_STATE") {
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"job":"global","project_id":"p1","location":"l1","cluster":"c1"},"value":[%[1]d,"0"]},
+				{"metric":{"job":"scoped"},"value":[%[1]d,"0"]}
+			]}}`, ts.Unix())
+			return
+		}
+		// The exporter attached the export labels to the series of the scoped alert.
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"__name__":"ALERTS_FOR_STATE","alertname":"Down","job":"global","project_id":"p1","location":"l1","cluster":"c1"},"values":[[%[1]d,"%[2]d"]]},
+			{"metric":{"__name__":"ALERTS_FOR_STATE","alertname":"Down","job":"scoped","project_id":"p1","location":"l1","cluster":"c1"},"values":[[%[1]d,"%[2]d"]]}
+		]}}`, ts.Add(-time.Minute).Unix(), activeAt.Unix())
+	}))
+	defer srv.Close()
+
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		&evaluatorOptions{
+			DisableAuth:     true,
+			TargetURL:       Must(url.Parse(srv.URL)),
+			OutageTolerance: time.Hour,
+		},
+		version.Version,
+		testAppendable{app: &testAppender{}}, notifier.NewManager(&notifier.Options{}, log.NewNopLogger()), nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re.SetExportLabels(labels.FromStrings("cluster", "c1", "location", "l1", "project_id", "p1"))
+	if err := re.ApplyConfig(&promforkconfig.Config{
+		GlobalConfig: promforkconfig.GlobalConfig{EvaluationInterval: model.Duration(time.Minute)},
+		RuleFiles:    []string{ruleFile},
+	}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g := re.rulesManager.RuleGroups()[0]
+	g.Eval(t.Context(), ts)
+	g.RestoreForState(ts)
+
+	// The alerts were pending for 29m before the last write, which is shifted by the 1m
+	// downtime.
+	want := map[string]time.Time{
+		"global": activeAt.Add(time.Minute).UTC(),
+		"scoped": activeAt.Add(time.Minute).UTC(),
+	}
+	got := map[string]time.Time{}
+	for _, a := range g.Rules()[0].(*rules.AlertingRule).ActiveAlerts() {
+		got[a.Labels.Get("job")] = a.ActiveAt
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected restored active times (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// queryOverride evaluates the rule groups it matches against another project or query target
+// than the one of the flags and the google_cloud section.
+type queryOverride struct {
+	// File is a glob matching the path of the rule file. Matches all files if empty.
+	File string `yaml:"file,omitempty"`
+	// Group is the name of the rule group. Matches all groups if empty.
+	Group string `yaml:"group,omitempty"`
+	// ProjectID overrides the project ID that is queried.
+	ProjectID string `yaml:"project_id,omitempty"`
+	// TargetURL overrides the query target URL. As in --query.target-url, the project ID
+	// placeholder is replaced with the project ID.
+	TargetURL string `yaml:"target_url,omitempty"`
+}
+
+func (o *queryOverride) validate() error {
+	if o.ProjectID == "" && o.TargetURL == "" {
+		return errors.New("project_id or target_url must be set")
+	}
+	if _, err := filepath.Match(o.File, ""); err != nil {
+		return fmt.Errorf("invalid file %q: %w", o.File, err)
+	}
+	if o.TargetURL != "" {
+		if _, err := url.Parse(strings.ReplaceAll(o.TargetURL, projectIDVar, "x")); err != nil {
+			return fmt.Errorf("invalid target_url %q: %w", o.TargetURL, err)
+		}
+	}
+	return nil
+}
+
+func (o *queryOverride) matches(file, group string) bool {
+	if o.Group != "" && o.Group != group {
+		return false
+	}
+	if o.File == "" {
+		return true
+	}
+	ok, _ := filepath.Match(o.File, file)
+	return ok
+}
+
+// options returns the query options of the override.
+func (o *queryOverride) options(opts evaluatorOptions) (*evaluatorOptions, error) {
+	if o.ProjectID != "" {
+		opts.ProjectID = o.ProjectID
+	}
+	if o.TargetURL != "" {
+		u, err := url.Parse(o.TargetURL)
+		if err != nil {
+			return nil, err
+		}
+		opts.TargetURL = u
+	}
+	return &opts, nil
+}
+
+// queryOverrides holds a query API client for every query target of the overrides. Overrides
+// with the same target share a client.
+type queryOverrides struct {
+	ctx     context.Context
+	version string
+
+	mtx       sync.RWMutex
+	overrides []queryOverride
+	apis      []v1.API
+	opts      *evaluatorOptions
+}
+
+func newQueryOverrides(ctx context.Context, version string) *queryOverrides {
+	return &queryOverrides{ctx: ctx, version: version}
+}
+
+// ApplyConfig sets the overrides. The clients are created with the given options, e.g. for
+// credentials, and only recreated if the overrides or the options change.
+func (q *queryOverrides) ApplyConfig(overrides []queryOverride, opts *evaluatorOptions) error {
+	for i := range overrides {
+		if err := overrides[i].validate(); err != nil {
+			return fmt.Errorf("query override %d: %w", i, err)
+		}
+	}
+	q.mtx.RLock()
+	unchanged := q.opts == opts && reflect.DeepEqual(q.overrides, overrides)
+	q.mtx.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	apis := make([]v1.API, 0, len(overrides))
+	byAddress := map[string]v1.API{}
+	for i := range overrides {
+		o, err := overrides[i].options(*opts)
+		if err != nil {
+			return fmt.Errorf("query override %d: %w", i, err)
+		}
+		address := strings.ReplaceAll(o.TargetURL.String(), projectIDVar, o.ProjectID)
+		api, ok := byAddress[address]
+		if !ok {
+			api, err = newAPI(q.ctx, o, q.version)
+			if err != nil {
+				return fmt.Errorf("query override %d: query client: %w", i, err)
+			}
+			byAddress[address] = api
+		}
+		apis = append(apis, api)
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.overrides, q.apis, q.opts = overrides, apis, opts
+	return nil
+}
+
+// forGroup returns the query API client of the first override matching the rule group,
+// or nil if none matches.
+func (q *queryOverrides) forGroup(file, group string) v1.API {
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	for i := range q.overrides {
+		if q.overrides[i].matches(file, group) {
+			return q.apis[i]
+		}
+	}
+	return nil
+}
+
+// projectIDForGroup returns the project ID of the first override matching the rule group,
+// or an empty string if none matches or it keeps the project ID of the options.
+func (q *queryOverrides) projectIDForGroup(file, group string) string {
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	for i := range q.overrides {
+		if q.overrides[i].matches(file, group) {
+			return q.overrides[i].ProjectID
+		}
+	}
+	return ""
+}
+
+type queryAPIKey struct{}
+
+// withQueryAPI returns a context in which the query function queries the given client.
+func withQueryAPI(ctx context.Context, api v1.API) context.Context {
+	return context.WithValue(ctx, queryAPIKey{}, api)
+}
+
+// queryAPIFromContext returns the query API client of the context, if any.
+func queryAPIFromContext(ctx context.Context) (v1.API, bool) {
+	api, ok := ctx.Value(queryAPIKey{}).(v1.API)
+	return api, ok
+}
@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	promforkconfig "github.com/prometheus/prometheus/config"
+)
+
+func TestQueryOverrideValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		override queryOverride
+		wantErr  bool
+	}{
+		{desc: "project", override: queryOverride{File: "/etc/rules/globalrules__*.yaml", ProjectID: "central"}},
+		{desc: "target", override: queryOverride{Group: "group", TargetURL: "http://localhost:9090"}},
+		{desc: "no override", override: queryOverride{Group: "group"}, wantErr: true},
+		{desc: "invalid glob", override: queryOverride{File: "[", ProjectID: "central"}, wantErr: true},
+		{desc: "invalid target", override: queryOverride{TargetURL: "http://local host"}, wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := tc.override.validate(); (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestQueryOverrides(t *testing.T) {
+	opts := &evaluatorOptions{
+		DisableAuth: true,
+		TargetURL:   Must(url.Parse("https://monitoring.googleapis.com/v1/projects/" + projectIDVar + "/location/global/prometheus")),
+		ProjectID:   "cluster",
+	}
+	q := newQueryOverrides(t.Context(), version.Version)
+	if err := q.ApplyConfig([]queryOverride{
+		{File: "/etc/rules/globalrules__*.yaml", ProjectID: "central"},
+		{Group: "central", ProjectID: "central"},
+		{Group: "other", ProjectID: "other"},
+	}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if api := q.forGroup("/etc/rules/rules__ns__name.yaml", "group"); api != nil {
+		t.Error("expected no override for namespace rules")
+	}
+	central := q.forGroup("/etc/rules/globalrules__name.yaml", "group")
+	if central == nil {
+		t.Fatal("expected override for global rules")
+	}
+	if api := q.forGroup("/etc/rules/rules__ns__name.yaml", "central"); api != central {
+		t.Error("expected overrides with the same target to share a client")
+	}
+	if api := q.forGroup("/etc/rules/rules__ns__name.yaml", "other"); api == nil || api == central {
+		t.Error("expected a separate client for another project")
+	}
+
+	// Unchanged overrides keep their clients.
+	if err := q.ApplyConfig([]queryOverride{
+		{File: "/etc/rules/globalrules__*.yaml", ProjectID: "central"},
+	}, opts); err != nil {
+		t.Fatal(err)
+	}
+	central = q.forGroup("/etc/rules/globalrules__name.yaml", "group")
+	if err := q.ApplyConfig([]queryOverride{
+		{File: "/etc/rules/globalrules__*.yaml", ProjectID: "central"},
+	}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if api := q.forGroup("/etc/rules/globalrules__name.yaml", "group"); api != central {
+		t.Error("expected client to be kept")
+	}
+
+	if err := q.ApplyConfig([]queryOverride{{Group: "group"}}, opts); err == nil {
+		t.Error("expected error for invalid override")
+	}
+}
+
+func TestQueryOverridesRouteGroupQueries(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: default
+  rules:
+  - record: a
+    expr: vector(1)
+- name: override
+  rules:
+  - record: b
+    expr: vector(2)
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mtx     sync.Mutex
+		queries = map[string][]string{}
+	)
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+			}
+			mtx.Lock()
+			queries[name] = append(queries[name], r.Form.Get("query"))
+			mtx.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+	}
+	defaultSrv, overrideSrv := newServer("default"), newServer("override")
+	defer defaultSrv.Close()
+	defer overrideSrv.Close()
+
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		&evaluatorOptions{
+			DisableAuth: true,
+			TargetURL:   Must(url.Parse(defaultSrv.URL)),
+		},
+		version.Version,
+		testAppendable{app: &testAppender{}}, nil, nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &promforkconfig.Config{
+		GlobalConfig: promforkconfig.GlobalConfig{EvaluationInterval: model.Duration(time.Minute)},
+		RuleFiles:    []string{ruleFile},
+	}
+	// Overrides apply to the groups of the same reload.
+	if err := re.ApplyConfig(cfg, nil, []queryOverride{{Group: "override", TargetURL: overrideSrv.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range re.rulesManager.RuleGroups() {
+		re.evalIterationFunc(t.Context(), g, time.Now())
+	}
+
+	mtx.Lock()
+	want := map[string][]string{
+		"default":  {"vector(1)"},
+		"override": {"vector(2)"},
+	}
+	if diff := cmp.Diff(want, queries); diff != "" {
+		t.Errorf("unexpected queries (-want, +got): %s", diff)
+	}
+	mtx.Unlock()
+
+	// A failed override must not load new groups that would query the default target.
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: new
+  rules:
+  - record: c
+    expr: vector(3)
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.ApplyConfig(cfg, nil, []queryOverride{{Group: "new"}}); err == nil {
+		t.Fatal("expected error for invalid override")
+	}
+	var groups []string
+	for _, g := range re.rulesManager.RuleGroups() {
+		groups = append(groups, g.Name())
+	}
+	if diff := cmp.Diff([]string{"default", "override"}, groups); diff != "" {
+		t.Errorf("unexpected groups (-want, +got): %s", diff)
+	}
+}
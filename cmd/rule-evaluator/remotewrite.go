@@ -0,0 +1,242 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	promforkconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/agent"
+)
+
+type remoteWriteOptions struct {
+	WALPath       string
+	FlushDeadline time.Duration
+}
+
+func (opts *remoteWriteOptions) setupFlags(a *kingpin.Application) {
+	a.Flag("storage.remote-write.path", "Directory of the write-ahead log that buffers rule results for the remote_write destinations of the configuration. Only created once a remote_write destination is configured.").
+		Default(opts.WALPath).
+		StringVar(&opts.WALPath)
+
+	a.Flag("storage.remote-write.flush-deadline", "How long to wait flushing rule results to remote_write destinations on shutdown or configuration reload.").
+		Default(opts.FlushDeadline.String()).
+		DurationVar(&opts.FlushDeadline)
+}
+
+func (opts *remoteWriteOptions) validate() error {
+	if opts.WALPath == "" {
+		return errors.New("--storage.remote-write.path must be set")
+	}
+	if opts.FlushDeadline < 0 {
+		return fmt.Errorf("--storage.remote-write.flush-deadline must not be negative, got %s", opts.FlushDeadline)
+	}
+	return nil
+}
+
+// remoteWriteStorage writes rule results to the remote_write destinations of the configuration.
+// As in the Prometheus agent mode, samples are written to a write-ahead log which is read by
+// one queue per destination. The write-ahead log is created on the first configuration with a
+// remote_write destination, before that no samples are written.
+type remoteWriteStorage struct {
+	logger log.Logger
+	reg    prometheus.Registerer
+	opts   remoteWriteOptions
+
+	mtx    sync.RWMutex
+	remote *remote.Storage
+	db     *agent.DB
+}
+
+func newRemoteWriteStorage(logger log.Logger, reg prometheus.Registerer, opts remoteWriteOptions) *remoteWriteStorage {
+	return &remoteWriteStorage{
+		logger: logger,
+		reg:    reg,
+		opts:   opts,
+	}
+}
+
+// ApplyConfig applies the remote_write section of the configuration.
+func (s *remoteWriteStorage) ApplyConfig(cfg *promforkconfig.Config) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.db == nil {
+		if len(cfg.RemoteWriteConfigs) == 0 {
+			return nil
+		}
+		rs := remote.NewStorage(log.With(s.logger, "component", "remote"), s.reg, func() (int64, error) { return 0, nil }, s.opts.WALPath, s.opts.FlushDeadline, nil)
+		db, err := agent.Open(log.With(s.logger, "component", "wal"), s.reg, rs, s.opts.WALPath, agent.DefaultOptions())
+		if err != nil {
+			return fmt.Errorf("open write-ahead log: %w", err)
+		}
+		s.remote, s.db = rs, db
+	}
+	return s.remote.ApplyConfig(cfg)
+}
+
+// enabled returns whether any remote_write destination was configured.
+func (s *remoteWriteStorage) enabled() bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.db != nil
+}
+
+// Appender returns an appender to the write-ahead log. Samples are discarded if no
+// remote_write destination was configured.
+func (s *remoteWriteStorage) Appender(ctx context.Context) storage.Appender {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.db == nil {
+		return discardAppender{}
+	}
+	return s.db.Appender(ctx)
+}
+
+// Close flushes the queues and closes the write-ahead log.
+func (s *remoteWriteStorage) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	// Closing the database closes the remote storage.
+	return s.db.Close()
+}
+
+// fanoutDestination is a named destination of rule results.
+type fanoutDestination struct {
+	name string
+	storage.Appendable
+}
+
+// fanoutAppendable writes samples to all destinations.
+type fanoutAppendable struct {
+	destinations []fanoutDestination
+
+	samplesAppended *prometheus.CounterVec
+	appendFailures  *prometheus.CounterVec
+}
+
+func newFanoutAppendable(reg prometheus.Registerer, destinations ...fanoutDestination) *fanoutAppendable {
+	a := &fanoutAppendable{
+		destinations: destinations,
+		samplesAppended: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rule_evaluator_destination_samples_appended_total",
+			Help: "Number of rule result samples appended to each destination.",
+		}, []string{"destination"}),
+		appendFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rule_evaluator_destination_append_failures_total",
+			Help: "Number of rule result samples or commits that failed for each destination.",
+		}, []string{"destination"}),
+	}
+	if reg != nil {
+		reg.MustRegister(a.samplesAppended, a.appendFailures)
+	}
+	return a
+}
+
+// Appender returns an appender writing to all enabled destinations.
+func (a *fanoutAppendable) Appender(ctx context.Context) storage.Appender {
+	app := &fanoutAppender{fanout: a}
+	for _, d := range a.destinations {
+		if e, ok := d.Appendable.(interface{ enabled() bool }); ok && !e.enabled() {
+			continue
+		}
+		app.names = append(app.names, d.name)
+		app.appenders = append(app.appenders, d.Appender(ctx))
+	}
+	return app
+}
+
+// fanoutAppender appends samples to the appenders of all destinations. Series references of the
+// destinations differ, so none are passed on. A failing destination doesn't prevent the samples
+// from being written to the other destinations.
+type fanoutAppender struct {
+	fanout    *fanoutAppendable
+	names     []string
+	appenders []storage.Appender
+}
+
+func (a *fanoutAppender) each(f func(storage.Appender) error, countSample bool) error {
+	var errs []error
+	for i, app := range a.appenders {
+		if err := f(app); err != nil {
+			a.fanout.appendFailures.WithLabelValues(a.names[i]).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", a.names[i], err))
+			continue
+		}
+		if countSample {
+			a.fanout.samplesAppended.WithLabelValues(a.names[i]).Inc()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *fanoutAppender) Append(_ storage.SeriesRef, lset labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return 0, a.each(func(app storage.Appender) error {
+		_, err := app.Append(0, lset, t, v)
+		return err
+	}, true)
+}
+
+func (a *fanoutAppender) AppendHistogram(_ storage.SeriesRef, lset labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return 0, a.each(func(app storage.Appender) error {
+		_, err := app.AppendHistogram(0, lset, t, h, fh)
+		return err
+	}, true)
+}
+
+func (a *fanoutAppender) AppendExemplar(_ storage.SeriesRef, lset labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, a.each(func(app storage.Appender) error {
+		_, err := app.AppendExemplar(0, lset, e)
+		return err
+	}, false)
+}
+
+func (a *fanoutAppender) UpdateMetadata(_ storage.SeriesRef, lset labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	return 0, a.each(func(app storage.Appender) error {
+		_, err := app.UpdateMetadata(0, lset, m)
+		return err
+	}, false)
+}
+
+func (a *fanoutAppender) AppendCTZeroSample(_ storage.SeriesRef, lset labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	return 0, a.each(func(app storage.Appender) error {
+		_, err := app.AppendCTZeroSample(0, lset, t, ct)
+		return err
+	}, false)
+}
+
+func (a *fanoutAppender) Commit() error {
+	return a.each(storage.Appender.Commit, false)
+}
+
+func (a *fanoutAppender) Rollback() error {
+	return a.each(storage.Appender.Rollback, false)
+}
@@ -0,0 +1,212 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+)
+
+type failingAppendable struct{}
+
+func (failingAppendable) Appender(context.Context) storage.Appender {
+	return failingAppender{}
+}
+
+type failingAppender struct {
+	discardAppender
+}
+
+func (failingAppender) Append(storage.SeriesRef, labels.Labels, int64, float64) (storage.SeriesRef, error) {
+	return 0, errors.New("append failed")
+}
+
+type toggledAppendable struct {
+	testAppendable
+	on bool
+}
+
+func (a toggledAppendable) enabled() bool {
+	return a.on
+}
+
+func TestFanoutAppendable(t *testing.T) {
+	first, second, disabled := &testAppender{}, &testAppender{}, &testAppender{}
+	fanout := newFanoutAppendable(prometheus.NewRegistry(),
+		fanoutDestination{name: "first", Appendable: testAppendable{app: first}},
+		fanoutDestination{name: "second", Appendable: toggledAppendable{testAppendable: testAppendable{app: second}, on: true}},
+		fanoutDestination{name: "disabled", Appendable: toggledAppendable{testAppendable: testAppendable{app: disabled}}},
+		fanoutDestination{name: "failing", Appendable: failingAppendable{}},
+	)
+
+	app := fanout.Appender(t.Context())
+	lset := labels.FromStrings("__name__", "foo")
+	if _, err := app.Append(0, lset, 1000, 1); err == nil {
+		t.Error("expected error for failing destination")
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []testSample{{lset: lset, t: 1000, v: 1}}
+	for _, app := range []*testAppender{first, second} {
+		if diff := cmp.Diff(want, app.samples, cmp.AllowUnexported(testSample{}), cmp.Comparer(labels.Equal)); diff != "" {
+			t.Errorf("unexpected samples (-want, +got): %s", diff)
+		}
+	}
+	if len(disabled.samples) > 0 {
+		t.Errorf("expected no samples for disabled destination, got %v", disabled.samples)
+	}
+	for _, tc := range []struct {
+		destination           string
+		wantSamples, wantFail float64
+	}{
+		{destination: "first", wantSamples: 1},
+		{destination: "second", wantSamples: 1},
+		{destination: "disabled"},
+		{destination: "failing", wantFail: 1},
+	} {
+		if got := testutil.ToFloat64(fanout.samplesAppended.WithLabelValues(tc.destination)); got != tc.wantSamples {
+			t.Errorf("%s: expected %v appended samples, got %v", tc.destination, tc.wantSamples, got)
+		}
+		if got := testutil.ToFloat64(fanout.appendFailures.WithLabelValues(tc.destination)); got != tc.wantFail {
+			t.Errorf("%s: expected %v failures, got %v", tc.destination, tc.wantFail, got)
+		}
+	}
+}
+
+// metadataAppender records the exemplars, metadata and created timestamps appended to it.
+type metadataAppender struct {
+	testAppender
+	calls []string
+}
+
+func (a *metadataAppender) AppendExemplar(storage.SeriesRef, labels.Labels, exemplar.Exemplar) (storage.SeriesRef, error) {
+	a.calls = append(a.calls, "exemplar")
+	return 0, nil
+}
+
+func (a *metadataAppender) UpdateMetadata(storage.SeriesRef, labels.Labels, metadata.Metadata) (storage.SeriesRef, error) {
+	a.calls = append(a.calls, "metadata")
+	return 0, nil
+}
+
+func (a *metadataAppender) AppendCTZeroSample(storage.SeriesRef, labels.Labels, int64, int64) (storage.SeriesRef, error) {
+	a.calls = append(a.calls, "ct")
+	return 0, nil
+}
+
+type metadataAppendable struct {
+	app *metadataAppender
+}
+
+func (a metadataAppendable) Appender(context.Context) storage.Appender {
+	return a.app
+}
+
+func TestFanoutAppenderMetadata(t *testing.T) {
+	first, second := &metadataAppender{}, &metadataAppender{}
+	fanout := newFanoutAppendable(prometheus.NewRegistry(),
+		fanoutDestination{name: "first", Appendable: metadataAppendable{app: first}},
+		fanoutDestination{name: "second", Appendable: metadataAppendable{app: second}},
+	)
+
+	app := fanout.Appender(t.Context())
+	lset := labels.FromStrings("__name__", "foo")
+	if _, err := app.AppendExemplar(0, lset, exemplar.Exemplar{Value: 1, Ts: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.UpdateMetadata(0, lset, metadata.Metadata{Type: model.MetricTypeGauge}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.AppendCTZeroSample(0, lset, 1000, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"exemplar", "metadata", "ct"}
+	for _, app := range []*metadataAppender{first, second} {
+		if diff := cmp.Diff(want, app.calls); diff != "" {
+			t.Errorf("unexpected calls (-want, +got): %s", diff)
+		}
+	}
+	if got := testutil.ToFloat64(fanout.samplesAppended.WithLabelValues("first")); got != 0 {
+		t.Errorf("expected no appended samples, got %v", got)
+	}
+}
+
+func TestRemoteWriteStorage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "wal")
+	s := newRemoteWriteStorage(log.NewNopLogger(), prometheus.NewRegistry(), remoteWriteOptions{WALPath: dir, FlushDeadline: time.Second})
+
+	// Without remote_write destinations, no write-ahead log is created.
+	cfg, err := loadConfig([]byte(`
+global:
+  evaluation_interval: 1m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyConfig(&cfg.Config); err != nil {
+		t.Fatal(err)
+	}
+	if s.enabled() {
+		t.Error("expected remote write to be disabled")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected no write-ahead log, got %v", err)
+	}
+
+	cfg, err = loadConfig([]byte(`
+remote_write:
+- url: http://localhost:9090/api/v1/write
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyConfig(&cfg.Config); err != nil {
+		t.Fatal(err)
+	}
+	if !s.enabled() {
+		t.Fatal("expected remote write to be enabled")
+	}
+	app := s.Appender(t.Context())
+	if _, err := app.Append(0, labels.FromStrings("__name__", "foo"), time.Now().UnixMilli(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wal")); err != nil {
+		t.Errorf("expected write-ahead log: %s", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Classes of query errors, used as the "error_class" label of query request metrics.
+const (
+	queryErrorClassNone        = "none"
+	queryErrorClassQuota       = "quota"
+	queryErrorClassAuth        = "auth"
+	queryErrorClassBadQuery    = "bad_query"
+	queryErrorClassUnavailable = "unavailable"
+	queryErrorClassCanceled    = "canceled"
+	queryErrorClassOther       = "other"
+)
+
+// queryRetryOptions configures retries of failed rule queries.
+type queryRetryOptions struct {
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// backoff returns the jittered delay before the given retry, starting at 0.
+func (opts queryRetryOptions) backoff(retry int) time.Duration {
+	d := opts.MaxBackoff
+	if retry < 32 {
+		d = min(opts.MinBackoff<<retry, opts.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter keeps a lower bound on the delay while spreading out retries of
+	// groups that failed at the same time.
+	return d/2 + rand.N(d/2+1)
+}
+
+// queryError is a query failure together with its error class.
+type queryError struct {
+	class string
+	err   error
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.class, e.err)
+}
+
+func (e *queryError) Unwrap() error {
+	return e.err
+}
+
+// retryable returns whether the query may succeed when attempted again.
+func (e *queryError) retryable() bool {
+	return e.class == queryErrorClassQuota || e.class == queryErrorClassUnavailable
+}
+
+// queryErrorClass returns the error class of an error returned by the query functions.
+func queryErrorClass(err error) string {
+	var qerr *queryError
+	if errors.As(err, &qerr) {
+		return qerr.class
+	}
+	return classifyQueryError(0, err)
+}
+
+// classifyQueryError classifies the outcome of a query request by its HTTP status code,
+// if a response was received, and the returned error.
+func classifyQueryError(code int, err error) string {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return queryErrorClassQuota
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return queryErrorClassAuth
+	case code == http.StatusBadRequest || code == http.StatusUnprocessableEntity:
+		return queryErrorClassBadQuery
+	case code == http.StatusRequestTimeout || code/100 == 5:
+		return queryErrorClassUnavailable
+	case code != 0 && code/100 != 2:
+		return queryErrorClassOther
+	}
+	if err == nil {
+		return queryErrorClassNone
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return queryErrorClassCanceled
+	}
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case v1.ErrBadData, v1.ErrExec:
+			return queryErrorClassBadQuery
+		case v1.ErrTimeout, v1.ErrServer:
+			return queryErrorClassUnavailable
+		case v1.ErrCanceled:
+			return queryErrorClassCanceled
+		}
+		return queryErrorClassOther
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return queryErrorClassUnavailable
+	}
+	return queryErrorClassOther
+}
+
+type queryStatusKey struct{}
+
+// queryStatus records the HTTP status code of the last request made with a context.
+type queryStatus struct {
+	code int
+}
+
+// withQueryStatus returns a context that records the status code of query requests
+// in the returned queryStatus.
+func withQueryStatus(ctx context.Context) (context.Context, *queryStatus) {
+	s := &queryStatus{}
+	return context.WithValue(ctx, queryStatusKey{}, s), s
+}
+
+// classifyingRoundTripper counts query requests by status code, method and error class
+// and records the status code for the caller.
+type classifyingRoundTripper struct {
+	next    http.RoundTripper
+	counter *prometheus.CounterVec
+}
+
+func (rt classifyingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+
+	var code int
+	if resp != nil {
+		code = resp.StatusCode
+	}
+	if s, ok := req.Context().Value(queryStatusKey{}).(*queryStatus); ok {
+		s.code = code
+	}
+	codeLabel := ""
+	if code != 0 {
+		codeLabel = strconv.Itoa(code)
+	}
+	rt.counter.WithLabelValues(codeLabel, strings.ToLower(req.Method), classifyQueryError(code, err)).Inc()
+
+	return resp, err
+}
+
+// retryQuery calls query until it succeeds, fails with a non-retryable error, or the
+// retries are exhausted. Retries are not attempted past the deadline of the context.
+func retryQuery[T any](ctx context.Context, opts queryRetryOptions, onRetry func(err error, backoff time.Duration), query func(ctx context.Context) (T, error)) (T, error) {
+	for retry := 0; ; retry++ {
+		attemptCtx, status := withQueryStatus(ctx)
+		res, err := query(attemptCtx)
+		if err == nil {
+			return res, nil
+		}
+		qerr := &queryError{class: classifyQueryError(status.code, err), err: err}
+		if !qerr.retryable() || retry >= opts.MaxRetries {
+			return res, qerr
+		}
+		backoff := opts.backoff(retry)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			return res, qerr
+		}
+		if onRetry != nil {
+			onRetry(qerr, backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return res, qerr
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClassifyQueryError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		code int
+		err  error
+		want string
+	}{
+		{name: "success", code: 200, want: queryErrorClassNone},
+		{name: "quota", code: 429, err: &v1.Error{Type: v1.ErrClient}, want: queryErrorClassQuota},
+		{name: "unauthenticated", code: 401, want: queryErrorClassAuth},
+		{name: "permission denied", code: 403, want: queryErrorClassAuth},
+		{name: "bad query", code: 400, err: &v1.Error{Type: v1.ErrBadData}, want: queryErrorClassBadQuery},
+		{name: "unavailable", code: 503, want: queryErrorClassUnavailable},
+		{name: "not found", code: 404, want: queryErrorClassOther},
+		{name: "execution error", err: &v1.Error{Type: v1.ErrExec}, want: queryErrorClassBadQuery},
+		{name: "api timeout", err: &v1.Error{Type: v1.ErrTimeout}, want: queryErrorClassUnavailable},
+		{name: "canceled", err: context.Canceled, want: queryErrorClassCanceled},
+		{name: "deadline", err: context.DeadlineExceeded, want: queryErrorClassCanceled},
+		{name: "network", err: &timeoutError{}, want: queryErrorClassUnavailable},
+		{name: "unknown", err: errors.New("foo"), want: queryErrorClassOther},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyQueryError(tc.code, tc.err); got != tc.want {
+				t.Errorf("expected class %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestQueryRetryOptionsBackoff(t *testing.T) {
+	opts := queryRetryOptions{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 10 {
+			if got := opts.backoff(retry); got < want/2 || got > want {
+				t.Errorf("retry %d: expected backoff in [%s, %s], got %s", retry, want/2, want, got)
+			}
+		}
+	}
+	if got := opts.backoff(100); got > time.Second {
+		t.Errorf("expected backoff to be capped, got %s", got)
+	}
+}
+
+func TestRetryQuery(t *testing.T) {
+	opts := queryRetryOptions{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	// attempt returns a query function that fails with the given status codes before succeeding.
+	attempt := func(calls *int, codes ...int) func(ctx context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			i := *calls
+			*calls++
+			if i < len(codes) {
+				ctx.Value(queryStatusKey{}).(*queryStatus).code = codes[i]
+				return 0, &v1.Error{Type: v1.ErrServer}
+			}
+			return 1, nil
+		}
+	}
+
+	t.Run("transient failures", func(t *testing.T) {
+		var calls int
+		res, err := retryQuery(t.Context(), opts, nil, attempt(&calls, 503, 429))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != 1 || calls != 3 {
+			t.Errorf("expected result after 3 calls, got %d after %d calls", res, calls)
+		}
+	})
+	t.Run("retries exhausted", func(t *testing.T) {
+		var calls int
+		_, err := retryQuery(t.Context(), opts, nil, attempt(&calls, 503, 503, 429))
+		if got := queryErrorClass(err); got != queryErrorClassQuota {
+			t.Errorf("expected class %q, got %q", queryErrorClassQuota, got)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+	})
+	t.Run("permanent failure", func(t *testing.T) {
+		var calls int
+		_, err := retryQuery(t.Context(), opts, nil, attempt(&calls, 403))
+		if got := queryErrorClass(err); got != queryErrorClassAuth {
+			t.Errorf("expected class %q, got %q", queryErrorClassAuth, got)
+		}
+		var apiErr *v1.Error
+		if !errors.As(err, &apiErr) {
+			t.Errorf("expected wrapped API error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+		defer cancel()
+		var calls int
+		_, err := retryQuery(ctx, queryRetryOptions{MaxRetries: 2, MinBackoff: time.Hour, MaxBackoff: time.Hour}, nil, attempt(&calls, 503))
+		if got := queryErrorClass(err); got != queryErrorClassUnavailable {
+			t.Errorf("expected class %q, got %q", queryErrorClassUnavailable, got)
+		}
+		if calls != 1 {
+			t.Errorf("expected no retry past the deadline, got %d calls", calls)
+		}
+	})
+}
+
+func TestQueryFuncRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"foo"},"value":[1,"2"]}]}}`))
+	}))
+	defer srv.Close()
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"code", "method", "error_class"})
+	client, err := api.NewClient(api.Config{
+		Address:      srv.URL,
+		RoundTripper: classifyingRoundTripper{next: http.DefaultTransport, counter: counter},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retryOpts := func() queryRetryOptions {
+		return queryRetryOptions{MaxRetries: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	}
+	queryFunc := newQueryFunc(log.NewNopLogger(), newQueryClient(v1.NewAPI(client)), retryOpts)
+
+	v, err := queryFunc(t.Context(), "foo", time.Unix(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 || v[0].F != 2 {
+		t.Errorf("unexpected result %v", v)
+	}
+	if got := testutil.ToFloat64(counter.WithLabelValues("503", "post", queryErrorClassUnavailable)); got != 1 {
+		t.Errorf("expected 1 unavailable request, got %v", got)
+	}
+	if got := testutil.ToFloat64(counter.WithLabelValues("200", "post", queryErrorClassNone)); got != 1 {
+		t.Errorf("expected 1 successful request, got %v", got)
+	}
+}
@@ -0,0 +1,525 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/promql/promqltest"
+	"github.com/prometheus/prometheus/rules"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// ruleTestOptions configures the test-rules command, which runs unit tests of Rules,
+// ClusterRules and GlobalRules resources. The rules are scoped the same way the operator
+// scopes them before they are evaluated.
+type ruleTestOptions struct {
+	TestFiles []string
+	Run       string
+}
+
+func (opts *ruleTestOptions) setupFlags(cmd *kingpin.CmdClause) {
+	cmd.Arg("test-file", "Unit test files in the promtool format. Rule files must contain Rules, ClusterRules or GlobalRules resources.").
+		Required().
+		StringsVar(&opts.TestFiles)
+
+	cmd.Flag("test-rules.run", "Only run test groups whose name matches the regular expression.").
+		PlaceHolder("<REGEX>").
+		StringVar(&opts.Run)
+}
+
+// runRuleTests runs the unit tests of all test files and reports the outcome to w. It
+// returns whether all tests passed.
+func runRuleTests(w io.Writer, opts *ruleTestOptions) (bool, error) {
+	var run *regexp.Regexp
+	if opts.Run != "" {
+		var err error
+		if run, err = regexp.Compile(opts.Run); err != nil {
+			return false, fmt.Errorf("invalid --test-rules.run: %w", err)
+		}
+	}
+	success := true
+	for _, f := range opts.TestFiles {
+		fmt.Fprintln(w, "Unit Testing:", f)
+		if errs := ruleUnitTest(f, run); len(errs) > 0 {
+			fmt.Fprintln(w, "  FAILED:")
+			for _, err := range errs {
+				fmt.Fprintln(w, err)
+			}
+			success = false
+		} else {
+			fmt.Fprintln(w, "  SUCCESS")
+		}
+		fmt.Fprintln(w)
+	}
+	return success, nil
+}
+
+// ruleTestFile is a unit test file. Apart from the scope, it matches the promtool format.
+type ruleTestFile struct {
+	// RuleFiles contain Rules, ClusterRules or GlobalRules resources.
+	RuleFiles []string `yaml:"rule_files"`
+	// ProjectID, Location and Cluster are the scope of Rules and ClusterRules, as set by
+	// the operator of the cluster they are deployed to.
+	ProjectID          string          `yaml:"project_id,omitempty"`
+	Location           string          `yaml:"location,omitempty"`
+	Cluster            string          `yaml:"cluster,omitempty"`
+	EvaluationInterval model.Duration  `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string        `yaml:"group_eval_order,omitempty"`
+	Tests              []ruleTestGroup `yaml:"tests"`
+}
+
+// ruleTestGroup is a group of input series and the tests run against them.
+type ruleTestGroup struct {
+	Name            string               `yaml:"name,omitempty"`
+	Interval        model.Duration       `yaml:"interval,omitempty"`
+	InputSeries     []ruleTestSeries     `yaml:"input_series"`
+	AlertRuleTests  []alertRuleTestCase  `yaml:"alert_rule_test,omitempty"`
+	PromQLExprTests []promQLExprTestCase `yaml:"promql_expr_test,omitempty"`
+	ExternalLabels  labels.Labels        `yaml:"external_labels,omitempty"`
+	ExternalURL     string               `yaml:"external_url,omitempty"`
+}
+
+type ruleTestSeries struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+type alertRuleTestCase struct {
+	EvalTime  model.Duration  `yaml:"eval_time"`
+	Alertname string          `yaml:"alertname"`
+	ExpAlerts []expectedAlert `yaml:"exp_alerts"`
+}
+
+type expectedAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+type promQLExprTestCase struct {
+	Expr       string           `yaml:"expr"`
+	EvalTime   model.Duration   `yaml:"eval_time"`
+	ExpSamples []expectedSample `yaml:"exp_samples"`
+}
+
+type expectedSample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+func ruleUnitTest(filename string, run *regexp.Regexp) []error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return []error{err}
+	}
+	var tf ruleTestFile
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&tf); err != nil {
+		return []error{err}
+	}
+	if tf.EvaluationInterval == 0 {
+		tf.EvaluationInterval = model.Duration(time.Minute)
+	}
+
+	// The scoped rules are written to temporary files for the rules manager to load.
+	dir, err := os.MkdirTemp("", "rule-evaluator-test")
+	if err != nil {
+		return []error{err}
+	}
+	defer os.RemoveAll(dir)
+	ruleFiles, err := tf.scopedRuleFiles(filepath.Dir(filename), dir)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, tg := range tf.Tests {
+		if run != nil && !run.MatchString(tg.Name) {
+			continue
+		}
+		if tg.Interval == 0 {
+			tg.Interval = tf.EvaluationInterval
+		}
+		errs = append(errs, tg.test(time.Duration(tf.EvaluationInterval), tf.GroupEvalOrder, ruleFiles)...)
+	}
+	return errs
+}
+
+// scopedRuleFiles writes the scoped rule groups of all resources in the rule files to dir
+// and returns the written files. Relative rule files are resolved against baseDir.
+func (tf *ruleTestFile) scopedRuleFiles(baseDir, dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range tf.RuleFiles {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no rule file matches %q", pattern)
+		}
+		for _, m := range matches {
+			configs, err := tf.scopedRuleGroups(m)
+			if err != nil {
+				return nil, fmt.Errorf("rule file %q: %w", m, err)
+			}
+			for _, config := range configs {
+				f := filepath.Join(dir, fmt.Sprintf("%d.yaml", len(files)))
+				if err := os.WriteFile(f, []byte(config), 0o600); err != nil {
+					return nil, err
+				}
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// scopedRuleGroups returns the rule groups configuration of every resource in the file,
+// as generated by the operator.
+func (tf *ruleTestFile) scopedRuleGroups(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var configs []string
+	dec := k8syaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return configs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		var meta metav1.TypeMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, err
+		}
+
+		var config string
+		switch meta.Kind {
+		case "Rules":
+			var r monitoringv1.Rules
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, err
+			}
+			if r.Namespace == "" {
+				return nil, fmt.Errorf("%s %s: missing namespace", meta.Kind, r.Name)
+			}
+			if err := tf.validateScope(); err != nil {
+				return nil, fmt.Errorf("%s %s/%s: %w", meta.Kind, r.Namespace, r.Name, err)
+			}
+			config, err = r.RuleGroupsConfig(tf.ProjectID, tf.Location, tf.Cluster)
+		case "ClusterRules":
+			var r monitoringv1.ClusterRules
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, err
+			}
+			if err := tf.validateScope(); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", meta.Kind, r.Name, err)
+			}
+			config, err = r.RuleGroupsConfig(tf.ProjectID, tf.Location, tf.Cluster)
+		case "GlobalRules":
+			var r monitoringv1.GlobalRules
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, err
+			}
+			config, err = r.RuleGroupsConfig()
+		default:
+			return nil, fmt.Errorf("unsupported kind %q, must be Rules, ClusterRules or GlobalRules", meta.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+}
+
+// validateScope checks that the scope of Rules and ClusterRules is set. Otherwise, rules
+// would only select series without the scope labels.
+func (tf *ruleTestFile) validateScope() error {
+	if tf.ProjectID == "" || tf.Location == "" || tf.Cluster == "" {
+		return errors.New("project_id, location and cluster of the test file must be set")
+	}
+	return nil
+}
+
+// test evaluates the rule groups over the input series and runs the test cases.
+func (tg *ruleTestGroup) test(evalInterval time.Duration, groupEvalOrder []string, ruleFiles []string) (errs []error) {
+	var input strings.Builder
+	fmt.Fprintf(&input, "load %s\n", tg.Interval)
+	for _, s := range tg.InputSeries {
+		fmt.Fprintf(&input, "  %s %s\n", s.Series, s.Values)
+	}
+	suite, err := promqltest.NewLazyLoader(input.String(), promqltest.LazyLoaderOpts{
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+	})
+	if err != nil {
+		return []error{err}
+	}
+	defer func() {
+		if err := suite.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}()
+	suite.SubqueryInterval = evalInterval
+
+	m := rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:  rules.EngineQueryFunc(suite.QueryEngine(), suite.Storage()),
+		Appendable: suite.Storage(),
+		Context:    context.Background(),
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+		Logger:     log.NewNopLogger(),
+	})
+	groupsMap, loadErrs := m.LoadGroups(time.Duration(tg.Interval), tg.ExternalLabels, tg.ExternalURL, nil, ruleFiles...)
+	if loadErrs != nil {
+		return loadErrs
+	}
+	// Groups are evaluated in the given order, remaining groups last.
+	groups := make([]*rules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	order := func(g *rules.Group) int {
+		if i := slices.Index(groupEvalOrder, g.Name()); i >= 0 {
+			return i
+		}
+		return len(groupEvalOrder)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if order(groups[i]) != order(groups[j]) {
+			return order(groups[i]) < order(groups[j])
+		}
+		return groups[i].File() < groups[j].File()
+	})
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			// Mark alerting rules as restored, so that they create the ALERTS series.
+			if ar, ok := r.(*rules.AlertingRule); ok {
+				ar.SetRestored(true)
+			}
+		}
+	}
+
+	alertTests := map[model.Duration][]alertRuleTestCase{}
+	var maxEvalTime model.Duration
+	for _, tc := range tg.AlertRuleTests {
+		if tc.Alertname == "" {
+			return []error{fmt.Errorf("%salert_rule_test at eval_time %s misses alertname", tg.prefix(), tc.EvalTime)}
+		}
+		alertTests[tc.EvalTime] = append(alertTests[tc.EvalTime], tc)
+		maxEvalTime = max(maxEvalTime, tc.EvalTime)
+	}
+	for _, tc := range tg.PromQLExprTests {
+		maxEvalTime = max(maxEvalTime, tc.EvalTime)
+	}
+	alertEvalTimes := make([]model.Duration, 0, len(alertTests))
+	for t := range alertTests {
+		alertEvalTimes = append(alertEvalTimes, t)
+	}
+	slices.Sort(alertEvalTimes)
+
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(time.Duration(maxEvalTime))
+	next := 0
+	for ts := mint; !ts.After(maxt); ts = ts.Add(evalInterval) {
+		var evalErrs []error
+		suite.WithSamplesTill(ts, func(err error) {
+			if err != nil {
+				evalErrs = append(evalErrs, err)
+				return
+			}
+			for _, g := range groups {
+				g.Eval(suite.Context(), ts)
+				for _, r := range g.Rules() {
+					if err := r.LastError(); err != nil {
+						evalErrs = append(evalErrs, fmt.Errorf("    rule: %s, expr: %q, time: %s, err: %w", r.Name(), r.Query(), ts.Sub(mint), err))
+					}
+				}
+			}
+		})
+		if len(evalErrs) > 0 {
+			return append(errs, evalErrs...)
+		}
+		// Alerts expected at eval times up to the next evaluation are compared with the
+		// alerts of this evaluation.
+		for ; next < len(alertEvalTimes) && time.Duration(alertEvalTimes[next]) < ts.Add(evalInterval).Sub(mint); next++ {
+			for _, tc := range alertTests[alertEvalTimes[next]] {
+				if err := tg.checkAlerts(groups, &tc); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	for _, tc := range tg.PromQLExprTests {
+		if err := tg.checkExpr(suite, mint, &tc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (tg *ruleTestGroup) prefix() string {
+	if tg.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf("    name: %s,\n", tg.Name)
+}
+
+// checkAlerts compares the firing alerts of the test case's alerting rules with the
+// expected alerts.
+func (tg *ruleTestGroup) checkAlerts(groups []*rules.Group, tc *alertRuleTestCase) error {
+	var got []labelsAndAnnotations
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			ar, ok := r.(*rules.AlertingRule)
+			if !ok || ar.Name() != tc.Alertname {
+				continue
+			}
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					got = append(got, labelsAndAnnotations{Labels: a.Labels.Copy(), Annotations: a.Annotations.Copy()})
+				}
+			}
+		}
+	}
+	var exp []labelsAndAnnotations
+	for _, a := range tc.ExpAlerts {
+		// The alertname label is added by the rule evaluation.
+		lset := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, tc.Alertname).Labels()
+		exp = append(exp, labelsAndAnnotations{Labels: lset, Annotations: labels.FromMap(a.ExpAnnotations)})
+	}
+	sortLabelsAndAnnotations(got)
+	sortLabelsAndAnnotations(exp)
+	if !cmp.Equal(exp, got, cmp.Comparer(labels.Equal)) {
+		return fmt.Errorf("%s    alertname: %s, time: %s,\n        exp: %v,\n        got: %v", tg.prefix(), tc.Alertname, tc.EvalTime, exp, got)
+	}
+	return nil
+}
+
+// checkExpr compares the result of the test case's query with the expected samples.
+func (tg *ruleTestGroup) checkExpr(suite *promqltest.LazyLoader, mint time.Time, tc *promQLExprTestCase) error {
+	got, err := ruleTestQuery(suite, tc.Expr, mint.Add(time.Duration(tc.EvalTime)))
+	if err != nil {
+		return fmt.Errorf("%s    expr: %q, time: %s, err: %w", tg.prefix(), tc.Expr, tc.EvalTime, err)
+	}
+	exp := make(promql.Vector, 0, len(tc.ExpSamples))
+	for _, s := range tc.ExpSamples {
+		lset, err := parser.ParseMetric(s.Labels)
+		if err != nil {
+			return fmt.Errorf("%s    expr: %q, time: %s, err: labels %q: %w", tg.prefix(), tc.Expr, tc.EvalTime, s.Labels, err)
+		}
+		exp = append(exp, promql.Sample{Metric: lset, F: s.Value})
+	}
+	sortSamples := func(v promql.Vector) {
+		sort.Slice(v, func(i, j int) bool { return labels.Compare(v[i].Metric, v[j].Metric) < 0 })
+	}
+	sortSamples(got)
+	sortSamples(exp)
+	equal := len(got) == len(exp)
+	for i := 0; equal && i < len(got); i++ {
+		equal = labels.Equal(got[i].Metric, exp[i].Metric) && got[i].F == exp[i].F && got[i].H == nil
+	}
+	if !equal {
+		return fmt.Errorf("%s    expr: %q, time: %s,\n        exp: %s\n        got: %s", tg.prefix(), tc.Expr, tc.EvalTime, samplesString(exp), samplesString(got))
+	}
+	return nil
+}
+
+func ruleTestQuery(suite *promqltest.LazyLoader, expr string, ts time.Time) (promql.Vector, error) {
+	q, err := suite.QueryEngine().NewInstantQuery(suite.Context(), suite.Queryable(), nil, expr, ts)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+	res := q.Exec(suite.Context())
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		for i := range v {
+			v[i].T = 0
+		}
+		return v, nil
+	case promql.Scalar:
+		return promql.Vector{promql.Sample{Metric: labels.EmptyLabels(), F: v.V}}, nil
+	default:
+		return nil, fmt.Errorf("result is not a vector or scalar, got %s", v.Type())
+	}
+}
+
+func samplesString(v promql.Vector) string {
+	if len(v) == 0 {
+		return "nil"
+	}
+	s := make([]string, 0, len(v))
+	for _, sample := range v {
+		if sample.H != nil {
+			s = append(s, sample.Metric.String()+" "+sample.H.String())
+			continue
+		}
+		s = append(s, fmt.Sprintf("%s %v", sample.Metric, sample.F))
+	}
+	return strings.Join(s, ", ")
+}
+
+type labelsAndAnnotations struct {
+	Labels      labels.Labels
+	Annotations labels.Labels
+}
+
+func (la labelsAndAnnotations) String() string {
+	return "Labels:" + la.Labels.String() + " Annotations:" + la.Annotations.String()
+}
+
+func sortLabelsAndAnnotations(la []labelsAndAnnotations) {
+	sort.Slice(la, func(i, j int) bool {
+		if c := labels.Compare(la[i].Labels, la[j].Labels); c != 0 {
+			return c < 0
+		}
+		return labels.Compare(la[i].Annotations, la[j].Annotations) < 0
+	})
+}
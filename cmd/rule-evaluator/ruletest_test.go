@@ -0,0 +1,179 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRulesResources = `
+apiVersion: monitoring.googleapis.com/v1
+kind: Rules
+metadata:
+  namespace: app
+  name: errors
+spec:
+  groups:
+  - name: errors
+    interval: 1m
+    rules:
+    - record: job:errors:sum
+      expr: sum by (job) (errors_total)
+    - alert: HighErrors
+      expr: sum by (job) (errors_total) > 10
+      for: 2m
+      annotations:
+        summary: "{{ $labels.job }} has {{ $value }} errors"
+---
+apiVersion: monitoring.googleapis.com/v1
+kind: GlobalRules
+metadata:
+  name: global
+spec:
+  groups:
+  - name: global
+    interval: 1m
+    rules:
+    - record: errors:sum
+      expr: sum(errors_total)
+`
+
+// The series of another namespace and of another cluster are excluded by the scope of the
+// Rules resource, but not by the GlobalRules resource.
+const testRulesInputSeries = `
+  input_series:
+  - series: errors_total{project_id="proj",location="loc",cluster="cluster",namespace="app",job="api"}
+    values: 20x5
+  - series: errors_total{project_id="proj",location="loc",cluster="cluster",namespace="other",job="api"}
+    values: 100x5
+  - series: errors_total{project_id="proj",location="loc",cluster="other",namespace="app",job="api"}
+    values: 100x5
+`
+
+func TestRunRuleTests(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		test        string
+		run         string
+		wantSuccess bool
+		wantOutput  []string
+	}{
+		{
+			desc: "scoped",
+			test: `
+project_id: proj
+location: loc
+cluster: cluster
+rule_files: [rules.yaml]
+tests:
+- interval: 1m` + testRulesInputSeries + `
+  promql_expr_test:
+  - expr: job:errors:sum
+    eval_time: 1m
+    exp_samples:
+    - labels: job:errors:sum{project_id="proj",location="loc",cluster="cluster",namespace="app",job="api"}
+      value: 20
+  - expr: errors:sum
+    eval_time: 1m
+    exp_samples:
+    - labels: errors:sum
+      value: 220
+  alert_rule_test:
+  - alertname: HighErrors
+    eval_time: 1m
+  - alertname: HighErrors
+    eval_time: 3m
+    exp_alerts:
+    - exp_labels: {project_id: proj, location: loc, cluster: cluster, namespace: app, job: api}
+      exp_annotations:
+        summary: api has 20 errors
+`,
+			wantSuccess: true,
+			wantOutput:  []string{"SUCCESS"},
+		},
+		{
+			desc: "unscoped expectation",
+			test: `
+project_id: proj
+location: loc
+cluster: cluster
+rule_files: [rules.yaml]
+tests:
+- name: unscoped
+  interval: 1m` + testRulesInputSeries + `
+  promql_expr_test:
+  - expr: job:errors:sum
+    eval_time: 1m
+    exp_samples:
+    - labels: job:errors:sum{job="api"}
+      value: 220
+`,
+			wantOutput: []string{"FAILED", "name: unscoped", `got: {__name__="job:errors:sum", cluster="cluster", job="api", location="loc", namespace="app", project_id="proj"} 20`},
+		},
+		{
+			desc: "missing scope",
+			test: `
+rule_files: [rules.yaml]
+tests: []
+`,
+			wantOutput: []string{"FAILED", "Rules app/errors: project_id, location and cluster of the test file must be set"},
+		},
+		{
+			desc: "filtered",
+			run:  "^other$",
+			test: `
+project_id: proj
+location: loc
+cluster: cluster
+rule_files: [rules.yaml]
+tests:
+- name: skipped
+  interval: 1m` + testRulesInputSeries + `
+  promql_expr_test:
+  - expr: job:errors:sum
+    eval_time: 1m
+`,
+			wantSuccess: true,
+			wantOutput:  []string{"SUCCESS"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "rules.yaml"), []byte(testRulesResources), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			testFile := filepath.Join(dir, "test.yaml")
+			if err := os.WriteFile(testFile, []byte(tc.test), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			success, err := runRuleTests(&out, &ruleTestOptions{TestFiles: []string{testFile}, Run: tc.run})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if success != tc.wantSuccess {
+				t.Errorf("expected success %v, got %v with output:\n%s", tc.wantSuccess, success, out.String())
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
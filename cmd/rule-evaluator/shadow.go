@@ -0,0 +1,133 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// shadowMode evaluates rules without side effects. While enabled, rule results are not written
+// and alerts are not sent. They are counted and logged instead, so that a rule-evaluator can
+// run next to the one in production, e.g. to test a new version or changed rules.
+type shadowMode struct {
+	logger  log.Logger
+	flag    bool
+	enabled atomic.Bool
+
+	enabledGauge     prometheus.Gauge
+	samplesDiscarded prometheus.Counter
+	alertsDiscarded  prometheus.Counter
+}
+
+func newShadowMode(logger log.Logger, reg prometheus.Registerer, flag bool) *shadowMode {
+	s := &shadowMode{
+		logger: logger,
+		flag:   flag,
+		enabledGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rule_evaluator_shadow_mode",
+			Help: "Whether the rule-evaluator runs in shadow mode, i.e. discards rule results and alerts.",
+		}),
+		samplesDiscarded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rule_evaluator_shadow_samples_discarded_total",
+			Help: "Number of rule result samples that were not written due to shadow mode.",
+		}),
+		alertsDiscarded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rule_evaluator_shadow_alerts_discarded_total",
+			Help: "Number of alerts that were not sent due to shadow mode.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(s.enabledGauge, s.samplesDiscarded, s.alertsDiscarded)
+	}
+	s.ApplyConfig(nil)
+	return s
+}
+
+// ApplyConfig enables or disables shadow mode. The configuration overrides the flag if set.
+func (s *shadowMode) ApplyConfig(enabled *bool) {
+	v := s.flag
+	if enabled != nil {
+		v = *enabled
+	}
+	if s.enabled.Swap(v) != v {
+		_ = level.Info(s.logger).Log("msg", "Changed shadow mode", "enabled", v)
+	}
+	if v {
+		s.enabledGauge.Set(1)
+	} else {
+		s.enabledGauge.Set(0)
+	}
+}
+
+func (s *shadowMode) active() bool {
+	return s.enabled.Load()
+}
+
+// discardAlerts logs and counts alerts instead of sending them.
+func (s *shadowMode) discardAlerts(expr string, alerts ...*rules.Alert) {
+	for _, a := range alerts {
+		s.alertsDiscarded.Inc()
+		_ = level.Info(s.logger).Log(
+			"msg", "Discarded alert notification in shadow mode",
+			"expr", expr,
+			"labels", a.Labels,
+			"state", a.State,
+			"firedAt", a.FiredAt,
+			"resolvedAt", a.ResolvedAt,
+		)
+	}
+}
+
+// shadowAppendable discards all samples while shadow mode is enabled.
+type shadowAppendable struct {
+	storage.Appendable
+	shadow *shadowMode
+}
+
+// Appender returns an appender discarding all samples if shadow mode is enabled.
+func (a shadowAppendable) Appender(ctx context.Context) storage.Appender {
+	if !a.shadow.active() {
+		return a.Appendable.Appender(ctx)
+	}
+	return &shadowAppender{shadow: a.shadow}
+}
+
+// shadowAppender logs and counts samples instead of writing them. Samples are logged at
+// debug level due to their volume.
+type shadowAppender struct {
+	discardAppender
+	shadow *shadowMode
+}
+
+func (a *shadowAppender) Append(_ storage.SeriesRef, lset labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.shadow.samplesDiscarded.Inc()
+	_ = level.Debug(a.shadow.logger).Log("msg", "Discarded sample in shadow mode", "series", lset, "t", t, "v", v)
+	return 0, nil
+}
+
+func (a *shadowAppender) AppendHistogram(_ storage.SeriesRef, lset labels.Labels, t int64, _ *histogram.Histogram, _ *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	a.shadow.samplesDiscarded.Inc()
+	_ = level.Debug(a.shadow.logger).Log("msg", "Discarded histogram sample in shadow mode", "series", lset, "t", t)
+	return 0, nil
+}
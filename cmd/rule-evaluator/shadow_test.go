@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/rules"
+	"k8s.io/utils/ptr"
+)
+
+func TestShadowModeApplyConfig(t *testing.T) {
+	s := newShadowMode(log.NewNopLogger(), prometheus.NewRegistry(), true)
+	if !s.active() {
+		t.Error("expected shadow mode to be enabled by flag")
+	}
+	s.ApplyConfig(ptr.To(false))
+	if s.active() {
+		t.Error("expected configuration to override flag")
+	}
+	if got := testutil.ToFloat64(s.enabledGauge); got != 0 {
+		t.Errorf("expected gauge 0, got %v", got)
+	}
+	s.ApplyConfig(nil)
+	if !s.active() {
+		t.Error("expected flag to apply without configuration")
+	}
+	if got := testutil.ToFloat64(s.enabledGauge); got != 1 {
+		t.Errorf("expected gauge 1, got %v", got)
+	}
+}
+
+func TestShadowAppendable(t *testing.T) {
+	s := newShadowMode(log.NewNopLogger(), prometheus.NewRegistry(), false)
+	app := &testAppender{}
+	appendable := shadowAppendable{Appendable: testAppendable{app: app}, shadow: s}
+
+	appendSample := func() {
+		a := appendable.Appender(t.Context())
+		if _, err := a.Append(0, labels.FromStrings("__name__", "foo"), 1000, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendSample()
+	if len(app.samples) != 1 {
+		t.Fatalf("expected sample to be appended, got %d samples", len(app.samples))
+	}
+
+	s.ApplyConfig(ptr.To(true))
+	appendSample()
+	if len(app.samples) != 1 {
+		t.Fatalf("expected sample to be discarded, got %d samples", len(app.samples))
+	}
+	if got := testutil.ToFloat64(s.samplesDiscarded); got != 1 {
+		t.Errorf("expected 1 discarded sample, got %v", got)
+	}
+
+	// Exemplars, metadata and created timestamps are discarded without being counted.
+	a := appendable.Appender(t.Context())
+	lset := labels.FromStrings("__name__", "foo")
+	if _, err := a.AppendExemplar(0, lset, exemplar.Exemplar{Value: 1, Ts: 1000}); err != nil {
+		t.Error(err)
+	}
+	if _, err := a.UpdateMetadata(0, lset, metadata.Metadata{}); err != nil {
+		t.Error(err)
+	}
+	if _, err := a.AppendCTZeroSample(0, lset, 1000, 500); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.ToFloat64(s.samplesDiscarded); got != 1 {
+		t.Errorf("expected 1 discarded sample, got %v", got)
+	}
+}
+
+func TestShadowModeSendAlerts(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	opts := testHAOptions("a")
+	opts.Mode = haModeNone
+	s := newShadowMode(log.NewNopLogger(), prometheus.NewRegistry(), true)
+	// Without a notifier manager, sending alerts would panic.
+	e := &ruleEvaluator{
+		ha:                newHACoordinator(ctx, log.NewNopLogger(), nil, opts),
+		shadow:            s,
+		lastEvaluatorOpts: &evaluatorOptions{},
+	}
+	e.sendAlerts(t.Context(), "up == 0",
+		&rules.Alert{State: rules.StateFiring, Labels: labels.FromStrings("alertname", "a")},
+		&rules.Alert{State: rules.StateFiring, Labels: labels.FromStrings("alertname", "b")},
+	)
+	if got := testutil.ToFloat64(s.alertsDiscarded); got != 2 {
+		t.Errorf("expected 2 discarded alerts, got %v", got)
+	}
+}
+
+func TestLoadConfigShadow(t *testing.T) {
+	cfg, err := loadConfig([]byte(`
+rule_evaluator:
+  shadow: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RuleEvaluator.Shadow == nil || !*cfg.RuleEvaluator.Shadow {
+		t.Errorf("expected shadow mode to be enabled, got %v", cfg.RuleEvaluator.Shadow)
+	}
+
+	cfg, err = loadConfig([]byte(`
+global:
+  evaluation_interval: 1m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RuleEvaluator.Shadow != nil {
+		t.Errorf("expected shadow mode to be unset, got %v", *cfg.RuleEvaluator.Shadow)
+	}
+}
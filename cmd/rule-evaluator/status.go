@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	apiv1 "github.com/prometheus/prometheus/web/api/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// writeStatusResponse writes a successful response in the Prometheus API format.
+func writeStatusResponse(logger log.Logger, w http.ResponseWriter, data any) {
+	b, err := json.Marshal(response{Status: "success", Data: data})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal status: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(b); err != nil {
+		_ = level.Error(logger).Log("msg", "Unable to write status", "err", err)
+	}
+}
+
+// String returns the configuration as YAML. Secrets are redacted.
+func (c *config) String() string {
+	var b strings.Builder
+	b.WriteString(c.Config.String())
+	if reflect.ValueOf(c.RuleEvaluator).IsZero() {
+		return b.String()
+	}
+	// Match the indentation of the Prometheus configuration.
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(struct {
+		RuleEvaluator ruleEvaluatorConfig `yaml:"rule_evaluator"`
+	}{RuleEvaluator: c.RuleEvaluator}); err != nil {
+		return fmt.Sprintf("<error creating config string: %s>", err)
+	}
+	return b.String()
+}
+
+// configStatus holds the last applied configuration.
+type configStatus struct {
+	mtx  sync.RWMutex
+	yaml string
+}
+
+func (s *configStatus) ApplyConfig(cfg *config) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.yaml = cfg.String()
+}
+
+// https://prometheus.io/docs/prometheus/latest/querying/api/#config
+func (s *configStatus) handlerFunc(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		s.mtx.RLock()
+		defer s.mtx.RUnlock()
+		writeStatusResponse(logger, w, struct {
+			YAML string `json:"yaml"`
+		}{YAML: s.yaml})
+	}
+}
+
+// https://prometheus.io/docs/prometheus/latest/querying/api/#flags
+func flagsHandlerFunc(logger log.Logger, a *kingpin.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		flags := map[string]string{}
+		for _, f := range a.Model().Flags {
+			flags[f.Name] = f.Value.String()
+		}
+		writeStatusResponse(logger, w, flags)
+	}
+}
+
+// alertmanagerRetriever provides the Alertmanagers alerts are sent to.
+type alertmanagerRetriever interface {
+	Alertmanagers() []*url.URL
+	DroppedAlertmanagers() []*url.URL
+}
+
+// https://prometheus.io/docs/prometheus/latest/querying/api/#alertmanagers
+// Alertmanagers are active if discovered through the alerting configuration and dropped if
+// discarded by its relabeling rules.
+func alertmanagersHandlerFunc(logger log.Logger, r alertmanagerRetriever) http.HandlerFunc {
+	toTargets := func(urls []*url.URL) []*apiv1.AlertmanagerTarget {
+		targets := make([]*apiv1.AlertmanagerTarget, 0, len(urls))
+		for _, u := range urls {
+			targets = append(targets, &apiv1.AlertmanagerTarget{URL: u.String()})
+		}
+		return targets
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeStatusResponse(logger, w, &apiv1.AlertmanagerDiscovery{
+			ActiveAlertmanagers:  toTargets(r.Alertmanagers()),
+			DroppedAlertmanagers: toTargets(r.DroppedAlertmanagers()),
+		})
+	}
+}
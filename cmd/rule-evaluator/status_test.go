@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	apiv1 "github.com/prometheus/prometheus/web/api/v1"
+)
+
+func TestConfigStatus(t *testing.T) {
+	cfg, err := loadConfig([]byte(`
+alerting:
+  alertmanagers:
+  - basic_auth:
+      username: user
+      password: hunter2
+    static_configs:
+    - targets: [alertmanager:9093]
+remote_write:
+- url: http://localhost:9090/api/v1/write
+  authorization:
+    credentials: hunter3
+rule_evaluator:
+  high_availability:
+    mode: shard
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s configStatus
+	s.ApplyConfig(cfg)
+
+	w := httptest.NewRecorder()
+	s.handlerFunc(log.NewNopLogger())(w, httptest.NewRequest(http.MethodGet, "/api/v1/status/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", w.Code)
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Data   struct {
+			YAML string `json:"yaml"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "hunter3"} {
+		if strings.Contains(resp.Data.YAML, secret) {
+			t.Errorf("expected secret %q to be redacted, got:\n%s", secret, resp.Data.YAML)
+		}
+	}
+	for _, want := range []string{"password: <secret>", "credentials: <secret>", "alertmanager:9093", "rule_evaluator:\n  high_availability:\n    mode: shard\n"} {
+		if !strings.Contains(resp.Data.YAML, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, resp.Data.YAML)
+		}
+	}
+
+	// The configuration must be loadable again.
+	if _, err := loadConfig([]byte(resp.Data.YAML)); err != nil {
+		t.Errorf("load config: %s", err)
+	}
+}
+
+func TestFlagsHandler(t *testing.T) {
+	a := kingpin.New("test", "")
+	a.Flag("foo", "").Default("bar").String()
+	a.Flag("enabled", "").Bool()
+	if _, err := a.Parse([]string{"--enabled"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	flagsHandlerFunc(log.NewNopLogger(), a)(w, httptest.NewRequest(http.MethodGet, "/api/v1/status/flags", nil))
+	var resp struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"foo": "bar", "enabled": "true"} {
+		if got := resp.Data[name]; got != want {
+			t.Errorf("expected flag %q to be %q, got %q", name, want, got)
+		}
+	}
+}
+
+type testAlertmanagers struct {
+	active, dropped []*url.URL
+}
+
+func (a testAlertmanagers) Alertmanagers() []*url.URL        { return a.active }
+func (a testAlertmanagers) DroppedAlertmanagers() []*url.URL { return a.dropped }
+
+func TestAlertmanagersHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	alertmanagersHandlerFunc(log.NewNopLogger(), testAlertmanagers{
+		active: []*url.URL{Must(url.Parse("http://alertmanager-0:9093/api/v2/alerts"))},
+	})(w, httptest.NewRequest(http.MethodGet, "/api/v1/alertmanagers", nil))
+
+	var resp struct {
+		Data apiv1.AlertmanagerDiscovery `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := apiv1.AlertmanagerDiscovery{
+		ActiveAlertmanagers:  []*apiv1.AlertmanagerTarget{{URL: "http://alertmanager-0:9093/api/v2/alerts"}},
+		DroppedAlertmanagers: []*apiv1.AlertmanagerTarget{},
+	}
+	if diff := cmp.Diff(want, resp.Data); diff != "" {
+		t.Errorf("unexpected alertmanagers (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/prometheus/rules"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The tracer provider is installed by the Prometheus tracing manager from the tracing
+// section of the configuration. Tracers are obtained for every span, so that spans go to
+// the provider of the last applied configuration.
+const tracerName = "github.com/GoogleCloudPlatform/prometheus-engine/cmd/rule-evaluator"
+
+// startGroupSpan starts the span of an evaluation iteration of the rule group. The rules
+// manager adds a child span for every rule evaluation.
+func startGroupSpan(ctx context.Context, g *rules.Group, evalTimestamp time.Time) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "rule_group", trace.WithAttributes(
+		attribute.String("file", g.File()),
+		attribute.String("name", g.Name()),
+		attribute.String("evaluation_timestamp", evalTimestamp.Format(time.RFC3339)),
+	))
+}
+
+// endGroupSpan ends the span of an evaluation iteration with the outcome of the group's
+// rules that were evaluated since start. Rules are skipped if evaluated by another replica.
+func endGroupSpan(sp trace.Span, g *rules.Group, start time.Time) {
+	var evaluated, failed int
+	for _, r := range g.Rules() {
+		if r.GetEvaluationTimestamp().Before(start) {
+			continue
+		}
+		evaluated++
+		if r.LastError() != nil {
+			failed++
+		}
+	}
+	sp.SetAttributes(
+		attribute.Int("rules_evaluated", evaluated),
+		attribute.Int("rules_failed", failed),
+	)
+	if failed > 0 {
+		sp.SetStatus(codes.Error, "rule evaluation failed")
+	}
+	sp.End()
+}
+
+// traceQuery adds the query and its result to the span of the rule evaluation.
+func traceQuery(ctx context.Context, q string, samples int, err error) {
+	sp := trace.SpanFromContext(ctx)
+	sp.SetAttributes(attribute.String("expr", q))
+	if err != nil {
+		sp.RecordError(err)
+		sp.SetStatus(codes.Error, err.Error())
+		return
+	}
+	sp.SetAttributes(attribute.Int("samples", samples))
+}
+
+// tracingRoundTripper adds a span for every query request and propagates the trace context.
+type tracingRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, sp := otel.Tracer(tracerName).Start(req.Context(), "query "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.Redacted()),
+		),
+	)
+	defer sp.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		sp.RecordError(err)
+		sp.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	sp.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		sp.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
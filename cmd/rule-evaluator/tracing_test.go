@@ -0,0 +1,156 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	promforkconfig "github.com/prometheus/prometheus/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+- name: test
+  rules:
+  - record: ok
+    expr: vector(1)
+  - record: failing
+    expr: fail
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var traceparents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("query") == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"invalid query"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]}]}}`))
+	}))
+	defer srv.Close()
+
+	re, err := newRuleEvaluator(
+		t.Context(), log.NewNopLogger(),
+		&evaluatorOptions{DisableAuth: true, TargetURL: Must(url.Parse(srv.URL))},
+		version.Version,
+		testAppendable{app: &testAppender{}}, nil, nil,
+		newHACoordinator(t.Context(), log.NewNopLogger(), nil, haOptions{}),
+		newShadowMode(log.NewNopLogger(), nil, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := re.ApplyConfig(&promforkconfig.Config{
+		GlobalConfig: promforkconfig.GlobalConfig{EvaluationInterval: model.Duration(time.Minute)},
+		RuleFiles:    []string{ruleFile},
+	}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	g := re.rulesManager.RuleGroups()[0]
+	re.evalIterationFunc(t.Context(), g, time.Now())
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, sp := range recorder.Ended() {
+		spans[sp.Name()] = append(spans[sp.Name()], sp)
+	}
+	attrs := func(sp sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, kv := range sp.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	if len(spans["rule_group"]) != 1 {
+		t.Fatalf("expected 1 group span, got %d", len(spans["rule_group"]))
+	}
+	groupSpan := spans["rule_group"][0]
+	if a := attrs(groupSpan); a["name"].AsString() != "test" || a["rules_evaluated"].AsInt64() != 2 || a["rules_failed"].AsInt64() != 1 {
+		t.Errorf("unexpected group span attributes %v", groupSpan.Attributes())
+	}
+	if groupSpan.Status().Code != codes.Error {
+		t.Errorf("expected error status of group span, got %v", groupSpan.Status())
+	}
+
+	if len(spans["rule"]) != 2 {
+		t.Fatalf("expected 2 rule spans, got %d", len(spans["rule"]))
+	}
+	for _, sp := range spans["rule"] {
+		if sp.Parent().SpanID() != groupSpan.SpanContext().SpanID() {
+			t.Errorf("expected rule span to be a child of the group span")
+		}
+		a := attrs(sp)
+		switch a["name"].AsString() {
+		case "ok":
+			if a["expr"].AsString() != "vector(1)" || a["samples"].AsInt64() != 1 || sp.Status().Code == codes.Error {
+				t.Errorf("unexpected span of successful rule: %v, %v", sp.Attributes(), sp.Status())
+			}
+		case "failing":
+			if a["expr"].AsString() != "fail" || sp.Status().Code != codes.Error {
+				t.Errorf("unexpected span of failing rule: %v, %v", sp.Attributes(), sp.Status())
+			}
+		default:
+			t.Errorf("unexpected rule span %v", sp.Attributes())
+		}
+	}
+
+	querySpans := spans["query /api/v1/query"]
+	if len(querySpans) != 2 {
+		t.Fatalf("expected 2 query spans, got %d", len(querySpans))
+	}
+	for _, sp := range querySpans {
+		if a := attrs(sp); a["http.response.status_code"].AsInt64() == 0 {
+			t.Errorf("expected status code attribute, got %v", sp.Attributes())
+		}
+	}
+	for _, tp := range traceparents {
+		if tp == "" {
+			t.Error("expected trace context to be propagated to the query target")
+		}
+	}
+}
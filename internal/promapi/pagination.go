@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promapi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+
+	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
+)
+
+// Rule group pagination parameters of the /api/v1/rules endpoint.
+// https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+const (
+	GroupLimitQueryParamName     = "group_limit"
+	GroupNextTokenQueryParamName = "group_next_token"
+)
+
+// ParseRuleGroupsPagination parses the pagination parameters of a rules request. A zero limit
+// means that the request is not paginated.
+func ParseRuleGroupsPagination(form url.Values) (limit int, nextToken string, err error) {
+	nextToken = form.Get(GroupNextTokenQueryParamName)
+	s := form.Get(GroupLimitQueryParamName)
+	if s == "" {
+		if nextToken != "" {
+			return 0, "", errors.New("group_limit needs to be present in order to paginate over the groups")
+		}
+		return 0, "", nil
+	}
+	limit, err = strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		return 0, "", errors.New("group_limit needs to be greater than 0")
+	}
+	return limit, nextToken, nil
+}
+
+// RuleGroupNextToken returns the pagination token of the given rule group.
+// Matches the token format of Prometheus.
+func RuleGroupNextToken(file, name string) string {
+	h := sha1.New()
+	h.Write([]byte(file + ";" + name))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PaginateRuleGroups returns the page of at most limit groups starting at the group identified
+// by nextToken, and the token of the first group of the next page, if any. The groups must be
+// in a stable order across requests.
+func PaginateRuleGroups(groups []*promapiv1.RuleGroup, limit int, nextToken string) ([]*promapiv1.RuleGroup, string, error) {
+	if limit <= 0 {
+		return groups, "", nil
+	}
+	start := 0
+	if nextToken != "" {
+		start = -1
+		for i, g := range groups {
+			if RuleGroupNextToken(g.File, g.Name) == nextToken {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", errors.New("invalid group_next_token")
+		}
+	}
+	groups = groups[start:]
+	if len(groups) <= limit {
+		return groups, "", nil
+	}
+	return groups[:limit], RuleGroupNextToken(groups[limit].File, groups[limit].Name), nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promapi
+
+import (
+	"net/url"
+	"testing"
+
+	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRuleGroupsPagination(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantToken string
+		wantErr   bool
+	}{
+		{name: "not paginated"},
+		{name: "first page", query: "group_limit=10", wantLimit: 10},
+		{name: "next page", query: "group_limit=10&group_next_token=abc", wantLimit: 10, wantToken: "abc"},
+		{name: "token without limit", query: "group_next_token=abc", wantErr: true},
+		{name: "zero limit", query: "group_limit=0", wantErr: true},
+		{name: "invalid limit", query: "group_limit=foo", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			form, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			limit, token, err := ParseRuleGroupsPagination(form)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantLimit, limit)
+			require.Equal(t, tt.wantToken, token)
+		})
+	}
+}
+
+func TestPaginateRuleGroups(t *testing.T) {
+	t.Parallel()
+
+	groups := []*promapiv1.RuleGroup{
+		{Name: "a", File: "f"},
+		{Name: "b", File: "f"},
+		{Name: "c", File: "f"},
+	}
+	names := func(groups []*promapiv1.RuleGroup) []string {
+		var res []string
+		for _, g := range groups {
+			res = append(res, g.Name)
+		}
+		return res
+	}
+
+	page, next, err := PaginateRuleGroups(groups, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, names(page))
+	require.Empty(t, next)
+
+	page, next, err = PaginateRuleGroups(groups, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, names(page))
+	require.Equal(t, RuleGroupNextToken("f", "c"), next)
+
+	page, next, err = PaginateRuleGroups(groups, 2, next)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, names(page))
+	require.Empty(t, next)
+
+	_, _, err = PaginateRuleGroups(groups, 2, RuleGroupNextToken("f", "d"))
+	require.Error(t, err)
+}
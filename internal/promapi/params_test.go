@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "0", want: time.Unix(0, 0).UTC()},
+		{in: "1700000000.1234", want: time.UnixMilli(1700000000123).UTC()},
+		{in: "-1.5", want: time.UnixMilli(-1500).UTC()},
+		{in: "2023-11-14T22:13:20.5Z", want: time.UnixMilli(1700000000500).UTC()},
+		{in: "NaN", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTime(tt.in)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "15", want: 15 * time.Second},
+		{in: "0.5", want: 500 * time.Millisecond},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "NaN", wantErr: true},
+		{in: "1e20", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "1x", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
 	promapiv1 "github.com/prometheus/prometheus/web/api/v1"
 )
 
@@ -28,7 +29,7 @@ import (
 
 // Response is the prometheus-compatible Response format.
 // https://prometheus.io/docs/prometheus/latest/querying/api/#format-overview
-type Response[T RulesResponseData | AlertsResponseData | GenericResponseData] struct {
+type Response[T RulesResponseData | AlertsResponseData | QueryRangeResponseData | GenericResponseData] struct {
 	Status    status    `json:"status"`
 	Data      T         `json:"data,omitempty"`
 	ErrorType ErrorType `json:"errorType,omitempty"`
@@ -38,13 +39,20 @@ type Response[T RulesResponseData | AlertsResponseData | GenericResponseData] st
 }
 
 type RulesResponseData struct {
-	Groups []*promapiv1.RuleGroup `json:"groups"`
+	Groups         []*promapiv1.RuleGroup `json:"groups"`
+	GroupNextToken string                 `json:"groupNextToken,omitempty"`
 }
 
 type AlertsResponseData struct {
 	Alerts []*promapiv1.Alert `json:"alerts"`
 }
 
+// QueryRangeResponseData is the data of range query responses.
+type QueryRangeResponseData struct {
+	ResultType model.ValueType `json:"resultType"`
+	Result     model.Matrix    `json:"result"`
+}
+
 type GenericResponseData any
 
 type ErrorType string
@@ -70,7 +78,7 @@ const (
 )
 
 // writeResponse writes a Response to given responseWriter w if it can, otherwise it logs the error and writes a generic error.
-func writeResponse[T RulesResponseData | AlertsResponseData | GenericResponseData](logger log.Logger, w http.ResponseWriter, httpResponseCode int, endpointURI string, resp Response[T]) {
+func writeResponse[T RulesResponseData | AlertsResponseData | QueryRangeResponseData | GenericResponseData](logger log.Logger, w http.ResponseWriter, httpResponseCode int, endpointURI string, resp Response[T]) {
 	logger = log.With(logger, "endpointURI", endpointURI, "intendedStatusCode", httpResponseCode)
 	w.Header().Set("Content-Type", "application/json")
 
@@ -99,6 +107,17 @@ func WriteSuccessResponse[T RulesResponseData | AlertsResponseData | promapiv1.P
 	})
 }
 
+// WriteQueryRangeResponse writes a successful range query Response with the given warnings
+// and infos to the given responseWriter w.
+func WriteQueryRangeResponse(logger log.Logger, w http.ResponseWriter, endpointURI string, responseData QueryRangeResponseData, warnings, infos []string) {
+	writeResponse(logger, w, http.StatusOK, endpointURI, Response[QueryRangeResponseData]{
+		Status:   statusSuccess,
+		Data:     responseData,
+		Warnings: warnings,
+		Infos:    infos,
+	})
+}
+
 // WriteError writes an error Response to the given responseWriter w.
 func WriteError(logger log.Logger, w http.ResponseWriter, errType ErrorType, errMsg string, httpResponseCode int, endpointURI string) {
 	writeResponse(logger, w, httpResponseCode, endpointURI, Response[GenericResponseData]{
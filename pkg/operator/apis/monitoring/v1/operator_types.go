@@ -18,7 +18,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"text/template"
 
+	prommodel "github.com/prometheus/common/model"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -79,6 +81,52 @@ func validateRules(rules *RuleEvaluatorSpec) error {
 	if err := validateSecretKeySelector(rules.Credentials); err != nil {
 		return fmt.Errorf("invalid credentials: %w", err)
 	}
+	if ha := rules.HighAvailability; ha != nil {
+		switch ha.Mode {
+		case "", RuleEvaluatorHAModeNone, RuleEvaluatorHAModeLeader, RuleEvaluatorHAModeShard:
+		default:
+			return fmt.Errorf("unknown high availability mode %q", ha.Mode)
+		}
+		if ha.Replicas != nil && *ha.Replicas < 1 {
+			return fmt.Errorf("high availability replicas must be at least 1, got %d", *ha.Replicas)
+		}
+	}
+	for i, o := range rules.QueryOverrides {
+		switch o.Kind {
+		case "Rules":
+		case "ClusterRules", "GlobalRules":
+			if o.Namespace != "" {
+				return fmt.Errorf("query override %d: namespace is only allowed for kind Rules", i)
+			}
+		default:
+			return fmt.Errorf("query override %d: unknown kind %q", i, o.Kind)
+		}
+		if o.QueryProjectID == "" {
+			return fmt.Errorf("query override %d: missing query project ID", i)
+		}
+	}
+	if rules.QueryOffset != "" {
+		if _, err := prommodel.ParseDuration(rules.QueryOffset); err != nil {
+			return fmt.Errorf("invalid query offset: %w", err)
+		}
+	}
+	if g := rules.AlertGeneratorURL; g != nil {
+		switch g.Preset {
+		case "", RuleEvaluatorGeneratorURLPresetGoogleCloud, RuleEvaluatorGeneratorURLPresetPrometheus:
+		default:
+			return fmt.Errorf("unknown alert generator URL preset %q", g.Preset)
+		}
+		if g.Preset != "" && g.Template != "" {
+			return errors.New("only one of alert generator URL preset and template may be set")
+		}
+		// Only parse the template, the functions are implemented by the rule-evaluator.
+		stub := func(any) any { return nil }
+		if _, err := template.New("").Funcs(template.FuncMap{
+			"queryEscape": stub, "pathEscape": stub, "unixMilli": stub, "json": stub,
+		}).Parse(g.Template); err != nil {
+			return fmt.Errorf("invalid alert generator URL template: %w", err)
+		}
+	}
 	for i, alertManagerEndpoint := range rules.Alerting.Alertmanagers {
 		if err := validateAlertManagerEndpoint(&alertManagerEndpoint); err != nil {
 			return fmt.Errorf("invalid alert manager endpoint `%s` (index %d): %w", alertManagerEndpoint.Name, i, err)
@@ -166,6 +214,107 @@ type RuleEvaluatorSpec struct {
 	// service account has the required permissions.
 	// +kubebuilder:validation:XValidation:rule="has(self.name) && self.name != ''",message="missing secret key selector name"
 	Credentials *corev1.SecretKeySelector `json:"credentials,omitempty"`
+	// HighAvailability configures running multiple rule-evaluator replicas.
+	HighAvailability *RuleEvaluatorHighAvailability `json:"highAvailability,omitempty"`
+	// Shadow runs the rule-evaluator in shadow mode. Rules are evaluated and
+	// exposed through the rules and alerts APIs, but rule results are not written
+	// and alerts are not sent. Instead, they are logged and counted in metrics.
+	// Useful to validate rules or a new rule-evaluator version side by side with
+	// the production one.
+	Shadow bool `json:"shadow,omitempty"`
+	// QueryOverrides evaluate the rules of matching rules resources against
+	// another project than queryProjectID. The first matching override applies.
+	QueryOverrides []RuleEvaluatorQueryOverride `json:"queryOverrides,omitempty"`
+	// AlertGeneratorURL configures how the generator URL in the alert notification
+	// payload is built. By default, alerts link to the Cloud Console, or to the
+	// query frontend at generatorUrl if set.
+	AlertGeneratorURL *RuleEvaluatorAlertGeneratorURL `json:"alertGeneratorUrl,omitempty"`
+	// QueryOffset shifts the query time of all rule evaluations into the past, to
+	// allow for the ingestion delay of Google Cloud Monitoring. Without it, recording
+	// rules may read incomplete data of the most recent interval. Results are
+	// written at the query time.
+	// +kubebuilder:validation:Format=duration
+	QueryOffset string `json:"queryOffset,omitempty"`
+}
+
+// RuleEvaluatorGeneratorURLPreset is a built-in generator URL of alerts.
+// +kubebuilder:validation:Enum=google_cloud;prometheus
+type RuleEvaluatorGeneratorURLPreset string
+
+const (
+	// RuleEvaluatorGeneratorURLPresetGoogleCloud links alerts to the Cloud Console
+	// metrics explorer of queryProjectID.
+	RuleEvaluatorGeneratorURLPresetGoogleCloud RuleEvaluatorGeneratorURLPreset = "google_cloud"
+	// RuleEvaluatorGeneratorURLPresetPrometheus links alerts to the query frontend
+	// at generatorUrl.
+	RuleEvaluatorGeneratorURLPresetPrometheus RuleEvaluatorGeneratorURLPreset = "prometheus"
+)
+
+// RuleEvaluatorAlertGeneratorURL configures the generator URL of alerts.
+// +kubebuilder:validation:XValidation:rule="!(has(self.preset) && has(self.template))",message="only one of preset and template may be set"
+type RuleEvaluatorAlertGeneratorURL struct {
+	// Preset is a built-in generator URL.
+	Preset RuleEvaluatorGeneratorURLPreset `json:"preset,omitempty"`
+	// Template is a Go template building the generator URL, e.g. to link to
+	// Grafana Explore. It is executed with the fields GeneratorURL, Expr,
+	// ProjectID, StartTime, EndTime, RuleName, Group, File and Labels, and
+	// the functions queryEscape, pathEscape, unixMilli and json.
+	Template string `json:"template,omitempty"`
+}
+
+// RuleEvaluatorQueryOverride evaluates the rules of matching rules resources
+// against another project.
+// +kubebuilder:validation:XValidation:rule="!has(self.namespace) || self.kind == 'Rules'",message="namespace is only allowed for kind Rules"
+type RuleEvaluatorQueryOverride struct {
+	// Kind of the rules resources.
+	// +kubebuilder:validation:Enum=Rules;ClusterRules;GlobalRules
+	Kind string `json:"kind"`
+	// Namespace of the Rules resources. Matches all namespaces if empty.
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the rules resources. Matches all names if empty.
+	Name string `json:"name,omitempty"`
+	// QueryProjectID is the GCP project ID to evaluate the rules against.
+	// +kubebuilder:validation:MinLength=1
+	QueryProjectID string `json:"queryProjectID"`
+}
+
+// RuleEvaluatorHAMode is the high availability mode of the rule-evaluator.
+// +kubebuilder:validation:Enum=none;leader;shard
+type RuleEvaluatorHAMode string
+
+const (
+	// RuleEvaluatorHAModeNone runs a single rule-evaluator replica.
+	RuleEvaluatorHAModeNone RuleEvaluatorHAMode = "none"
+	// RuleEvaluatorHAModeLeader runs active/passive replicas. All replicas evaluate
+	// all rules, but only the replica holding a Kubernetes Lease writes rule results
+	// and sends alerts.
+	RuleEvaluatorHAModeLeader RuleEvaluatorHAMode = "leader"
+	// RuleEvaluatorHAModeShard distributes rule groups across all replicas by
+	// consistent hashing. Rule groups of failed replicas are taken over by the
+	// remaining ones.
+	RuleEvaluatorHAModeShard RuleEvaluatorHAMode = "shard"
+)
+
+// RuleEvaluatorHighAvailability configures running multiple rule-evaluator replicas.
+type RuleEvaluatorHighAvailability struct {
+	// Mode is how rule-evaluator replicas coordinate. Defaults to none.
+	Mode RuleEvaluatorHAMode `json:"mode,omitempty"`
+	// Replicas is the number of rule-evaluator replicas while any rules exist.
+	// Ignored if mode is none. Defaults to 2.
+	// +kubebuilder:validation:Minimum=1
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// RuleEvaluatorReplicas returns the number of rule-evaluator replicas to run if
+// any rules exist.
+func (ha *RuleEvaluatorHighAvailability) RuleEvaluatorReplicas() int32 {
+	if ha == nil || ha.Mode == "" || ha.Mode == RuleEvaluatorHAModeNone {
+		return 1
+	}
+	if ha.Replicas == nil {
+		return 2
+	}
+	return *ha.Replicas
 }
 
 // CollectionSpec specifies how the operator configures collection of metric data.
@@ -1070,6 +1070,59 @@ func (in *Rule) DeepCopy() *Rule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleEvaluatorAlertGeneratorURL) DeepCopyInto(out *RuleEvaluatorAlertGeneratorURL) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleEvaluatorAlertGeneratorURL.
+func (in *RuleEvaluatorAlertGeneratorURL) DeepCopy() *RuleEvaluatorAlertGeneratorURL {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleEvaluatorAlertGeneratorURL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleEvaluatorHighAvailability) DeepCopyInto(out *RuleEvaluatorHighAvailability) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleEvaluatorHighAvailability.
+func (in *RuleEvaluatorHighAvailability) DeepCopy() *RuleEvaluatorHighAvailability {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleEvaluatorHighAvailability)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleEvaluatorQueryOverride) DeepCopyInto(out *RuleEvaluatorQueryOverride) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleEvaluatorQueryOverride.
+func (in *RuleEvaluatorQueryOverride) DeepCopy() *RuleEvaluatorQueryOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleEvaluatorQueryOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RuleEvaluatorSpec) DeepCopyInto(out *RuleEvaluatorSpec) {
 	*out = *in
@@ -1086,6 +1139,21 @@ func (in *RuleEvaluatorSpec) DeepCopyInto(out *RuleEvaluatorSpec) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HighAvailability != nil {
+		in, out := &in.HighAvailability, &out.HighAvailability
+		*out = new(RuleEvaluatorHighAvailability)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.QueryOverrides != nil {
+		in, out := &in.QueryOverrides, &out.QueryOverrides
+		*out = make([]RuleEvaluatorQueryOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.AlertGeneratorURL != nil {
+		in, out := &in.AlertGeneratorURL, &out.AlertGeneratorURL
+		*out = new(RuleEvaluatorAlertGeneratorURL)
+		**out = **in
+	}
 	return
 }
 
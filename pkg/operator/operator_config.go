@@ -314,21 +314,46 @@ func (r *operatorConfigReconciler) makeRuleEvaluatorConfig(ctx context.Context,
 		queryProjectID = spec.QueryProjectID
 	}
 
-	cfg := promforkconfig.Config{
-		GlobalConfig: promforkconfig.GlobalConfig{
-			ExternalLabels: labels.FromMap(spec.ExternalLabels),
-		},
-		AlertingConfig: promforkconfig.AlertingConfig{
-			AlertmanagerConfigs: amConfigs,
-		},
-		RuleFiles: []string{path.Join(rulesDir, "*.yaml")},
-		GoogleCloud: gcmconfig.GoogleCloudConfig{
-			Query: gcmconfig.GoogleCloudQueryConfig{
-				ProjectID:    queryProjectID,
-				GeneratorURL: spec.GeneratorURL,
+	cfg := ruleEvaluatorConfig{
+		Config: promforkconfig.Config{
+			GlobalConfig: promforkconfig.GlobalConfig{
+				ExternalLabels: labels.FromMap(spec.ExternalLabels),
+			},
+			AlertingConfig: promforkconfig.AlertingConfig{
+				AlertmanagerConfigs: amConfigs,
+			},
+			RuleFiles: []string{path.Join(rulesDir, "*.yaml")},
+			GoogleCloud: gcmconfig.GoogleCloudConfig{
+				Query: gcmconfig.GoogleCloudQueryConfig{
+					ProjectID:    queryProjectID,
+					GeneratorURL: spec.GeneratorURL,
+				},
 			},
 		},
 	}
+	if spec.HighAvailability != nil {
+		cfg.RuleEvaluator.HighAvailability.Mode = string(spec.HighAvailability.Mode)
+	}
+	if spec.QueryOffset != "" {
+		queryOffset, err := prommodel.ParseDuration(spec.QueryOffset)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse query offset: %w", err)
+		}
+		cfg.GlobalConfig.RuleQueryOffset = queryOffset
+	}
+	cfg.RuleEvaluator.Shadow = spec.Shadow
+	for _, o := range spec.QueryOverrides {
+		cfg.RuleEvaluator.QueryOverrides = append(cfg.RuleEvaluator.QueryOverrides, ruleEvaluatorQueryOverride{
+			File:      path.Join(rulesDir, rulesFileGlob(o.Kind, o.Namespace, o.Name)),
+			ProjectID: o.QueryProjectID,
+		})
+	}
+	if g := spec.AlertGeneratorURL; g != nil {
+		cfg.RuleEvaluator.AlertGeneratorURL = ruleEvaluatorGeneratorURLConfig{
+			Preset:   string(g.Preset),
+			Template: g.Template,
+		}
+	}
 	if spec.Credentials != nil {
 		credentialsFile := path.Join(secretsDir, pathForSelector(r.opts.PublicNamespace, &monitoringv1.SecretOrConfigMap{Secret: spec.Credentials}))
 		cfg.GoogleCloud.Query.CredentialsFile = credentialsFile
@@ -451,6 +476,54 @@ func (r *operatorConfigReconciler) ensureAlertmanagerConfigSecret(ctx context.Co
 	return nil
 }
 
+// ruleEvaluatorConfig is the rule-evaluator configuration file. Matches the rule-evaluator's
+// configuration.
+type ruleEvaluatorConfig struct {
+	promforkconfig.Config `yaml:",inline"`
+
+	RuleEvaluator ruleEvaluatorExtraConfig `yaml:"rule_evaluator,omitempty"`
+}
+
+type ruleEvaluatorExtraConfig struct {
+	HighAvailability  ruleEvaluatorHAConfig           `yaml:"high_availability,omitempty"`
+	Shadow            bool                            `yaml:"shadow,omitempty"`
+	QueryOverrides    []ruleEvaluatorQueryOverride    `yaml:"query_overrides,omitempty"`
+	AlertGeneratorURL ruleEvaluatorGeneratorURLConfig `yaml:"alert_generator_url,omitempty"`
+}
+
+type ruleEvaluatorGeneratorURLConfig struct {
+	Preset   string `yaml:"preset,omitempty"`
+	Template string `yaml:"template,omitempty"`
+}
+
+type ruleEvaluatorQueryOverride struct {
+	File      string `yaml:"file,omitempty"`
+	ProjectID string `yaml:"project_id,omitempty"`
+}
+
+// rulesFileGlob returns a glob matching the rule files generated for rules resources of the
+// given kind, namespace and name. Empty namespaces and names match all.
+func rulesFileGlob(kind, namespace, name string) string {
+	if namespace == "" {
+		namespace = "*"
+	}
+	if name == "" {
+		name = "*"
+	}
+	switch kind {
+	case "ClusterRules":
+		return fmt.Sprintf("clusterrules__%s.yaml", name)
+	case "GlobalRules":
+		return fmt.Sprintf("globalrules__%s.yaml", name)
+	default:
+		return fmt.Sprintf("rules__%s__%s.yaml", namespace, name)
+	}
+}
+
+type ruleEvaluatorHAConfig struct {
+	Mode string `yaml:"mode,omitempty"`
+}
+
 func alertmanagerConfigMarshal(userConfig []byte, overrideSpec *monitoringv1.ManagedAlertmanagerSpec) ([]byte, error) {
 	inter := map[string]any{}
 
@@ -16,10 +16,12 @@ package operator
 
 import (
 	"testing"
+	"time"
 
 	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	prommodel "github.com/prometheus/common/model"
 	promforkconfig "github.com/prometheus/prometheus/config"
 	gcmconfig "github.com/prometheus/prometheus/google/config"
 	"github.com/prometheus/prometheus/google/export"
@@ -98,6 +100,147 @@ runtime:
 	}
 }
 
+func TestMakeRuleEvaluatorConfigHighAvailability(t *testing.T) {
+	reconciler := newOperatorConfigReconciler(newFakeClientBuilder().Build(), Options{ProjectID: "test-project"})
+
+	for _, tc := range []struct {
+		desc string
+		ha   *monitoringv1.RuleEvaluatorHighAvailability
+		want string
+	}{
+		{
+			desc: "unset",
+		},
+		{
+			desc: "sharding",
+			ha:   &monitoringv1.RuleEvaluatorHighAvailability{Mode: monitoringv1.RuleEvaluatorHAModeShard},
+			want: "shard",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cm, _, err := reconciler.makeRuleEvaluatorConfig(t.Context(), &monitoringv1.RuleEvaluatorSpec{
+				HighAvailability: tc.ha,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The embedded Prometheus config implements yaml.Unmarshaler, which would
+			// drop the rule-evaluator section, so decode into a plain struct.
+			var cfg struct {
+				GoogleCloud   gcmconfig.GoogleCloudConfig `yaml:"google_cloud"`
+				RuleEvaluator ruleEvaluatorExtraConfig    `yaml:"rule_evaluator"`
+			}
+			if err := yaml.Unmarshal([]byte(cm.Data[configFilename]), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.RuleEvaluator.HighAvailability.Mode; got != tc.want {
+				t.Errorf("expected mode %q, got %q", tc.want, got)
+			}
+			if got := cfg.GoogleCloud.Query.ProjectID; got != "test-project" {
+				t.Errorf("expected query project %q, got %q", "test-project", got)
+			}
+		})
+	}
+}
+
+func TestMakeRuleEvaluatorConfigShadow(t *testing.T) {
+	reconciler := newOperatorConfigReconciler(newFakeClientBuilder().Build(), Options{ProjectID: "test-project"})
+
+	for _, shadow := range []bool{false, true} {
+		cm, _, err := reconciler.makeRuleEvaluatorConfig(t.Context(), &monitoringv1.RuleEvaluatorSpec{
+			Shadow: shadow,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg struct {
+			RuleEvaluator ruleEvaluatorExtraConfig `yaml:"rule_evaluator"`
+		}
+		if err := yaml.Unmarshal([]byte(cm.Data[configFilename]), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.RuleEvaluator.Shadow; got != shadow {
+			t.Errorf("expected shadow %v, got %v", shadow, got)
+		}
+	}
+}
+
+func TestMakeRuleEvaluatorConfigQueryOverrides(t *testing.T) {
+	reconciler := newOperatorConfigReconciler(newFakeClientBuilder().Build(), Options{ProjectID: "test-project"})
+
+	cm, _, err := reconciler.makeRuleEvaluatorConfig(t.Context(), &monitoringv1.RuleEvaluatorSpec{
+		QueryOverrides: []monitoringv1.RuleEvaluatorQueryOverride{
+			{Kind: "GlobalRules", QueryProjectID: "central"},
+			{Kind: "ClusterRules", Name: "example", QueryProjectID: "central"},
+			{Kind: "Rules", Namespace: "ns", QueryProjectID: "other"},
+			{Kind: "Rules", QueryProjectID: "other"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		RuleEvaluator ruleEvaluatorExtraConfig `yaml:"rule_evaluator"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data[configFilename]), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := []ruleEvaluatorQueryOverride{
+		{File: "/etc/rules/globalrules__*.yaml", ProjectID: "central"},
+		{File: "/etc/rules/clusterrules__example.yaml", ProjectID: "central"},
+		{File: "/etc/rules/rules__ns__*.yaml", ProjectID: "other"},
+		{File: "/etc/rules/rules__*__*.yaml", ProjectID: "other"},
+	}
+	if diff := cmp.Diff(want, cfg.RuleEvaluator.QueryOverrides); diff != "" {
+		t.Errorf("unexpected query overrides (-want, +got): %s", diff)
+	}
+}
+
+func TestMakeRuleEvaluatorConfigAlertGeneratorURL(t *testing.T) {
+	reconciler := newOperatorConfigReconciler(newFakeClientBuilder().Build(), Options{ProjectID: "test-project"})
+
+	cm, _, err := reconciler.makeRuleEvaluatorConfig(t.Context(), &monitoringv1.RuleEvaluatorSpec{
+		AlertGeneratorURL: &monitoringv1.RuleEvaluatorAlertGeneratorURL{
+			Template: "https://grafana.example.com/explore?expr={{ .Expr | queryEscape }}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		RuleEvaluator ruleEvaluatorExtraConfig `yaml:"rule_evaluator"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data[configFilename]), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := ruleEvaluatorGeneratorURLConfig{Template: "https://grafana.example.com/explore?expr={{ .Expr | queryEscape }}"}
+	if diff := cmp.Diff(want, cfg.RuleEvaluator.AlertGeneratorURL); diff != "" {
+		t.Errorf("unexpected alert generator URL (-want, +got): %s", diff)
+	}
+}
+
+func TestMakeRuleEvaluatorConfigQueryOffset(t *testing.T) {
+	reconciler := newOperatorConfigReconciler(newFakeClientBuilder().Build(), Options{ProjectID: "test-project"})
+
+	cm, _, err := reconciler.makeRuleEvaluatorConfig(t.Context(), &monitoringv1.RuleEvaluatorSpec{
+		QueryOffset: "2m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		GlobalConfig struct {
+			RuleQueryOffset prommodel.Duration `yaml:"rule_query_offset"`
+		} `yaml:"global"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data[configFilename]), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if want := prommodel.Duration(2 * time.Minute); cfg.GlobalConfig.RuleQueryOffset != want {
+		t.Errorf("expected rule query offset %s, got %s", want, cfg.GlobalConfig.RuleQueryOffset)
+	}
+}
+
 func TestEnsureOperatorConfig(t *testing.T) {
 	logger := logr.Discard()
 	operatorOpts := Options{
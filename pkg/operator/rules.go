@@ -125,17 +125,17 @@ func (r *rulesReconciler) Reconcile(ctx context.Context, req reconcile.Request)
 		return reconcile.Result{}, fmt.Errorf("ensure rule configmaps: %w", err)
 	}
 
-	if err := r.scaleRuleConsumers(ctx); err != nil {
+	if err := r.scaleRuleConsumers(ctx, &config.Rules); err != nil {
 		return reconcile.Result{}, fmt.Errorf("scale rule consumers: %w", err)
 	}
 
 	return reconcile.Result{}, nil
 }
 
-func (r *rulesReconciler) scaleRuleConsumers(ctx context.Context) error {
+func (r *rulesReconciler) scaleRuleConsumers(ctx context.Context, spec *monitoringv1.RuleEvaluatorSpec) error {
 	logger, _ := logr.FromContext(ctx)
 
-	var desiredReplicas int32
+	var desiredReplicas, desiredRuleEvaluatorReplicas int32
 
 	var hasAnyRules bool
 	for _, check := range []ruleCheck{hasRules, hasClusterRules, hasGlobalRules} {
@@ -150,6 +150,7 @@ func (r *rulesReconciler) scaleRuleConsumers(ctx context.Context) error {
 	}
 	if hasAnyRules {
 		desiredReplicas = 1
+		desiredRuleEvaluatorReplicas = spec.HighAvailability.RuleEvaluatorReplicas()
 	}
 
 	scaleClient := r.client.SubResource("scale")
@@ -181,12 +182,12 @@ func (r *rulesReconciler) scaleRuleConsumers(ctx context.Context) error {
 	}
 	ruleEvaluatorScale := autoscalingv1.Scale{}
 	if err := scaleClient.Get(ctx, &ruleEvaluatorDeployment, &ruleEvaluatorScale); apierrors.IsNotFound(err) {
-		msg := fmt.Sprintf("Rule Evaluator Deployment not found, cannot scale to %d. In-cluster Rule Evaluator will not function.", desiredReplicas)
+		msg := fmt.Sprintf("Rule Evaluator Deployment not found, cannot scale to %d. In-cluster Rule Evaluator will not function.", desiredRuleEvaluatorReplicas)
 		logger.Error(err, msg)
 	} else if err != nil {
 		return err
-	} else if ruleEvaluatorScale.Spec.Replicas != desiredReplicas {
-		ruleEvaluatorScale.Spec.Replicas = desiredReplicas
+	} else if ruleEvaluatorScale.Spec.Replicas != desiredRuleEvaluatorReplicas {
+		ruleEvaluatorScale.Spec.Replicas = desiredRuleEvaluatorReplicas
 		if err := scaleClient.Update(ctx, &ruleEvaluatorDeployment, client.WithSubResourceBody(&ruleEvaluatorScale)); err != nil {
 			return err
 		}
@@ -29,6 +29,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
@@ -430,8 +431,11 @@ func TestScaleRuleConsumers(t *testing.T) {
 
 	type test struct {
 		client  client.Client
+		spec    monitoringv1.RuleEvaluatorSpec
 		want    int32
 		wantErr bool
+		// wantRuleEvaluator defaults to want.
+		wantRuleEvaluator *int32
 	}
 
 	tests := map[string]test{
@@ -442,6 +446,35 @@ func TestScaleRuleConsumers(t *testing.T) {
 		"rule-evaluator deleted":            {client: ruleEvaluatorDeleted, want: 0, wantErr: false},
 		"alertmanager deleted with rules":   {client: alertmanagerDeletedWithRules, want: 1, wantErr: false},
 		"rule-evaluator deleted with rules": {client: ruleEvaluatorDeletedWithRules, want: 1, wantErr: false},
+		"has rule with leader election": {
+			client: hasRule,
+			spec: monitoringv1.RuleEvaluatorSpec{
+				HighAvailability: &monitoringv1.RuleEvaluatorHighAvailability{Mode: monitoringv1.RuleEvaluatorHAModeLeader},
+			},
+			want:              1,
+			wantRuleEvaluator: ptr.To(int32(2)),
+		},
+		"has rule with sharding": {
+			client: hasRule,
+			spec: monitoringv1.RuleEvaluatorSpec{
+				HighAvailability: &monitoringv1.RuleEvaluatorHighAvailability{
+					Mode:     monitoringv1.RuleEvaluatorHAModeShard,
+					Replicas: ptr.To(int32(3)),
+				},
+			},
+			want:              1,
+			wantRuleEvaluator: ptr.To(int32(3)),
+		},
+		"no rules with sharding": {
+			client: emptyRules,
+			spec: monitoringv1.RuleEvaluatorSpec{
+				HighAvailability: &monitoringv1.RuleEvaluatorHighAvailability{
+					Mode:     monitoringv1.RuleEvaluatorHAModeShard,
+					Replicas: ptr.To(int32(3)),
+				},
+			},
+			want: 0,
+		},
 	}
 
 	for name, tc := range tests {
@@ -449,7 +482,7 @@ func TestScaleRuleConsumers(t *testing.T) {
 			r := rulesReconciler{
 				client: &fakeClientWithScale{tc.client},
 			}
-			err := r.scaleRuleConsumers(t.Context())
+			err := r.scaleRuleConsumers(t.Context(), &tc.spec)
 			if err != nil {
 				if !tc.wantErr {
 					t.Errorf("Unexpected error: %s", err)
@@ -469,8 +502,12 @@ func TestScaleRuleConsumers(t *testing.T) {
 			if err := r.client.Get(t.Context(), client.ObjectKey{Name: "rule-evaluator"}, &ruleEvaluator); client.IgnoreNotFound(err) != nil {
 				t.Error(err)
 			}
-			if ruleEvaluator.Spec.Replicas != nil && *ruleEvaluator.Spec.Replicas != tc.want {
-				t.Errorf("want: %d, got: %d", tc.want, ruleEvaluator.Spec.Replicas)
+			wantRuleEvaluator := tc.want
+			if tc.wantRuleEvaluator != nil {
+				wantRuleEvaluator = *tc.wantRuleEvaluator
+			}
+			if ruleEvaluator.Spec.Replicas != nil && *ruleEvaluator.Spec.Replicas != wantRuleEvaluator {
+				t.Errorf("want: %d, got: %d", wantRuleEvaluator, *ruleEvaluator.Spec.Replicas)
 			}
 		})
 	}